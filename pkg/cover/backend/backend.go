@@ -37,6 +37,9 @@ type Symbol struct {
 	Start      uint64
 	End        uint64
 	Symbolized bool
+	// Functions called directly from this one, only for the kernel image on amd64 and arm64
+	// and only if the call graph was requested.
+	Callees []*Symbol
 }
 
 // ObjectUnit represents either CompileUnit or Symbol.
@@ -63,16 +66,18 @@ type Range struct {
 
 const LineEnd = 1 << 30
 
+// Make creates Impl for the kernel. If callGraph is set, Symbol.Callees are filled as well
+// (only for DWARF-based binaries).
 func Make(target *targets.Target, vm, objDir, srcDir, buildDir string,
-	moduleObj []string, modules []host.KernelModule) (*Impl, error) {
+	moduleObj []string, modules []host.KernelModule, callGraph bool) (*Impl, error) {
 	if objDir == "" {
 		return nil, fmt.Errorf("kernel obj directory is not specified")
 	}
 	if target.OS == "darwin" {
-		return makeMachO(target, objDir, srcDir, buildDir, moduleObj, modules)
+		return makeMachO(target, objDir, srcDir, buildDir, moduleObj, modules, callGraph)
 	}
 	if vm == "gvisor" {
 		return makeGvisor(target, objDir, srcDir, buildDir, modules)
 	}
-	return makeELF(target, objDir, srcDir, buildDir, moduleObj, modules)
+	return makeELF(target, objDir, srcDir, buildDir, moduleObj, modules, callGraph)
 }
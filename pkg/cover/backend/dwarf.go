@@ -30,6 +30,8 @@ type dwarfParams struct {
 	buildDir    string
 	moduleObj   []string
 	hostModules []host.KernelModule
+	// If set, direct calls are collected to fill Symbol.Callees.
+	callGraph bool
 	// Kernel coverage PCs in the [pcFixUpStart,pcFixUpEnd) range are offsetted by pcFixUpOffset.
 	pcFixUpStart          uint64
 	pcFixUpEnd            uint64
@@ -103,6 +105,7 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 	// Here and below index 0 refers to coverage callbacks (__sanitizer_cov_trace_pc(_guard))
 	// and index 1 refers to comparison callbacks (__sanitizer_cov_trace_cmp*).
 	var allCoverPoints [2][]uint64
+	var allCalls []callEdge
 	var allSymbols []*Symbol
 	var allRanges []pcRange
 	var allUnits []*CompileUnit
@@ -134,7 +137,14 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 					errc <- err
 					return
 				}
+				if params.callGraph {
+					info.funcs = make(map[uint64]bool, len(symbols))
+					for _, s := range symbols {
+						info.funcs[s.Start] = true
+					}
+				}
 				coverPoints, err = readCoverPoints(target, info, data)
+				allCalls = append(allCalls, info.calls...)
 			} else {
 				coverPoints, err = params.readModuleCoverPoints(target, module, info)
 			}
@@ -169,6 +179,7 @@ func makeDWARFUnsafe(params *dwarfParams) (*Impl, error) {
 	}
 
 	allSymbols = buildSymbols(allSymbols, allRanges, allCoverPoints)
+	buildCallGraph(allSymbols, allCalls)
 	nunit := 0
 	for _, unit := range allUnits {
 		if len(unit.PCs) == 0 {
@@ -268,12 +279,53 @@ func buildSymbols(symbols []*Symbol, ranges []pcRange, coverPoints [2][]uint64)
 	return symbols
 }
 
+// buildCallGraph fills Callees of the symbols (sorted by Start) from the direct calls.
+func buildCallGraph(symbols []*Symbol, calls []callEdge) {
+	if len(calls) == 0 {
+		return
+	}
+	funcs := make(map[uint64]*Symbol, len(symbols))
+	for _, s := range symbols {
+		funcs[s.Start] = s
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].pc < calls[j].pc
+	})
+	symbolIdx := 0
+	for i := 0; i < len(calls); {
+		for ; symbolIdx < len(symbols) && calls[i].pc >= symbols[symbolIdx].End; symbolIdx++ {
+		}
+		if symbolIdx == len(symbols) {
+			break
+		}
+		caller := symbols[symbolIdx]
+		seen := make(map[*Symbol]bool)
+		for ; i < len(calls) && calls[i].pc < caller.End; i++ {
+			callee := funcs[calls[i].callee]
+			if calls[i].pc < caller.Start || callee == nil || seen[callee] {
+				continue
+			}
+			seen[callee] = true
+			caller.Callees = append(caller.Callees, callee)
+		}
+	}
+}
+
 type symbolInfo struct {
 	textAddr    uint64
 	tracePC     uint64
 	traceCmp    map[uint64]bool
 	tracePCIdx  map[int]bool
 	traceCmpIdx map[int]bool
+	// If set, readCoverPoints collects calls of these addresses (function starts) in calls.
+	funcs map[uint64]bool
+	calls []callEdge
+}
+
+// callEdge is a direct call of the function starting at callee from pc.
+type callEdge struct {
+	pc     uint64
+	callee uint64
 }
 
 type pcRange struct {
@@ -425,6 +477,8 @@ func symbolize(target *targets.Target, objDir, srcDir, buildDir string,
 // readCoverPoints finds all coverage points (calls of __sanitizer_cov_trace_*) in the object file.
 // Currently it is [amd64|arm64]-specific: looks for opcode and correct offset.
 // Running objdump on the whole object file is too slow.
+// If info.funcs is set, it also collects calls of other functions for the call graph.
+// Since instructions are not decoded, a few of them may be bogus (opcode bytes inside other instructions).
 func readCoverPoints(target *targets.Target, info *symbolInfo, data []byte) ([2][]uint64, error) {
 	var pcs [2][]uint64
 	if info.tracePC == 0 {
@@ -450,6 +504,8 @@ func readCoverPoints(target *targets.Target, info *symbolInfo, data []byte) ([2]
 			pcs[0] = append(pcs[0], pc)
 		} else if info.traceCmp[target] {
 			pcs[1] = append(pcs[1], pc)
+		} else if info.funcs[target] {
+			info.calls = append(info.calls, callEdge{pc, target})
 		}
 	}
 	return pcs, nil
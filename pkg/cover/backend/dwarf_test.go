@@ -0,0 +1,57 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package backend
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestCallGraph(t *testing.T) {
+	const textAddr = 0x1000
+	data := make([]byte, 0x40)
+	call := func(pc, callee uint64) {
+		data[pc-textAddr] = 0xe8
+		binary.LittleEndian.PutUint32(data[pc-textAddr+1:], uint32(callee-pc-5))
+	}
+	// a -> {b, c}, b -> c, __sanitizer_cov_trace_pc is at 0x1030.
+	call(0x1000, 0x1030)
+	call(0x1005, 0x1010)
+	call(0x100a, 0x1020)
+	call(0x1010, 0x1030)
+	call(0x1015, 0x1020)
+	call(0x101a, 0x1020)
+	call(0x1020, 0x1030)
+	symbols := []*Symbol{
+		{ObjectUnit: ObjectUnit{Name: "a"}, Start: 0x1000, End: 0x1010},
+		{ObjectUnit: ObjectUnit{Name: "b"}, Start: 0x1010, End: 0x1020},
+		{ObjectUnit: ObjectUnit{Name: "c"}, Start: 0x1020, End: 0x1030},
+	}
+	info := &symbolInfo{
+		textAddr: textAddr,
+		tracePC:  0x1030,
+		funcs:    map[uint64]bool{0x1000: true, 0x1010: true, 0x1020: true},
+	}
+	pcs, err := readCoverPoints(targets.Get(targets.Linux, targets.AMD64), info, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{0x1000, 0x1010, 0x1020}; !reflect.DeepEqual(pcs[0], want) {
+		t.Fatalf("got coverage points %x, want %x", pcs[0], want)
+	}
+	buildCallGraph(symbols, info.calls)
+	callees := make(map[string][]string)
+	for _, s := range symbols {
+		for _, callee := range s.Callees {
+			callees[s.Name] = append(callees[s.Name], callee.Name)
+		}
+	}
+	want := map[string][]string{"a": {"b", "c"}, "b": {"c"}}
+	if !reflect.DeepEqual(callees, want) {
+		t.Fatalf("got call graph %v, want %v", callees, want)
+	}
+}
@@ -17,7 +17,7 @@ import (
 )
 
 func makeELF(target *targets.Target, objDir, srcDir, buildDir string,
-	moduleObj []string, hostModules []host.KernelModule) (*Impl, error) {
+	moduleObj []string, hostModules []host.KernelModule, callGraph bool) (*Impl, error) {
 	var pcFixUpStart, pcFixUpEnd, pcFixUpOffset uint64
 	if target.Arch == targets.ARM64 {
 		// On arm64 as PLT is enabled by default, .text section is loaded after .plt section,
@@ -42,6 +42,7 @@ func makeELF(target *targets.Target, objDir, srcDir, buildDir string,
 		buildDir:              buildDir,
 		moduleObj:             moduleObj,
 		hostModules:           hostModules,
+		callGraph:             callGraph,
 		pcFixUpStart:          pcFixUpStart,
 		pcFixUpEnd:            pcFixUpEnd,
 		pcFixUpOffset:         pcFixUpOffset,
@@ -15,7 +15,7 @@ import (
 )
 
 func makeMachO(target *targets.Target, objDir, srcDir, buildDir string,
-	moduleObj []string, hostModules []host.KernelModule) (*Impl, error) {
+	moduleObj []string, hostModules []host.KernelModule, callGraph bool) (*Impl, error) {
 	return makeDWARF(&dwarfParams{
 		target:                target,
 		objDir:                objDir,
@@ -23,6 +23,7 @@ func makeMachO(target *targets.Target, objDir, srcDir, buildDir string,
 		buildDir:              buildDir,
 		moduleObj:             moduleObj,
 		hostModules:           hostModules,
+		callGraph:             callGraph,
 		readSymbols:           machoReadSymbols,
 		readTextData:          machoReadTextData,
 		readModuleCoverPoints: machoReadModuleCoverPoints,
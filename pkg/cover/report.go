@@ -32,8 +32,20 @@ var RestorePC = backend.RestorePC
 
 func MakeReportGenerator(cfg *mgrconfig.Config, subsystem []mgrconfig.Subsystem,
 	modules []host.KernelModule, rawCover bool) (*ReportGenerator, error) {
+	return makeReportGenerator(cfg, subsystem, modules, rawCover, false)
+}
+
+// MakeCallGraphReportGenerator is like MakeReportGenerator, but also fills Symbol.Callees.
+// Extracting the call graph takes noticeably more time and memory for large kernels.
+func MakeCallGraphReportGenerator(cfg *mgrconfig.Config, subsystem []mgrconfig.Subsystem,
+	modules []host.KernelModule, rawCover bool) (*ReportGenerator, error) {
+	return makeReportGenerator(cfg, subsystem, modules, rawCover, true)
+}
+
+func makeReportGenerator(cfg *mgrconfig.Config, subsystem []mgrconfig.Subsystem,
+	modules []host.KernelModule, rawCover, callGraph bool) (*ReportGenerator, error) {
 	impl, err := backend.Make(cfg.SysTarget, cfg.Type, cfg.KernelObj,
-		cfg.KernelSrc, cfg.KernelBuildSrc, cfg.ModuleObj, modules)
+		cfg.KernelSrc, cfg.KernelBuildSrc, cfg.ModuleObj, modules, callGraph)
 	if err != nil {
 		return nil, err
 	}
@@ -21,6 +21,10 @@ type Config struct {
 	// Location of a working directory for the syz-manager process. Outputs here include:
 	// - <workdir>/crashes/*: crash output files
 	// - <workdir>/corpus.db: corpus with interesting programs
+	// - <workdir>/seeds.db: power schedule counters of corpus programs
+	// - <workdir>/provenance.db: how corpus programs were found
+	// - <workdir>/signal.db: checkpoint of corpus and max signal to resume without re-triage,
+	//   and of pending hub candidates (other candidates are recreated from corpus.db and seeds)
 	// - <workdir>/instance-x: per VM instance temporary files
 	Workdir string `json:"workdir"`
 	// Refers to a directory. Optional.
@@ -142,6 +146,15 @@ type Config struct {
 	// Each line of the file should be: "64-bit-pc:32-bit-weight\n".
 	// eg. "0xffffffff81000000:0x10\n"
 	CovFilter covFilterCfg `json:"cover_filter,omitempty"`
+	// Directed fuzzing: corpus inputs are prioritized for mutation by their distance to the targets
+	// in the kernel call graph, e.g. to reach code paths of a patch or to fuzz newly changed code.
+	// Supported types of targets:
+	// "functions": kernel functions, support regular expression.
+	// eg. "functions": ["^tcp_sendmsg$", "^sctp_"].
+	// "lines": kernel source lines in the form "file:line", file is relative to kernel_src.
+	// eg. "lines": ["net/ipv4/tcp.c:1234"].
+	// Requires kernel_obj with debug info, the call graph is extracted only on amd64 and arm64.
+	Directed directedCfg `json:"directed,omitempty"`
 
 	// For each prog in the corpus, remember the raw array of PCs obtained from the kernel.
 	// It can be useful for debugging syzkaller descriptions and syzkaller itself.
@@ -169,6 +182,12 @@ type Config struct {
 	DisabledSyscalls []string `json:"disable_syscalls,omitempty"`
 	// List of syscalls that should not be mutated by the fuzzer (optional).
 	NoMutateSyscalls []string `json:"no_mutate_syscalls,omitempty"`
+	// How fuzzers choose mutation operators (default: "fixed"):
+	//  - "fixed": fixed odds;
+	//  - "bandit": each fuzzer learns the odds from the new signal found by programs
+	//    mutated with each operator (Thompson sampling).
+	// The new signal per operator is shown on the /mutations page in both cases.
+	MutationScheduler string `json:"mutation_scheduler,omitempty"`
 	// List of regexps for known bugs.
 	// Don't save reports matching these regexps, but reboot VM after them,
 	// matched against whole report output.
@@ -202,6 +221,21 @@ type Config struct {
 	// More details can be found in pkg/asset/config.go.
 	AssetStorage *asset.Config `json:"asset_storage"`
 
+	// SyzLLM inference server configuration (optional).
+	// If enabled, fuzzers ask the predictor which call to insert during mutation
+	// and the manager periodically reports corpus coverage to the server.
+	// A sample config:
+	// {
+	//    "enabled": true,
+	//    "predictor": "http",
+	//    "addr": "10.211.55.4:6678",
+	//    "timeout": 10000,
+	//    "insert_prob": 50,
+	//    "bundle": "/data/syzllm/bundle.json",
+	//    "disabled_calls": "propose"
+	// }
+	SyzLLM SyzLLM `json:"syzllm,omitempty"`
+
 	// Implementation details beyond this point. Filled after parsing.
 	Derived `json:"-"`
 }
@@ -211,8 +245,73 @@ type Subsystem struct {
 	Paths []string `json:"path"`
 }
 
+const (
+	SyzLLMPredictorHTTP  = "http"
+	SyzLLMPredictorNgram = "ngram"
+
+	SyzLLMDisabledReject         = "reject"
+	SyzLLMDisabledAllowSupported = "allow_if_supported"
+	SyzLLMDisabledPropose        = "propose"
+)
+
+const (
+	MutationSchedulerFixed  = "fixed"
+	MutationSchedulerBandit = "bandit"
+)
+
+type SyzLLM struct {
+	// Use SyzLLM predictions for call insertion (default: false).
+	Enabled bool `json:"enabled"`
+	// Predictor used to choose inserted calls (default: "http"):
+	//  - "http": query the SyzLLM server at addr;
+	//  - "ngram": in-process n-gram model built from the corpus and rebuilt as it grows, no server is needed.
+	Predictor string `json:"predictor,omitempty"`
+	// Address of the SyzLLM server in host:port form (required for the "http" predictor).
+	Addr string `json:"addr"`
+	// Timeout for a single request to the server in milliseconds (default: 10000).
+	Timeout int `json:"timeout,omitempty"`
+	// Percentage of call insertions that are delegated to SyzLLM (default: 100).
+	// The remaining insertions use the stock ChoiceTable-based insertCall.
+	InsertProb int `json:"insert_prob,omitempty"`
+	// Percentage of argument mutations that ask SyzLLM to fill a masked struct or union
+	// argument of a call (default: 0). The server must support sequences where [MASK]
+	// is inside a call, the "ngram" predictor doesn't support them.
+	ArgProb int `json:"arg_prob,omitempty"`
+	// Percentage of generated programs that are produced by SyzLLM call by call starting
+	// from [CLS] (default: 0). The remaining programs, and programs whose first call
+	// can't be predicted, are generated randomly.
+	GenerateProb int `json:"generate_prob,omitempty"`
+	// Bundle produced by syz-syzllm-prep along with the training data of the server
+	// (required for the "http" predictor). It must match the current syscall descriptions.
+	Bundle string `json:"bundle,omitempty"`
+	// What to do with predicted calls that are not enabled on the VM (default: "reject"):
+	//  - "reject": the prediction is dropped;
+	//  - "allow_if_supported": the call is inserted if it's enabled in the config and supported
+	//    by the machine (it may be missing on the VM due to corpus rotation), but it's not generated;
+	//  - "propose": the prediction is dropped and the call is reported to the manager,
+	//    supported calls that are proposed often enough are enabled on all VMs.
+	// Calls excluded by enable_syscalls/disable_syscalls or unsupported by the machine are never inserted.
+	DisabledCalls string `json:"disabled_calls,omitempty"`
+	// How much of the program is sent to the predictor (default: "flags"):
+	//  - "names": call names only;
+	//  - "flags": call names, flags and resources, other arguments are replaced with placeholders
+	//    that are filled back from the program for the predicted call;
+	//  - "full": all arguments.
+	// Must match the level the server was trained on.
+	Prompt string `json:"prompt,omitempty"`
+	// Predicted calls that don't match the descriptions (e.g. buffers of wrong sizes)
+	// are repaired before insertion. If set, such predictions are dropped instead
+	// and the needed repairs are logged (default: false).
+	StrictRepair bool `json:"strict_repair,omitempty"`
+}
+
 type covFilterCfg struct {
 	Files     []string `json:"files,omitempty"`
 	Functions []string `json:"functions,omitempty"`
 	RawPCs    []string `json:"pcs,omitempty"`
 }
+
+type directedCfg struct {
+	Functions []string `json:"functions,omitempty"`
+	Lines     []string `json:"lines,omitempty"`
+}
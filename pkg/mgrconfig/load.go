@@ -5,10 +5,12 @@ package mgrconfig
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/google/syzkaller/pkg/config"
@@ -86,6 +88,13 @@ func defaultValues() *Config {
 		MaxCrashLogs:   100,
 		Procs:          6,
 		PreserveCorpus: true,
+		SyzLLM: SyzLLM{
+			Predictor:     SyzLLMPredictorHTTP,
+			Timeout:       10000,
+			InsertProb:    100,
+			DisabledCalls: SyzLLMDisabledReject,
+			Prompt:        prog.PromptFlags.String(),
+		},
 	}
 }
 
@@ -190,10 +199,99 @@ func Complete(cfg *Config) error {
 			return err
 		}
 	}
+	switch cfg.MutationScheduler {
+	case "", MutationSchedulerFixed, MutationSchedulerBandit:
+	default:
+		return fmt.Errorf("unknown mutation_scheduler %q", cfg.MutationScheduler)
+	}
+	if err := cfg.SyzLLM.validate(); err != nil {
+		return err
+	}
+	if err := cfg.validateDirected(); err != nil {
+		return err
+	}
 	cfg.initTimeouts()
 	return nil
 }
 
+func (cfg *SyzLLM) validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	switch cfg.Predictor {
+	case SyzLLMPredictorHTTP:
+		if cfg.Addr == "" {
+			return fmt.Errorf("syzllm: addr must be set for the %q predictor", cfg.Predictor)
+		}
+		if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+			return fmt.Errorf("syzllm: bad addr %q: %w", cfg.Addr, err)
+		}
+		if cfg.Bundle == "" {
+			return fmt.Errorf("syzllm: bundle must be set for the %q predictor", cfg.Predictor)
+		}
+	case SyzLLMPredictorNgram:
+	default:
+		return fmt.Errorf("syzllm: unknown predictor %q", cfg.Predictor)
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("syzllm: timeout must be positive")
+	}
+	if cfg.InsertProb < 0 || cfg.InsertProb > 100 {
+		return fmt.Errorf("syzllm: insert_prob must be in [0, 100]")
+	}
+	if cfg.ArgProb < 0 || cfg.ArgProb > 100 {
+		return fmt.Errorf("syzllm: arg_prob must be in [0, 100]")
+	}
+	if cfg.GenerateProb < 0 || cfg.GenerateProb > 100 {
+		return fmt.Errorf("syzllm: generate_prob must be in [0, 100]")
+	}
+	switch cfg.DisabledCalls {
+	case SyzLLMDisabledReject, SyzLLMDisabledAllowSupported, SyzLLMDisabledPropose:
+	default:
+		return fmt.Errorf("syzllm: unknown disabled_calls policy %q", cfg.DisabledCalls)
+	}
+	if _, err := prog.ParsePromptLevel(cfg.Prompt); err != nil {
+		return fmt.Errorf("syzllm: %w", err)
+	}
+	return nil
+}
+
+func (cfg *Config) validateDirected() error {
+	if len(cfg.Directed.Functions)+len(cfg.Directed.Lines) == 0 {
+		return nil
+	}
+	if !cfg.Cover {
+		return fmt.Errorf("directed: requires cover")
+	}
+	if cfg.KernelObj == "" {
+		return fmt.Errorf("directed: requires kernel_obj")
+	}
+	for _, re := range cfg.Directed.Functions {
+		if _, err := regexp.Compile(re); err != nil {
+			return fmt.Errorf("directed: bad function %q: %w", re, err)
+		}
+	}
+	for _, line := range cfg.Directed.Lines {
+		if _, _, err := ParseDirectedLine(line); err != nil {
+			return fmt.Errorf("directed: %w", err)
+		}
+	}
+	return nil
+}
+
+// ParseDirectedLine parses a "file:line" target of directed fuzzing.
+func ParseDirectedLine(target string) (string, int, error) {
+	pos := strings.LastIndexByte(target, ':')
+	if pos <= 0 {
+		return "", 0, fmt.Errorf("bad line %q, want file:line", target)
+	}
+	line, err := strconv.Atoi(target[pos+1:])
+	if err != nil || line <= 0 {
+		return "", 0, fmt.Errorf("bad line %q, want file:line", target)
+	}
+	return target[:pos], line, nil
+}
+
 func (cfg *Config) initTimeouts() {
 	slowdown := 1
 	switch {
@@ -6,11 +6,14 @@
 package rpctype
 
 import (
+	"fmt"
 	"math"
+	"time"
 
 	"github.com/google/syzkaller/pkg/host"
 	"github.com/google/syzkaller/pkg/ipc"
 	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/prog"
 )
 
 type Input struct {
@@ -20,12 +23,52 @@ type Input struct {
 	Cover    []uint32
 	CallID   int // seq number of call in the prog to which the item is related (-1 for extra)
 	RawCover []uint32
+	SyzLLM   bool // the last mutation of the program inserted a SyzLLM-predicted call
+	// Power schedule counters of the input accumulated by all fuzzers.
+	Seed       SeedStats
+	Provenance Provenance
+	// Number of functions on the shortest call path from a function covered by the input
+	// to a directed fuzzing target (1 if it covers a target function), 0 if there is no such path
+	// or directed fuzzing is disabled. Computed by the manager.
+	Distance uint32
+}
+
+// Provenance says how the program that found an input was produced.
+type Provenance struct {
+	Origin string    // one of Origin* constants
+	Ops    []string  // mutation operators (prog.MutationOp) for OriginMutate/OriginSmash
+	Parent string    // hash of the corpus input the program was derived from, if any
+	Added  time.Time // when the manager added the input to the corpus
+}
+
+const (
+	OriginGenerate       = "generate"
+	OriginGenerateSyzLLM = "generate syzllm"
+	OriginMutate         = "mutate"
+	OriginSmash          = "smash"
+	OriginHint           = "hint"
+	OriginMinimize       = "minimize" // new signal noticed while minimizing another input
+	OriginSeed           = "seed"     // programs from sys/OS/test
+	OriginHub            = "hub"
+	OriginCorpus         = "corpus" // corpus inputs saved before provenance was recorded
+)
+
+// SeedStats are the counters the fuzzer power schedule uses to assign energy to a corpus input.
+type SeedStats struct {
+	Mutations uint64 // number of executed mutants of the input
+	NewSignal uint64 // number of those mutants that gave new signal
+}
+
+func (stats *SeedStats) Add(other SeedStats) {
+	stats.Mutations += other.Mutations
+	stats.NewSignal += other.NewSignal
 }
 
 type Candidate struct {
-	Prog      []byte
-	Minimized bool
-	Smashed   bool
+	Prog       []byte
+	Minimized  bool
+	Smashed    bool
+	Provenance Provenance // passed to inputs found by the candidate
 }
 
 type ExecTask struct {
@@ -49,6 +92,33 @@ type ConnectRes struct {
 	MemoryLeakFrames  []string
 	DataRaceFrames    []string
 	CoverFilterBitmap []byte
+	SyzLLM            SyzLLMConfig
+	// Learn the odds of mutation operators, see mgrconfig.Config.MutationScheduler.
+	AdaptiveMutations bool
+}
+
+// SyzLLMConfig describes the call predictor fuzzers should use.
+// Empty Predictor means that LLM-guided mutation is disabled.
+type SyzLLMConfig struct {
+	Predictor  string // "http" or "ngram", see mgrconfig.SyzLLM
+	Addr       string
+	Timeout    time.Duration
+	InsertProb int                // percentage of call insertions delegated to SyzLLM
+	ArgProb    int                // percentage of argument mutations delegated to SyzLLM
+	Bundle     *prog.SyzLLMBundle // vocabulary and address table, nil for the "ngram" predictor
+	// Percentage of generated programs produced by SyzLLM.
+	GenerateProb int
+	// Policy for predicted calls that are not enabled on the VM, see mgrconfig.SyzLLM.DisabledCalls.
+	DisabledCalls string
+	// Prompt level, see mgrconfig.SyzLLM.Prompt.
+	Prompt string
+	// Drop predictions that need repairs, see mgrconfig.SyzLLM.StrictRepair.
+	StrictRepair bool
+	// Calls enabled in the manager config and supported by the machine,
+	// nil if the machine check is not done yet (then the VM has all of them enabled).
+	SupportedCalls []int
+	// Calls enabled on all VMs by the "propose" policy so far.
+	AcceptedCalls []int
 }
 
 type CheckArgs struct {
@@ -70,17 +140,68 @@ type NewInputArgs struct {
 	Input
 }
 
+type NewInputRes struct {
+	// Counters of the input saved by the manager (e.g. before a restart).
+	Seed     SeedStats
+	Distance uint32 // see Input.Distance
+}
+
 type PollArgs struct {
 	Name           string
 	NeedCandidates bool
 	MaxSignal      signal.Serial
 	Stats          map[string]uint64
+	// Predicted calls rejected since the last poll under the "propose" SyzLLM policy (call ID -> count).
+	SyzLLMProposals map[int]uint64
+	// The fuzzer considers the SyzLLM server down and mutates without it.
+	SyzLLMDown bool
+	// Current weights of mutation operators (prog.MutationOp -> per mille), see prog.OpScheduler.Weights.
+	MutationWeights map[string]uint64
+	// Power schedule counters accumulated since the last poll (input hash -> counters).
+	SeedStats map[string]SeedStats
+}
+
+// Prefixes of mutation operator stats reported by fuzzers in PollArgs.Stats, see prog.OpStat.
+const (
+	MutationTriesStat  = "mutation: "            // followed by prog.MutationOp
+	MutationSignalStat = "mutation new signal: " // followed by prog.MutationOp
+)
+
+// Prefixes of SyzLLM stats reported by fuzzers in PollArgs.Stats.
+const (
+	SyzLLMResultStat  = "syzllm: "         // followed by prog.PredictionResult
+	SyzLLMLatencyStat = "syzllm latency: " // followed by SyzLLMLatencyBucket
+	SyzLLMCallStat    = "syzllm call: "    // followed by the name of the inserted syscall
+)
+
+// SyzLLMServerDownStat counts how many times fuzzers considered the SyzLLM server down.
+const SyzLLMServerDownStat = "syzllm server down"
+
+// SyzLLMLatencyBuckets are upper bounds of the SyzLLM request latency histogram.
+var SyzLLMLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// SyzLLMLatencyBucket returns the name of the i-th bucket of the latency histogram,
+// i == len(SyzLLMLatencyBuckets) is the bucket for all larger latencies.
+func SyzLLMLatencyBucket(i int) string {
+	if i == len(SyzLLMLatencyBuckets) {
+		return fmt.Sprintf(">%v", SyzLLMLatencyBuckets[i-1])
+	}
+	return fmt.Sprintf("<=%v", SyzLLMLatencyBuckets[i])
 }
 
 type PollRes struct {
 	Candidates []Candidate
 	NewInputs  []Input
 	MaxSignal  signal.Serial
+	// Calls enabled on all VMs by the "propose" SyzLLM policy, the list only grows.
+	SyzLLMCalls []int
 }
 
 type RunnerConnectArgs struct {
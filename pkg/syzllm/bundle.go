@@ -0,0 +1,40 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+)
+
+// BundleFile is the name of the bundle in the preprocessing output directory.
+const BundleFile = "bundle.json"
+
+func WriteBundle(file string, bundle *prog.SyzLLMBundle) error {
+	data, err := json.MarshalIndent(bundle, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SyzLLM bundle: %w", err)
+	}
+	return osutil.WriteFile(file, data)
+}
+
+// ReadBundle reads the bundle from file and checks that it matches the target.
+func ReadBundle(file string, target *prog.Target) (*prog.SyzLLMBundle, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SyzLLM bundle: %w", err)
+	}
+	bundle := new(prog.SyzLLMBundle)
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse SyzLLM bundle %v: %w", file, err)
+	}
+	if err := target.CheckSyzLLMBundle(bundle); err != nil {
+		return nil, fmt.Errorf("%v: %w", file, err)
+	}
+	return bundle, nil
+}
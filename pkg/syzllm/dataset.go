@@ -0,0 +1,95 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/prog"
+)
+
+// DatasetRecord is a corpus input exported as a line of a JSONL training dataset.
+// Unlike the token files written by ParseCorpusToFile, records keep the coverage the input
+// achieved, so that training can weight sequences by it.
+type DatasetRecord struct {
+	Sig     string
+	Call    string // the syscall the input was added to the corpus for
+	Calls   []DatasetCall
+	Signal  int      // signal of the input
+	Cover   int      // PCs covered by the input
+	Origin  string   // how the input was found, one of rpctype.Origin* constants
+	Ops     []string `json:",omitempty"` // mutation operators that produced the input, see rpctype.Provenance
+	Crashes []string // titles of crashes whose logs contain the program
+}
+
+// DatasetCall is a single call of an exported input.
+type DatasetCall struct {
+	Call       string // serialized call
+	Normalized string // the call as the predictor sees it, see prog.NormalizeProg
+	Signal     int    // signal of the call, if the input was added to the corpus for it
+	Cover      int    // PCs covered by the call, if the manager has its raw coverage
+}
+
+// DatasetInput is what the manager knows about a corpus input.
+type DatasetInput struct {
+	Sig        string
+	Prog       []byte
+	Call       string
+	Signal     int
+	Cover      int
+	CallSignal map[int]int // call index -> signal of the call, for the calls the input was added for
+	CallCover  map[int]int // call index -> PCs in the raw coverage of the call
+	Origin     string
+	Ops        []string
+}
+
+// NewDatasetRecord converts a corpus input into a dataset record.
+func NewDatasetRecord(target *prog.Target, inp *DatasetInput, level prog.PromptLevel,
+	crashes []string) (*DatasetRecord, error) {
+	p, err := target.Deserialize(inp.Prog, prog.NonStrict)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize input %v: %w", inp.Sig, err)
+	}
+	calls := strings.Split(strings.TrimSuffix(string(p.Serialize()), "\n"), "\n")
+	norm := prog.NormalizeProg(p, level)
+	if len(p.Calls) == 0 || len(calls) != len(p.Calls) || len(norm.Calls) != len(p.Calls) {
+		return nil, fmt.Errorf("input %v: failed to split %v calls", inp.Sig, len(p.Calls))
+	}
+	rec := &DatasetRecord{
+		Sig:     inp.Sig,
+		Call:    inp.Call,
+		Signal:  inp.Signal,
+		Cover:   inp.Cover,
+		Origin:  inp.Origin,
+		Ops:     inp.Ops,
+		Crashes: crashes,
+	}
+	for i := range p.Calls {
+		rec.Calls = append(rec.Calls, DatasetCall{
+			Call:       calls[i],
+			Normalized: norm.Calls[i],
+			Signal:     inp.CallSignal[i],
+			Cover:      inp.CallCover[i],
+		})
+	}
+	return rec, nil
+}
+
+// CrashPrograms maps hashes of programs (the same as corpus input signatures)
+// to titles of crashes whose logs contain them.
+type CrashPrograms map[string][]string
+
+// AddLog notes all programs of the crash log.
+func (cp CrashPrograms) AddLog(target *prog.Target, title string, log []byte) {
+	for _, ent := range target.ParseLog(log) {
+		sig := hash.String(ent.P.Serialize())
+		titles := cp[sig]
+		if len(titles) != 0 && titles[len(titles)-1] == title {
+			continue
+		}
+		cp[sig] = append(titles, title)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestDatasetRecord(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const text = "mutate8(0x1)\nmutate0()\n"
+	sig := hash.String([]byte(text))
+	crashes := make(CrashPrograms)
+	crashLog := "executing program 0:\nmutate0()\n\nexecuting program 1:\n" + text
+	crashes.AddLog(target, "crash 1", []byte(crashLog))
+	crashes.AddLog(target, "crash 2", []byte(crashLog+"executing program 1:\n"+text))
+	inp := &DatasetInput{
+		Sig:        sig,
+		Prog:       []byte(text),
+		Call:       "mutate0",
+		Signal:     10,
+		Cover:      7,
+		CallSignal: map[int]int{1: 6, -1: 4},
+		CallCover:  map[int]int{0: 3, 1: 5, -1: 2},
+		Origin:     "mutate",
+		Ops:        []string{"insert call syzllm"},
+	}
+	rec, err := NewDatasetRecord(target, inp, prog.PromptFlags, crashes[sig])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DatasetRecord{
+		Sig:  sig,
+		Call: "mutate0",
+		Calls: []DatasetCall{
+			{Call: "mutate8(0x1)", Normalized: "mutate8(0x111)", Cover: 3},
+			{Call: "mutate0()", Normalized: "mutate0()", Signal: 6, Cover: 5},
+		},
+		Signal:  10,
+		Cover:   7,
+		Origin:  "mutate",
+		Ops:     []string{"insert call syzllm"},
+		Crashes: []string{"crash 1", "crash 2"},
+	}
+	if diff := cmp.Diff(want, rec); diff != "" {
+		t.Fatal(diff)
+	}
+	if _, err := NewDatasetRecord(target, &DatasetInput{Prog: []byte("foo()\n")}, prog.PromptFlags, nil); err == nil {
+		t.Fatalf("no error for a broken input")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/prog"
+)
+
+const evalBatchSize = 32
+
+// Evaluator measures prediction quality of a predictor on held-out programs:
+// a random call of each program is masked and the predictor is asked for it.
+type Evaluator struct {
+	target *prog.Target
+	pred   prog.CallPredictor
+	k      int
+	level  prog.PromptLevel
+	rnd    *rand.Rand
+	stats  EvalStats
+}
+
+// EvalStats are counters collected by Evaluator.
+// Name matches are counted per masked call, decoding stages are counted per candidate.
+type EvalStats struct {
+	K          int
+	Programs   int // programs with a masked call
+	Broken     int // programs that failed to deserialize
+	Failed     int // masked calls the predictor failed to answer
+	Top1       int // the first candidate is the masked call
+	TopK       int // one of the first K candidates is the masked call
+	Top1Base   int // same as Top1, but the variant is ignored (e.g. socket$inet == socket$inet6)
+	TopKBase   int
+	Candidates int // candidates returned (only the first K are counted)
+	// Candidates that have well-formed resource tags, deserialize, and produce a valid program
+	// after insertion into the masked position. Each stage implies the previous ones.
+	Resources    int
+	Deserialized int
+	Valid        int
+}
+
+func NewEvaluator(target *prog.Target, pred prog.CallPredictor, k int, level prog.PromptLevel,
+	seed int64) *Evaluator {
+	return &Evaluator{
+		target: target,
+		pred:   pred,
+		k:      k,
+		level:  level,
+		rnd:    rand.New(rand.NewSource(seed)),
+		stats:  EvalStats{K: k},
+	}
+}
+
+// Process evaluates the predictor on all programs of the corpus.
+func (ev *Evaluator) Process(corpusDB *db.DB) {
+	// Sort for reproducibility of the masked positions.
+	keys := make([]string, 0, len(corpusDB.Records))
+	for key := range corpusDB.Records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var batch []*prog.HeldOutCall
+	for _, key := range keys {
+		p, err := ev.target.Deserialize(corpusDB.Records[key].Val, prog.NonStrict)
+		if err != nil {
+			ev.stats.Broken++
+			continue
+		}
+		if len(p.Calls) == 0 {
+			continue
+		}
+		batch = append(batch, p.HoldOutCall(ev.rnd.Intn(len(p.Calls)), ev.level))
+		if len(batch) == evalBatchSize {
+			ev.processBatch(batch)
+			batch = nil
+		}
+	}
+	if len(batch) != 0 {
+		ev.processBatch(batch)
+	}
+}
+
+func (ev *Evaluator) processBatch(batch []*prog.HeldOutCall) {
+	res, err := ev.predict(batch)
+	if err != nil {
+		log.Logf(0, "prediction of %v programs failed: %v", len(batch), err)
+		ev.stats.Programs += len(batch)
+		ev.stats.Failed += len(batch)
+		return
+	}
+	for i, held := range batch {
+		ev.stats.Programs++
+		if res[i] == nil {
+			ev.stats.Failed++
+			continue
+		}
+		ev.evaluate(held, res[i])
+	}
+}
+
+func (ev *Evaluator) predict(batch []*prog.HeldOutCall) ([][]prog.Prediction, error) {
+	seqs := make([][]string, len(batch))
+	for i, held := range batch {
+		seqs[i] = held.Prompt
+	}
+	if pred, ok := ev.pred.(prog.BatchCallPredictor); ok {
+		return pred.PredictBatch(seqs)
+	}
+	res := make([][]prog.Prediction, len(seqs))
+	for i, seq := range seqs {
+		var err error
+		if res[i], err = ev.pred.Predict(seq); err != nil {
+			log.Logf(1, "prediction failed: %v", err)
+		}
+	}
+	return res, nil
+}
+
+func (ev *Evaluator) evaluate(held *prog.HeldOutCall, predictions []prog.Prediction) {
+	if len(predictions) > ev.k {
+		predictions = predictions[:ev.k]
+	}
+	var variantK, baseK bool
+	for i, pred := range predictions {
+		variant, base := held.NameMatch(pred.Call)
+		if i == 0 {
+			ev.stats.Top1 += b2i(variant)
+			ev.stats.Top1Base += b2i(base)
+		}
+		variantK = variantK || variant
+		baseK = baseK || base
+		check := held.Check(pred.Call, rand.NewSource(ev.rnd.Int63()))
+		ev.stats.Candidates++
+		ev.stats.Resources += b2i(check.Resources)
+		ev.stats.Deserialized += b2i(check.Deserialized)
+		ev.stats.Valid += b2i(check.Valid)
+		if check.Err != nil {
+			log.Logf(2, "%v: %v", pred.Call, check.Err)
+		}
+	}
+	ev.stats.TopK += b2i(variantK)
+	ev.stats.TopKBase += b2i(baseK)
+}
+
+func (ev *Evaluator) Stats() EvalStats {
+	return ev.stats
+}
+
+func (st EvalStats) String() string {
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "programs: %v (broken: %v, failed predictions: %v)\n", st.Programs, st.Broken, st.Failed)
+	answered := st.Programs - st.Failed
+	fmt.Fprintf(buf, "top-1 accuracy: %v variant, %v base\n",
+		percent(st.Top1, answered), percent(st.Top1Base, answered))
+	fmt.Fprintf(buf, "top-%v accuracy: %v variant, %v base\n", st.K,
+		percent(st.TopK, answered), percent(st.TopKBase, answered))
+	fmt.Fprintf(buf, "candidates: %v\n", st.Candidates)
+	fmt.Fprintf(buf, "well-formed resources: %v\n", percent(st.Resources, st.Candidates))
+	fmt.Fprintf(buf, "deserialized: %v\n", percent(st.Deserialized, st.Candidates))
+	fmt.Fprintf(buf, "valid: %v\n", percent(st.Valid, st.Candidates))
+	return buf.String()
+}
+
+func percent(v, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%v)", float64(v)*100/float64(total), v)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
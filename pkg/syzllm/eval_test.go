@@ -0,0 +1,57 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestEvaluator(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpusDB, err := db.Open(filepath.Join(t.TempDir(), "corpus.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []string{"mutate0()\n", "mutate1()\n", "not_a_call()\n"} {
+		corpusDB.Save(string(rune('a'+i)), []byte(p), 0)
+	}
+	pred := &prog.FakePredictor{Predictions: []prog.Prediction{
+		{Call: "mutate0()"},
+		{Call: "mutate1$foo()"},
+		{Call: "mutate0(@RSTART@mutate5("},
+		{Call: "mutate2()"},
+	}}
+	ev := NewEvaluator(target, pred, 3, prog.PromptFlags, 0)
+	ev.Process(corpusDB)
+	want := EvalStats{
+		K:            3,
+		Programs:     2,
+		Broken:       1,
+		Top1:         1,
+		TopK:         1,
+		Top1Base:     1,
+		TopKBase:     2,
+		Candidates:   6,
+		Resources:    4,
+		Deserialized: 4,
+		Valid:        4,
+	}
+	if got := ev.Stats(); got != want {
+		t.Fatalf("got stats:\n%+v\nwant:\n%+v", got, want)
+	}
+	for _, req := range pred.Requests() {
+		if len(req) != 1 || req[0] != prog.MASK {
+			t.Fatalf("bad request %q", req)
+		}
+	}
+}
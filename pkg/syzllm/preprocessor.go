@@ -1,102 +1,150 @@
-package main
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package syzllm prepares corpus programs for training of the SyzLLM model.
+package syzllm
 
 import (
 	"fmt"
-	"github.com/google/syzkaller/pkg/db"
-	"github.com/google/syzkaller/pkg/log"
-	proglib "github.com/google/syzkaller/prog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	proglib "github.com/google/syzkaller/prog"
 )
 
-const OldCorpusPath = "./syz-manager/data/"
-const TokensFilePath = "./syz-manager/data/tokens/tokens_"
 const TokenFileSize = 256
-const VocabFilePath = "./syz-manager/data/vocab/vocab.txt"
 
-func PreprocessAllCorpora(manager *Manager, preprocessorType PreprocessorType) {
-	logCollector := NewLogCollector()
-	// Walk through the directory
-	err := filepath.Walk(OldCorpusPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// Session preprocesses a set of corpora into a single output directory.
+// The output directory gets the following files:
+//   - tokens/tokens_N.txt with up to TokenFileSize programs each, programs are terminated with [SEP];
+//   - vocab/vocab.txt with all programs;
+//   - addr.txt with base addresses of calls (AllocateConstant only);
+//   - bundle.json with the vocabulary and addresses for fuzzing, see prog.SyzLLMBundle.
+type Session struct {
+	target       *proglib.Target
+	typ          PreprocessorType
+	outDir       string
+	tokenFileCnt int
+	dedup        *Deduplicator
+	addrs        *AddrGenerator
+	args         *ArgTable
+	vocab        []string
+	vocabSet     map[string]bool
+	logCollector *LogCollector
+}
+
+func NewSession(target *proglib.Target, typ PreprocessorType, outDir string) (*Session, error) {
+	for _, dir := range []string{"tokens", "vocab"} {
+		if err := os.MkdirAll(filepath.Join(outDir, dir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output dir: %w", err)
 		}
-		// Check if the file is a corpus
-		if !info.IsDir() && strings.Contains(path, "corpus") {
-			corpusDB, err := db.Open(path, true)
-			if err != nil {
-				if corpusDB == nil {
-					log.Fatalf("failed to open corpus database: %v", err)
-				}
-				log.Errorf("read %v inputs from corpus and got error: %v", len(corpusDB.Records), err)
-			}
-			newLogCollector := NewPreprocessor(manager, corpusDB, preprocessorType).Preprocessing()
-			logCollector.MergeCnt(newLogCollector)
-		}
-		return nil
-	})
-
-	if preprocessorType == AllocateConstant {
-		SaveAddr()
 	}
+	s := &Session{
+		target:       target,
+		typ:          typ,
+		outDir:       outDir,
+		dedup:        &Deduplicator{Set: make(map[interface{}]struct{})},
+		addrs:        &AddrGenerator{addrCounter: make(map[string]uint64), addrBase: make(map[string]uint64)},
+		args:         &ArgTable{argTable: make(map[string][][]proglib.Arg)},
+		vocabSet:     make(map[string]bool),
+		logCollector: NewLogCollector(),
+	}
+	return s, nil
+}
 
+// Process preprocesses all programs of the corpus database and writes them to the output.
+func (s *Session) Process(corpusDB *db.DB) error {
+	newLogCollector, err := s.newPreprocessor(corpusDB).Preprocessing()
 	if err != nil {
-		fmt.Println("filepath.Walk Error:", err)
+		return err
 	}
-	log.Fatalf("preprocessing exit!\n"+
-		"programs cnt: %v \n"+"calls cnt: %v \n"+"replaced args cnt: %v \n"+"panic cnt: %v \n"+"max sequence length: %v \n"+"broken progs: %v\n"+"Any: %v\n", logCollector.TotalProgramsCnt, logCollector.TotalCallsCnt, logCollector.TotalReplacedArgsCnt, logCollector.TotalPanicCnt, logCollector.MaxProgramLength, logCollector.BrokenProgCNT, logCollector.Cnt)
+	s.logCollector.MergeCnt(newLogCollector)
+	return nil
+}
+
+// Finish writes the files that depend on all processed corpora and returns the summary.
+func (s *Session) Finish() (*LogCollector, error) {
+	if s.typ == AllocateConstant {
+		if err := s.SaveAddr(); err != nil {
+			return nil, err
+		}
+	}
+	bundle := s.target.NewSyzLLMBundle()
+	bundle.Vocab = s.vocab
+	for call, addr := range s.addrs.addrBase {
+		bundle.AddrBase[call] = addr
+	}
+	if err := WriteBundle(filepath.Join(s.outDir, BundleFile), bundle); err != nil {
+		return nil, err
+	}
+	return s.logCollector, nil
 }
 
 type Preprocessor interface {
-	Preprocessing() *LogCollector
+	Preprocessing() (*LogCollector, error)
 }
 
 type PreprocessorBase struct {
+	*Session
 	corpusDB        *db.DB
 	recordsCopy     map[string]db.Record // filter broken programs
-	mgr             *Manager
 	logCollector    *LogCollector
 	currentCallName string
 	currentProg     string
 }
 
-func (p *PreprocessorBase) ParseCorpusToFile() {
-	CreatePathIfNotExist("./syz-manager/data/tokens/")
-	CreatePathIfNotExist("./syz-manager/data/vocab/")
+func (p *PreprocessorBase) ParseCorpusToFile() error {
 	buffer := ""
 	programCount := 0
 	for _, rec := range p.corpusDB.Records {
-		if IsDuplication(string(rec.Val[:])) {
+		if p.IsDuplication(string(rec.Val[:])) {
 			continue
 		}
-		//buffer += string(rec.Val[:]) + "[SEP]\n"
-		buffer += ConvertResource(rec.Val[:]) + "[SEP]\n"
+		buffer += ConvertResource(rec.Val[:]) + proglib.SEP + "\n"
 		programCount += 1
 		p.logCollector.TotalProgramsCnt += 1
 
 		if programCount >= TokenFileSize {
-			buffer = ConvertAnyBlob(buffer)
-			SaveTokensFile(&buffer)
-			SaveVocabFile(&buffer)
-
-			tokenFileCnt += 1
+			if err := p.saveBuffer(buffer); err != nil {
+				return err
+			}
 			programCount = 0
 			buffer = ""
 		}
 	}
-	buffer += "[UNK]\n"
-	buffer += "[MASK]\n"
-	buffer += "[CLS]\n"
+	buffer += proglib.UNK + "\n"
+	buffer += proglib.MASK + "\n"
+	buffer += proglib.CLS + "\n"
 	buffer += "[PAD]\n"
-	buffer = ConvertAnyBlob(buffer)
-	SaveTokensFile(&buffer)
-	SaveVocabFile(&buffer)
+	if err := p.saveBuffer(buffer); err != nil {
+		return err
+	}
 
 	log.Logf(0, "total number of sequences (traces): %v", p.logCollector.TotalProgramsCnt)
+	return nil
+}
+
+func (p *PreprocessorBase) saveBuffer(buffer string) error {
+	buffer = ConvertAnyBlob(buffer)
+	if err := p.SaveTokensFile(buffer); err != nil {
+		return err
+	}
+	if err := p.SaveVocabFile(buffer); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(buffer, "\n") {
+		if line != "" && !p.vocabSet[line] {
+			p.vocabSet[line] = true
+			p.vocab = append(p.vocab, line)
+		}
+	}
+	p.tokenFileCnt += 1
+	return nil
 }
 
 func (p *PreprocessorBase) MakeRecord(program *proglib.Prog, rec db.Record) db.Record {
@@ -112,19 +160,17 @@ func (p *PreprocessorBase) MakeRecord(program *proglib.Prog, rec db.Record) db.R
 	return newRecord
 }
 
-func NewPreprocessor(manager *Manager, corpusDB *db.DB, preprocessorType PreprocessorType) Preprocessor {
+func (s *Session) newPreprocessor(corpusDB *db.DB) Preprocessor {
 	pBase := new(PreprocessorBase)
+	pBase.Session = s
 	pBase.corpusDB = corpusDB
 	pBase.recordsCopy = make(map[string]db.Record)
 	for k, v := range corpusDB.Records {
 		pBase.recordsCopy[k] = v
 	}
-	pBase.mgr = manager
 	pBase.logCollector = NewLogCollector()
 
-	switch preprocessorType {
-	case AllocateConstant:
-		return &PAllocateConstant{pBase}
+	switch s.typ {
 	case Brutal:
 		return &PBrutal{pBase}
 	default:
@@ -139,21 +185,33 @@ const (
 	Brutal
 )
 
+// ParsePreprocessorType converts the preprocessor name ("constant" or "brutal") to PreprocessorType.
+func ParsePreprocessorType(name string) (PreprocessorType, error) {
+	switch name {
+	case "constant":
+		return AllocateConstant, nil
+	case "brutal":
+		return Brutal, nil
+	}
+	return 0, fmt.Errorf("unknown preprocessor %q", name)
+}
+
 type PAllocateConstant struct {
 	*PreprocessorBase
 }
 
-func (p *PAllocateConstant) Preprocessing() *LogCollector {
-	//preprocessor.ReplaceArgs()
+func (p *PAllocateConstant) Preprocessing() (*LogCollector, error) {
 	p.Replace()
-	p.ParseCorpusToFile()
+	if err := p.ParseCorpusToFile(); err != nil {
+		return nil, err
+	}
 	log.Logf(0, "preprocessing done! Replaced Args Count: %v, Panic Count: %v", p.logCollector.TotalReplacedArgsCnt, p.logCollector.TotalPanicCnt)
-	return p.logCollector
+	return p.logCollector, nil
 }
 
 func (p *PAllocateConstant) Replace() {
 	for x, rec := range p.corpusDB.Records {
-		program, err := p.mgr.target.Deserialize(rec.Val[:], proglib.NonStrict)
+		program, err := p.target.Deserialize(rec.Val[:], proglib.NonStrict)
 		if err != nil {
 			if program == nil {
 				//log.Logf(0, "prog:\n %v\n", string(rec.Val[:]))
@@ -168,7 +226,7 @@ func (p *PAllocateConstant) Replace() {
 	p.corpusDB.Records = p.recordsCopy
 
 	for x, rec := range p.corpusDB.Records {
-		program, err := p.mgr.target.Deserialize(rec.Val[:], proglib.NonStrict)
+		program, err := p.target.Deserialize(rec.Val[:], proglib.NonStrict)
 		if err != nil {
 			if program == nil {
 				log.Fatalf("prog:\n %v\n", string(rec.Val[:]))
@@ -179,7 +237,7 @@ func (p *PAllocateConstant) Replace() {
 		p.logCollector.CalcMaxProgramLength(len(program.Calls))
 		p.currentProg = string(rec.Val[:])
 
-		GetAddrGeneratorInstance().ResetCounter()
+		p.addrs.ResetCounter()
 
 		for i, call := range program.Calls {
 			p.logCollector.TotalCallsCnt += 1
@@ -314,7 +372,7 @@ func (p *PAllocateConstant) GenerateGroupArg(groupArg *proglib.GroupArg, fieldTy
 }
 
 func (p *PAllocateConstant) GeneratePtrArg(ptrArg *proglib.PointerArg, fieldType proglib.Type) proglib.Arg {
-	ptrArg.Address = GetAddr(p.currentCallName)
+	ptrArg.Address = p.addrs.GetAddr(p.currentCallName)
 
 	switch ft := fieldType.(type) {
 	case *proglib.PtrType:
@@ -335,16 +393,6 @@ func (p *PAllocateConstant) GeneratePtrArg(ptrArg *proglib.PointerArg, fieldType
 	return ptrArg
 }
 
-var _addrGeneratorInstance *AddrGenerator
-var _addrGeneratorOnce sync.Once
-
-func GetAddrGeneratorInstance() *AddrGenerator {
-	_addrGeneratorOnce.Do(func() {
-		_addrGeneratorInstance = &AddrGenerator{addrCounter: make(map[string]uint64), addrBase: make(map[string]uint64)}
-	})
-	return _addrGeneratorInstance
-}
-
 type AddrGenerator struct {
 	addrCounter map[string]uint64
 	addrBase    map[string]uint64
@@ -358,8 +406,7 @@ const AddrDiffCallStep = AddrSameCallStep * 4
 // check two strategies, current is 2:
 // 1. for all the addrs in each program, mapping them to symbols in order
 // 2. for addrs in each call, mapping...
-func GetAddr(callName string) uint64 {
-	addrGenerator := GetAddrGeneratorInstance()
+func (addrGenerator *AddrGenerator) GetAddr(callName string) uint64 {
 	cnt, ok := addrGenerator.addrCounter[callName]
 	if !ok {
 		addrGenerator.addrCounter[callName] = 0
@@ -377,28 +424,24 @@ func (a *AddrGenerator) ResetCounter() {
 	}
 }
 
-func SaveAddr() {
-	const AddrPath = "./syz-manager/data/addr.txt"
-	file, err := os.Create(AddrPath)
+func (s *Session) SaveAddr() error {
+	file, err := os.Create(filepath.Join(s.outDir, "addr.txt"))
 	if err != nil {
-		fmt.Println("Error creating addr.txt:", err)
-		return
+		return fmt.Errorf("failed to create addr.txt: %w", err)
 	}
 	defer file.Close()
 
 	cnt := 0
-	addrGenerator := GetAddrGeneratorInstance()
-	for key, value := range addrGenerator.addrBase {
+	for key, value := range s.addrs.addrBase {
 		cnt += 1
 		line := fmt.Sprintf("%s %d\n", key, value)
-		_, err := file.WriteString(line)
-		if err != nil {
-			fmt.Println("Error writing to addr.txt:", err)
-			return
+		if _, err := file.WriteString(line); err != nil {
+			return fmt.Errorf("failed to write addr.txt: %w", err)
 		}
 	}
 
 	log.Logf(0, "addr.txt saved %v", cnt)
+	return nil
 }
 
 func ParseCallsText(prog []byte) []string {
@@ -423,17 +466,18 @@ type PBrutal struct {
 	*PreprocessorBase
 }
 
-func (p *PBrutal) Preprocessing() *LogCollector {
-	//preprocessor.ReplaceArgs()
+func (p *PBrutal) Preprocessing() (*LogCollector, error) {
 	p.Replace()
-	p.ParseCorpusToFile()
+	if err := p.ParseCorpusToFile(); err != nil {
+		return nil, err
+	}
 	log.Logf(0, "preprocessing done! Replaced Args Count: %v, Panic Count: %v", p.logCollector.TotalReplacedArgsCnt, p.logCollector.TotalPanicCnt)
-	return p.logCollector
+	return p.logCollector, nil
 }
 
 func (p *PBrutal) Replace() {
 	for x, rec := range p.corpusDB.Records {
-		program, err := p.mgr.target.Deserialize(rec.Val[:], proglib.NonStrict)
+		program, err := p.target.Deserialize(rec.Val[:], proglib.NonStrict)
 		if err != nil {
 			if program == nil {
 				continue
@@ -460,7 +504,7 @@ func (p *PBrutal) Replace() {
 }
 
 func (p *PBrutal) SearchForArg(callName string, idx int, arg proglib.Arg, callArgSize int) proglib.Arg {
-	argTableInstance := GetArgTableInstance()
+	argTableInstance := p.args
 	argList, exist := argTableInstance.argTable[callName]
 	if !exist {
 		argList = make([][]proglib.Arg, callArgSize)
@@ -540,78 +584,33 @@ func UnionArgDFSContainResArg(arg *proglib.UnionArg) bool {
 	return false
 }
 
-var tokenFileCnt int = 0
-
-func CreatePathIfNotExist(path string) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err := os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			fmt.Println("Failed to create folder:", err)
-			return
-		}
-		fmt.Println("Folder created successfully!")
-	} else {
-		fmt.Println("Folder already exists!")
+func (s *Session) SaveTokensFile(content string) error {
+	name := filepath.Join(s.outDir, "tokens", "tokens_"+strconv.Itoa(s.tokenFileCnt)+".txt")
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write tokens: %w", err)
 	}
+	return nil
 }
 
-func SaveTokensFile(content *string) {
-	f, err := os.Create(TokensFilePath + strconv.Itoa(tokenFileCnt) + ".txt")
+func (s *Session) SaveVocabFile(content string) error {
+	f, err := os.OpenFile(filepath.Join(s.outDir, "vocab", "vocab.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Fatalf("open token error :", err)
-		return
+		return fmt.Errorf("failed to open vocab: %w", err)
 	}
-
-	_, err = f.Write([]byte(*content))
-	if err != nil {
-		log.Fatalf("write token error: ", err)
-		return
+	if _, err := f.Write([]byte(content)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write vocab: %w", err)
 	}
-
-	err = f.Close()
-	if err != nil {
-		log.Fatalf("Close token error: ", err)
-		return
-	}
-}
-
-func SaveVocabFile(content *string) {
-	f, err := os.OpenFile(VocabFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("open token error :", err)
-		return
-	}
-
-	_, err = f.Write([]byte(*content))
-	if err != nil {
-		log.Fatalf("write token error: ", err)
-		return
-	}
-
-	err = f.Close()
-	if err != nil {
-		log.Fatalf("Close token error: ", err)
-		return
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close vocab: %w", err)
 	}
+	return nil
 }
 
 type ArgTable struct {
 	argTable map[string][][]proglib.Arg
 }
 
-var _argTableInstance *ArgTable
-var argTableOnce sync.Once
-
-// GetInstance returns the singleton instance
-func GetArgTableInstance() *ArgTable {
-	argTableOnce.Do(func() {
-		_argTableInstance = &ArgTable{
-			argTable: make(map[string][][]proglib.Arg),
-		}
-	})
-	return _argTableInstance
-}
-
 type LogCollector struct {
 	TotalProgramsCnt     int
 	TotalCallsCnt        int
@@ -645,6 +644,18 @@ func (logCollector *LogCollector) MergeCnt(newLogCollector *LogCollector) {
 	logCollector.MaxProgramLength = logCollector.CalcMaxProgramLength(newLogCollector.MaxProgramLength)
 }
 
+func (logCollector *LogCollector) String() string {
+	return fmt.Sprintf("programs cnt: %v\n"+
+		"calls cnt: %v\n"+
+		"replaced args cnt: %v\n"+
+		"panic cnt: %v\n"+
+		"max sequence length: %v\n"+
+		"broken progs: %v\n"+
+		"Any: %v\n",
+		logCollector.TotalProgramsCnt, logCollector.TotalCallsCnt, logCollector.TotalReplacedArgsCnt,
+		logCollector.TotalPanicCnt, logCollector.MaxProgramLength, logCollector.BrokenProgCNT, logCollector.Cnt)
+}
+
 func (logCollector *LogCollector) CalcMaxProgramLength(length int) int {
 	logCollector.MaxProgramLength = max(logCollector.MaxProgramLength, length)
 	return logCollector.MaxProgramLength
@@ -685,8 +696,8 @@ func ConvertResource(prog []byte, args ...bool) string {
 	return str
 }
 
-func IsDuplication(prog string) bool {
-	instance := GetDeduplicatorInstance()
+func (s *Session) IsDuplication(prog string) bool {
+	instance := s.dedup
 	if instance.ItemExists(prog) {
 		return true
 	}
@@ -698,20 +709,6 @@ type Deduplicator struct {
 	Set map[interface{}]struct{}
 }
 
-var (
-	_DeduplicatorInstance *Deduplicator
-	_DeduplicatorOnce     sync.Once
-)
-
-func GetDeduplicatorInstance() *Deduplicator {
-	_DeduplicatorOnce.Do(func() {
-		_DeduplicatorInstance = &Deduplicator{
-			Set: map[interface{}]struct{}{},
-		}
-	})
-	return _DeduplicatorInstance
-}
-
 func (d *Deduplicator) AddItem(item interface{}) {
 	_, found := d.Set[item]
 	if !found {
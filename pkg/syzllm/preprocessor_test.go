@@ -0,0 +1,114 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package syzllm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestSession(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	corpusDB, err := db.Open(filepath.Join(dir, "corpus.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progs := []string{
+		"r0 = mutate5(&(0x7f0000000100)='./file1\\x00', 0x1)\nmutate6(r0, &(0x7f0000000200)=\"0102\", 0x2)\n",
+		"mutate0()\nmutate4(&(0x7f0000000300)=\"aabb\", 0x2)\n",
+		"not_a_call()\n",
+	}
+	for i, p := range progs {
+		corpusDB.Save(string(rune('a'+i)), []byte(p), 0)
+	}
+	out := filepath.Join(dir, "out")
+	session, err := NewSession(target, AllocateConstant, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Process(corpusDB); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := session.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalProgramsCnt != 2 || summary.BrokenProgCNT != 1 || summary.TotalCallsCnt != 4 {
+		t.Fatalf("unexpected summary:\n%v", summary)
+	}
+	vocab, err := os.ReadFile(filepath.Join(out, "vocab", "vocab.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := os.ReadFile(filepath.Join(out, "tokens", "tokens_0.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(vocab) != string(tokens) {
+		t.Fatalf("vocab and tokens differ:\n%s\n%s", vocab, tokens)
+	}
+	if got := strings.Count(string(tokens), "[SEP]"); got != 2 {
+		t.Fatalf("got %v programs, want 2:\n%s", got, tokens)
+	}
+	// The resource is replaced with the call that produced it.
+	if !strings.Contains(string(tokens), "mutate6("+ResPrefix+"mutate5(") {
+		t.Fatalf("resource is not converted:\n%s", tokens)
+	}
+	addr, err := os.ReadFile(filepath.Join(out, "addr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(addr), "mutate4 ") {
+		t.Fatalf("no mutate4 in addr.txt:\n%s", addr)
+	}
+	bundle, err := ReadBundle(filepath.Join(out, BundleFile), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.Vocab) == 0 || bundle.AddrBase["mutate4"] == 0 {
+		t.Fatalf("bad bundle: %+v", bundle)
+	}
+}
+
+func TestBundleMismatch(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), BundleFile)
+	bundle := target.NewSyzLLMBundle()
+	bundle.AddrBase["mutate0"] = 0x1000
+	if err := WriteBundle(file, bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(file, target); err != nil {
+		t.Fatal(err)
+	}
+	bundle.Revision = "foo"
+	if err := WriteBundle(file, bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(file, target); err == nil || !strings.Contains(err.Error(), "revision") {
+		t.Fatalf("mismatched revision is not detected: %v", err)
+	}
+	bundle.Revision = target.Revision
+	bundle.Version++
+	if err := WriteBundle(file, bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadBundle(file, target); err == nil {
+		t.Fatalf("mismatched version is not detected")
+	}
+}
@@ -156,10 +156,7 @@ func (target *Target) squashPtr(arg *PointerArg) {
 	arg.ref = newType.ref()
 	arg.Res = MakeGroupArg(newType.Elem, DirIn, elems)
 	if size := arg.Res.Size(); size != size0 {
-		// IF SyzLLM
-		//panic(fmt.Sprintf("squash changed size %v->%v for %v", size0, size, res0.Type()))
-		arg.Res = res0
-		// ENDIF
+		panic(fmt.Sprintf("squash changed size %v->%v for %v", size0, size, res0.Type()))
 	}
 }
 
@@ -176,11 +173,6 @@ func (target *Target) squashPtrImpl(a Arg, elems *[]Arg) {
 	case *UnionArg:
 		if !arg.Type().Varlen() {
 			pad = arg.Size() - arg.Option.Size()
-			// IF SyzLLM
-			if arg.Size() < arg.Option.Size() {
-				pad = 0
-			}
-			// ENDIF
 		}
 		target.squashPtrImpl(arg.Option, elems)
 	case *DataArg:
@@ -267,11 +259,6 @@ func (target *Target) squashGroup(arg *GroupArg, elems *[]Arg) {
 	}
 	var bitfield, fieldsSize uint64
 	for _, fld := range arg.Inner {
-		// IF SyzLLM
-		if fieldsSize+fld.Size() > arg.Size() {
-			continue
-		}
-		// ENDIF
 		fieldsSize += fld.Size()
 		// Squash bitfields separately.
 		if fld.Type().IsBitfield() {
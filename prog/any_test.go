@@ -96,3 +96,31 @@ overlay_uses(0x0, 0x0, 0x0, r0)
 		})
 	}
 }
+
+func TestSquashMalformedCorpus(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	p, err := target.Deserialize([]byte(
+		"test$array2(&(0x7f0000000000)={0x1, \"00112233445566778899aabbccddeeff\", 0x2})\n"+
+			"test$regression2(&(0x7f0000000000)=[0x1, 0x2, 0x3, 0x4])\n"+
+			"test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n"), Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Programs mutated by SyzLLM in memory before DeserializeSyzLLM existed
+	// were malformed like this and then saved to corpus.db.
+	blob := p.Calls[0].Args[0].(*PointerArg).Res.(*GroupArg).Inner[1].(*DataArg)
+	blob.SetData([]byte(strings.Repeat("\xff", 20)))
+	arr := p.Calls[1].Args[0].(*PointerArg).Res.(*GroupArg)
+	arr.Inner = append(arr.Inner, arr.Inner[0].Type().DefaultArg(DirIn))
+	union := p.Calls[2].Args[0].(*PointerArg).Res.(*GroupArg).Inner[1].(*UnionArg)
+	union.Index = 1
+	data := p.serializeMasked(nil) // without validation as it was done in fuzzers
+	// Deserialization of the saved program fixes it up, so squashing can't break.
+	p1, err := target.Deserialize(data, NonStrict)
+	if err != nil {
+		t.Fatalf("failed to deserialize %q: %v", data, err)
+	}
+	for _, ptr := range p1.complexPtrs() {
+		target.squashPtr(ptr.arg)
+	}
+}
@@ -48,12 +48,28 @@ func (p *Prog) serialize(verbose bool) []byte {
 	return ctx.buf.Bytes()
 }
 
+// serializeMasked serializes p with mask replaced by MASK, p is not validated.
+// Note: mask is omitted (along with MASK) if it's a trailing default argument.
+func (p *Prog) serializeMasked(mask Arg) []byte {
+	ctx := &serializer{
+		target: p.Target,
+		buf:    new(bytes.Buffer),
+		vars:   make(map[*ResultArg]int),
+		mask:   mask,
+	}
+	for _, c := range p.Calls {
+		ctx.call(c)
+	}
+	return ctx.buf.Bytes()
+}
+
 type serializer struct {
 	target  *Target
 	buf     *bytes.Buffer
 	vars    map[*ResultArg]int
 	varSeq  int
 	verbose bool
+	mask    Arg
 }
 
 func (ctx *serializer) printf(text string, args ...interface{}) {
@@ -118,6 +134,10 @@ func (ctx *serializer) arg(arg Arg) {
 		ctx.printf("nil")
 		return
 	}
+	if arg == ctx.mask {
+		ctx.printf(MASK)
+		return
+	}
 	arg.serialize(ctx)
 }
 
@@ -242,6 +262,19 @@ const (
 )
 
 func (target *Target) Deserialize(data []byte, mode DeserializeMode) (*Prog, error) {
+	prog, _, err := target.deserialize(data, mode)
+	return prog, err
+}
+
+// deserialize is Deserialize that also returns what was fixed up in the NonStrict mode.
+func (target *Target) deserialize(data []byte, mode DeserializeMode) (*Prog, []string, error) {
+	return target.deserializeFixup(data, mode, nil)
+}
+
+// deserializeFixup is deserialize with a hook that fixes up the parsed program before it's validated,
+// see DeserializeSyzLLM.
+func (target *Target) deserializeFixup(data []byte, mode DeserializeMode, fixup func(*Prog)) (
+	*Prog, []string, error) {
 	defer func() {
 		if err := recover(); err != nil {
 			panic(fmt.Errorf("%v\ntarget: %v/%v, rev: %v, mode=%v, prog:\n%q",
@@ -251,24 +284,28 @@ func (target *Target) Deserialize(data []byte, mode DeserializeMode) (*Prog, err
 	p := newParser(target, data, mode == Strict)
 	prog, err := p.parseProg()
 	if err := p.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if fixup != nil {
+		fixup(prog)
+		p.dropRemovedAutos(prog)
 	}
 	// This validation is done even in non-debug mode because deserialization
 	// procedure does not catch all bugs (e.g. mismatched types).
 	// And we can receive bad programs from corpus and hub.
 	if err := prog.validate(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if p.autos != nil {
 		p.fixupAutos(prog)
 	}
 	if err := prog.sanitize(mode == NonStrict); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return prog, nil
+	return prog, p.fixups, nil
 }
 
 func (p *parser) parseProg() (*Prog, error) {
@@ -1101,6 +1138,7 @@ type parser struct {
 	vars    map[string]*ResultArg
 	autos   map[Arg]bool
 	comment string
+	fixups  []string // errors ignored in the non-strict mode
 
 	data []byte
 	s    string
@@ -1127,6 +1165,22 @@ func (p *parser) auto(arg Arg) Arg {
 	return arg
 }
 
+// dropRemovedAutos forgets AUTO args that are not in the program anymore after a fixup.
+func (p *parser) dropRemovedAutos(prog *Prog) {
+	if p.autos == nil {
+		return
+	}
+	autos := make(map[Arg]bool)
+	for _, c := range prog.Calls {
+		ForeachArg(c, func(arg Arg, _ *ArgCtx) {
+			if p.autos[arg] {
+				autos[arg] = true
+			}
+		})
+	}
+	p.autos = autos
+}
+
 func (p *parser) fixupAutos(prog *Prog) {
 	s := analyze(nil, nil, prog, nil)
 	for _, c := range prog.Calls {
@@ -1270,6 +1324,8 @@ func (p *parser) failf(msg string, args ...interface{}) {
 func (p *parser) strictFailf(msg string, args ...interface{}) {
 	if p.strict {
 		p.failf(msg, args...)
+	} else {
+		p.fixups = append(p.fixups, fmt.Sprintf("line #%v: %v", p.l, fmt.Sprintf(msg, args...)))
 	}
 }
 
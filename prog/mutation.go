@@ -4,17 +4,14 @@
 package prog
 
 import (
-	"bufio"
 	"encoding/binary"
 	"fmt"
 	"github.com/google/syzkaller/pkg/log"
 	"math"
 	"math/rand"
-	"os"
 	"regexp"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/google/syzkaller/pkg/image"
 )
@@ -43,78 +40,49 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, noMutate map[
 		noMutate: noMutate,
 		corpus:   corpus,
 	}
+	var ops []MutationOp
 	for stop, ok := false, false; !stop; stop = ok && len(p.Calls) != 0 && r.oneOf(3) {
-		switch {
-		case r.oneOf(5):
-			// Not all calls have anything squashable,
-			// so this has lower priority in reality.
-			ok = ctx.squashAny()
-		case r.nOutOf(1, 100):
-			ok = ctx.splice()
-		case r.nOutOf(20, 31):
-			//ok = ctx.insertCall()
-			if len(p.Calls) < 6 {
-				ok = ctx.insertCall()
-			} else {
-				ok = ctx.insertCall_SyzLLM()
-			}
-		case r.nOutOf(10, 11):
-			ok = ctx.mutateArg()
-		default:
-			ok = ctx.removeCall()
+		op := ctx.chooseOp()
+		if op, ok = ctx.applyOp(op); ok {
+			ops = append(ops, op)
 		}
 	}
+	p.MutationOps = ops
 	p.sanitizeFix()
 	p.debugValidate()
 	if got := len(p.Calls); got < 1 || got > ncalls {
 		panic(fmt.Sprintf("bad number of calls after mutation: %v, want [1, %v]", got, ncalls))
 	}
+	ctx.notePredictedCall()
 }
 
-func (p *Prog) RequestAndVerifyCall() {
-	file, err := os.Open("/root/data/vocab.txt")
-	if err != nil {
-		log.Fatal(err)
+// notePredictedCall records the predicted call in the program, unless it was removed by later mutations.
+func (ctx *mutator) notePredictedCall() {
+	p := ctx.p
+	p.Predicted = nil
+	for i, c := range p.Calls {
+		if c == ctx.predictedCall {
+			ctx.predicted.Position = i
+			p.Predicted = ctx.predicted
+		}
 	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
+}
 
+// RequestAndVerifyCall checks that all calls of the SyzLLM vocabulary can be deserialized.
+func (p *Prog) RequestAndVerifyCall(vocab []string) {
 	excludeCalls := []string{"newstat", "access", "newlstat", "clone"}
 
-	// for debug attaching
-	time.Sleep(20 * time.Second)
-
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			log.Fatal(err)
-		}
-		line = strings.TrimSuffix(line, "\n")
+	for _, line := range vocab {
 		if ContainsAny(line, excludeCalls) {
 			continue
 		}
 
 		line = ProcessDescriptor(line)
 
-		newCall := line
-		maskedSyscallList := make([]string, 1)
-		maskedSyscallList[0] = "[MASK]"
-		calls := ParseNestedResources(newCall, maskedSyscallList, 0)
-		newSyscallSequence := ""
-		for _, call := range calls {
-			if len(newSyscallSequence) > 0 {
-				newSyscallSequence += "\n"
-			}
-			newSyscallSequence += call
-		}
-		newSyscallBytes := []byte(newSyscallSequence)
-		_, err = p.Target.Deserialize(newSyscallBytes, NonStrict)
+		newCall, _ := extractResourceHints(line)
+		_, err := p.Target.Deserialize([]byte(newCall), NonStrict)
 		if err != nil {
-			log.Logf(0, newCall, err)
+			log.Logf(0, "%v: %v", line, err)
 		}
 	}
 }
@@ -128,6 +96,8 @@ func ContainsAny(call string, list []string) bool {
 	return false
 }
 
+// ProcessDescriptor resolves "$SyzLLM" calls with the fixed descriptor table of the installed bundle.
+// Mutations use resolveDescriptors instead, which chooses among all variants of the syscall.
 func ProcessDescriptor(line string) string {
 	callsWithoutDescriptor := []string{"pipe"}
 	for _, c := range callsWithoutDescriptor {
@@ -136,7 +106,7 @@ func ProcessDescriptor(line string) string {
 		}
 	}
 
-	callsWithDescriptor := map[string]string{"socketpair": "unix", "socket": "unix", "connect": "unix", "getsockname": "unix", "openat": "damon_target_ids", "fcntl": "setflags", "accept4": "unix", "read": "FUSE", "quotactl": "Q_QUOTAON", "getpeername": "llc", "sendmmsg": "unix", "getsockopt": "kcm_KCM_RECV_DISABLE", "bind": "unix", "sendto": "llc", "setsockopt": "kcm_KCM_RECV_DISABLE", "write": "damon_target_ids", "ioctl": "FITRIM", "mmap": "IORING_OFF_SQ_RING", "recvmsg": "unix", "sendmsg": "unix", "epoll_ctl": "EPOLL_CTL_ADD", "accept": "unix", "prctl": "PR_SET_PDEATHSIG", "recvfrom": "unix"}
+	callsWithDescriptor := currentSyzLLMDescriptors()
 
 	callName := ExtractCallNameWithoutDescriptor(line)
 	descriptor, exists := callsWithDescriptor[callName]
@@ -193,6 +163,12 @@ type mutator struct {
 	ct       *ChoiceTable // ChoiceTable for syscalls.
 	noMutate map[int]bool // Set of IDs of syscalls which should not be mutated.
 	corpus   []*Prog      // The entire corpus, including original program p.
+
+	predicted     *PredictedCall    // The last call inserted by the predictor, if any.
+	predictedCall *Call             // The inserted call itself, to find its final position.
+	record        *PredictionRecord // The record of the current insertion, if the predictor records them.
+	generate      bool              // The program is generated from scratch by GenerateSyzLLM.
+	fellBack      bool              // The SyzLLM operator fell back to the stock one, see applyOp.
 }
 
 // This function selects a random other program p0 out of the corpus, and
@@ -281,21 +257,102 @@ func (ctx *mutator) insertCall() bool {
 	return true
 }
 
+// Decides whether the current insertion should be delegated to the ChoiceTable's predictor.
+func (ctx *mutator) useSyzLLM() bool {
+	if !ctx.canInsertSyzLLM() {
+		return false
+	}
+	ct := ctx.ct
+	return ct.predictProb >= 100 || ctx.r.nOutOf(ct.predictProb, 100)
+}
+
+// canInsertSyzLLM says if insertions can be delegated to the predictor at all.
+// Short programs give the model too little context, so they always use insertCall.
+func (ctx *mutator) canInsertSyzLLM() bool {
+	ct := ctx.ct
+	return ct.predictorAvailable() && len(ctx.p.Calls) >= 6 && ct.predictProb > 0
+}
+
 func (ctx *mutator) insertCall_SyzLLM() bool {
 	p, r := ctx.p, ctx.r
+	if ctx.ct.predictor == nil {
+		ctx.fellBack = true
+		return ctx.insertCall()
+	}
 	if len(p.Calls) >= ctx.ncalls {
 		return false
 	}
 	idx := r.biasedRand(len(p.Calls)+1, 5)
-	calls := ctx.requestNewCallAsync(p, idx, ctx.ct)
-	if len(calls) == len(p.Calls) || len(calls) <= 0 {
+	res, err := ctx.predictCall(idx, r.Int63())
+	switch res {
+	case PredictionInserted:
+		return true
+	case PredictionPending:
+		// Never stall on the predictor, the answer will be used next time.
+		ctx.fellBack = true
+		return ctx.insertCall()
+	default:
+		log.Logf(2, "SyzLLM insertion failed: %v: %v", res, err)
 		return false
 	}
-	p.Calls = calls
-	for len(p.Calls) > ctx.ncalls {
-		p.RemoveCall(idx)
+}
+
+// predictCall inserts the predicted call at idx. All random choices are made with a separate
+// generator seeded with seed, so that the insertion can be repeated with ReplayPrediction.
+func (ctx *mutator) predictCall(idx int, seed int64) (PredictionResult, error) {
+	p, r := ctx.p, ctx.r
+	ctx.r = newRand(p.Target, rand.NewSource(seed))
+	defer func() { ctx.r = r }()
+	ctx.startRecord(idx, seed)
+	calls, res, err := ctx.requestNewCall(p, idx)
+	ctx.observePrediction(res)
+	if res == PredictionInserted {
+		p.Calls = calls
+		for len(p.Calls) > ctx.ncalls {
+			p.RemoveCall(idx)
+		}
+	}
+	ctx.finishRecord(res, err)
+	return res, err
+}
+
+// Decides whether the current argument mutation should be delegated to the ChoiceTable's predictor.
+func (ctx *mutator) useSyzLLMArgs() bool {
+	ct := ctx.ct
+	if !ct.predictorAvailable() || ct.predictArgProb <= 0 {
+		return false
+	}
+	return ct.predictArgProb >= 100 || ctx.r.nOutOf(ct.predictArgProb, 100)
+}
+
+// mutateArg_SyzLLM replaces a struct or union argument of a random call with the one predicted by SyzLLM.
+func (ctx *mutator) mutateArg_SyzLLM() bool {
+	p, r := ctx.p, ctx.r
+	idx := chooseCall(p, r)
+	if idx < 0 || ctx.noMutate[p.Calls[idx].Meta.ID] {
+		return false
+	}
+	args := maskableArgs(p.Target, p.Calls[idx])
+	if len(args) == 0 {
+		ctx.fellBack = true
+		return ctx.mutateArg()
+	}
+	res, err := ctx.requestArg(p, idx, args[r.Intn(len(args))])
+	if err == errNoMask {
+		ctx.fellBack = true
+		return ctx.mutateArg()
+	}
+	ctx.observePrediction(res)
+	switch res {
+	case PredictionArgReplaced:
+		return true
+	case PredictionPending:
+		ctx.fellBack = true
+		return ctx.mutateArg()
+	default:
+		log.Logf(2, "SyzLLM argument prediction failed: %v: %v", res, err)
+		return false
 	}
-	return true
 }
 
 // Removes a random call from program.
@@ -0,0 +1,246 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// MutationOp is a mutation operator applied by Mutate.
+type MutationOp int
+
+const (
+	MutateSquashAny MutationOp = iota
+	MutateSplice
+	MutateInsertCall
+	MutateInsertCallSyzLLM
+	MutateArg
+	MutateArgSyzLLM
+	MutateRemoveCall
+	MutationOpCount
+)
+
+var mutationOpNames = [MutationOpCount]string{
+	MutateSquashAny:        "squash any",
+	MutateSplice:           "splice",
+	MutateInsertCall:       "insert call",
+	MutateInsertCallSyzLLM: "insert call syzllm",
+	MutateArg:              "mutate arg",
+	MutateArgSyzLLM:        "mutate arg syzllm",
+	MutateRemoveCall:       "remove call",
+}
+
+func (op MutationOp) String() string {
+	return mutationOpNames[op]
+}
+
+const (
+	// Percent of choices of the adaptive scheduler that are uniformly random,
+	// so that operators that were unlucky so far are still tried.
+	opExplore = 10
+	// Number of programs after which all counts are halved,
+	// so that the scheduler follows the kernel state as fuzzing progresses.
+	opWindow = 1 << 14
+)
+
+// OpScheduler accounts the new signal found by programs mutated with each operator.
+// An adaptive scheduler also chooses operators for Mutate based on that (Thompson sampling:
+// the operator with the best sample of the Beta posterior of its success rate is chosen),
+// otherwise Mutate uses fixed odds. All methods can be called concurrently.
+type OpScheduler struct {
+	adaptive bool
+
+	mu    sync.Mutex
+	total float64                  // decayed number of programs
+	tries [MutationOpCount]float64 // decayed number of programs mutated with the operator
+	wins  [MutationOpCount]float64 // decayed number of such programs that gave new signal
+	stats [MutationOpCount]OpStat  // counts since the last TakeStats
+}
+
+// OpStat is the number of executed programs mutated with an operator and how many of them gave new signal.
+type OpStat struct {
+	Tries     uint64
+	NewSignal uint64
+}
+
+func NewOpScheduler(adaptive bool) *OpScheduler {
+	return &OpScheduler{adaptive: adaptive}
+}
+
+// NoteResult accounts an execution of a program mutated with ops.
+func (s *OpScheduler) NoteResult(ops []MutationOp, newSignal bool) {
+	var seen [MutationOpCount]bool
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	if s.total >= opWindow {
+		s.total /= 2
+		for op := range s.tries {
+			s.tries[op] /= 2
+			s.wins[op] /= 2
+		}
+	}
+	for _, op := range ops {
+		if seen[op] {
+			continue
+		}
+		seen[op] = true
+		s.tries[op]++
+		s.stats[op].Tries++
+		if newSignal {
+			s.wins[op]++
+			s.stats[op].NewSignal++
+		}
+	}
+}
+
+// TakeStats returns the counts accumulated since the last call.
+func (s *OpScheduler) TakeStats() [MutationOpCount]OpStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	s.stats = [MutationOpCount]OpStat{}
+	return stats
+}
+
+// Weights returns the recent shares of the operators in mutated programs normalized to sum to 1,
+// i.e. the operator distribution learned by an adaptive scheduler.
+func (s *OpScheduler) Weights() [MutationOpCount]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var weights [MutationOpCount]float64
+	var sum float64
+	for _, tries := range s.tries {
+		sum += tries
+	}
+	if sum == 0 {
+		return weights
+	}
+	for op, tries := range s.tries {
+		weights[op] = tries / sum
+	}
+	return weights
+}
+
+// choose returns one of the enabled operators.
+func (s *OpScheduler) choose(r *rand.Rand, enabled *[MutationOpCount]bool) MutationOp {
+	var ops []MutationOp
+	for op := MutationOp(0); op < MutationOpCount; op++ {
+		if enabled[op] {
+			ops = append(ops, op)
+		}
+	}
+	if r.Intn(100) < opExplore {
+		return ops[r.Intn(len(ops))]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	best, bestSample := ops[0], -1.0
+	for _, op := range ops {
+		if sample := sampleBeta(r, s.wins[op]+1, s.tries[op]-s.wins[op]+1); sample > bestSample {
+			best, bestSample = op, sample
+		}
+	}
+	return best
+}
+
+// sampleBeta samples Beta(a, b) for a, b >= 1.
+func sampleBeta(r *rand.Rand, a, b float64) float64 {
+	x := sampleGamma(r, a)
+	return x / (x + sampleGamma(r, b))
+}
+
+// sampleGamma samples Gamma(a, 1) for a >= 1 with the Marsaglia-Tsang method.
+func sampleGamma(r *rand.Rand, a float64) float64 {
+	d := a - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+// chooseOp chooses the next mutation operator, see OpScheduler.
+func (ctx *mutator) chooseOp() MutationOp {
+	ct, r := ctx.ct, ctx.r
+	if s := ct.opSched; s != nil && s.adaptive {
+		// The scheduler decides what share of insertions and argument mutations
+		// goes to the predictor, its probabilities are only used to enable the operators.
+		enabled := [MutationOpCount]bool{
+			MutateSquashAny:        true,
+			MutateSplice:           true,
+			MutateInsertCall:       true,
+			MutateInsertCallSyzLLM: ctx.canInsertSyzLLM(),
+			MutateArg:              true,
+			MutateArgSyzLLM:        ct.predictorAvailable() && ct.predictArgProb > 0,
+			MutateRemoveCall:       true,
+		}
+		return s.choose(r.Rand, &enabled)
+	}
+	switch {
+	case r.oneOf(5):
+		// Not all calls have anything squashable,
+		// so this has lower priority in reality.
+		return MutateSquashAny
+	case r.nOutOf(1, 100):
+		return MutateSplice
+	case r.nOutOf(20, 31):
+		if ctx.useSyzLLM() {
+			return MutateInsertCallSyzLLM
+		}
+		return MutateInsertCall
+	case r.nOutOf(10, 11):
+		if ctx.useSyzLLMArgs() {
+			return MutateArgSyzLLM
+		}
+		return MutateArg
+	default:
+		return MutateRemoveCall
+	}
+}
+
+// applyOp applies the mutation operator and says if the program was changed.
+// SyzLLM operators may fall back to the stock ones (e.g. if the prediction is pending),
+// in such case the stock operator is returned, so that it's credited for the result.
+func (ctx *mutator) applyOp(op MutationOp) (MutationOp, bool) {
+	ctx.fellBack = false
+	var ok bool
+	switch op {
+	case MutateSquashAny:
+		ok = ctx.squashAny()
+	case MutateSplice:
+		ok = ctx.splice()
+	case MutateInsertCall:
+		ok = ctx.insertCall()
+	case MutateInsertCallSyzLLM:
+		ok = ctx.insertCall_SyzLLM()
+	case MutateArg:
+		ok = ctx.mutateArg()
+	case MutateArgSyzLLM:
+		ok = ctx.mutateArg_SyzLLM()
+	case MutateRemoveCall:
+		ok = ctx.removeCall()
+	default:
+		panic("bad mutation op")
+	}
+	if ctx.fellBack {
+		switch op {
+		case MutateInsertCallSyzLLM:
+			op = MutateInsertCall
+		case MutateArgSyzLLM:
+			op = MutateArg
+		}
+	}
+	return op, ok
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSampleBeta(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	const n = 10000
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += sampleBeta(r, 3, 7)
+	}
+	if mean := sum / n; math.Abs(mean-0.3) > 0.02 {
+		t.Fatalf("Beta(3, 7) mean is %v, want 0.3", mean)
+	}
+}
+
+func TestOpScheduler(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	s := NewOpScheduler(true)
+	enabled := [MutationOpCount]bool{}
+	for op := range enabled {
+		enabled[op] = op != int(MutateArgSyzLLM)
+	}
+	var chosen [MutationOpCount]int
+	for i := 0; i < 10000; i++ {
+		op := s.choose(r, &enabled)
+		chosen[op]++
+		rate := 0.01
+		if op == MutateInsertCallSyzLLM {
+			rate = 0.3
+		}
+		s.NoteResult([]MutationOp{op, op}, r.Float64() < rate)
+	}
+	if chosen[MutateArgSyzLLM] != 0 {
+		t.Fatalf("disabled operator was chosen %v times", chosen[MutateArgSyzLLM])
+	}
+	if chosen[MutateInsertCallSyzLLM] < 7000 {
+		t.Fatalf("the best operator was chosen only %v times: %v", chosen[MutateInsertCallSyzLLM], chosen)
+	}
+	weights := s.Weights()
+	for op, w := range weights {
+		if op != int(MutateInsertCallSyzLLM) && w >= weights[MutateInsertCallSyzLLM] {
+			t.Fatalf("operator %v has weight %v >= %v", MutationOp(op), w, weights[MutateInsertCallSyzLLM])
+		}
+	}
+	stats := s.TakeStats()
+	total := uint64(0)
+	for _, stat := range stats {
+		total += stat.Tries
+	}
+	if total != 10000 {
+		t.Fatalf("accounted %v programs, want 10000", total)
+	}
+	if stats := s.TakeStats(); stats[MutateInsertCallSyzLLM].Tries != 0 {
+		t.Fatalf("stats are not reset")
+	}
+}
+
+func TestMutateWithOpScheduler(t *testing.T) {
+	target, rs, iters := initTest(t)
+	ct := target.DefaultChoiceTable()
+	s := NewOpScheduler(true)
+	ct.SetOpScheduler(s)
+	r := rand.New(rs)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		p.Mutate(rs, 10, ct, nil, nil)
+		if len(p.MutationOps) == 0 {
+			t.Fatalf("no mutation operators recorded")
+		}
+		for _, op := range p.MutationOps {
+			if op == MutateInsertCallSyzLLM || op == MutateArgSyzLLM {
+				t.Fatalf("operator %v is chosen without a predictor", op)
+			}
+		}
+		s.NoteResult(p.MutationOps, r.Intn(10) == 0)
+		if p1 := p.Clone(); p1.MutationOps != nil {
+			t.Fatalf("mutation operators are cloned")
+		}
+	}
+}
+
+func TestOpSchedulerShortPrograms(t *testing.T) {
+	target, rs, iters := initTest(t)
+	ct := target.DefaultChoiceTable()
+	pred := &FakePredictor{}
+	ct.SetPredictor(pred, 100)
+	ct.SetOpScheduler(NewOpScheduler(true))
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 5, ct)
+		p.Mutate(rs, 5, ct, nil, nil)
+		for _, op := range p.MutationOps {
+			if op == MutateInsertCallSyzLLM {
+				t.Fatalf("SyzLLM insertion is chosen for a program with %v calls", len(p.Calls))
+			}
+		}
+	}
+	if reqs := pred.Requests(); len(reqs) != 0 {
+		t.Fatalf("predictor is asked about short programs: %q", reqs[0])
+	}
+}
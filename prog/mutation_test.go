@@ -5,8 +5,10 @@ package prog
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/google/syzkaller/pkg/testutil"
@@ -478,6 +480,159 @@ func BenchmarkStoreLoadInt(b *testing.B) {
 
 var sink interface{}
 
+func TestInsertCallSyzLLM(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	const prog0 = `mutate0()
+mutate1()
+mutate2()
+`
+	tests := []struct {
+		name        string
+		predictions []Prediction
+		err         error
+		inserted    string
+		fallback    bool
+	}{
+		{
+			name:        "full call",
+			predictions: []Prediction{{Call: "mutate8$SyzLLM(0x2)", Score: 1}},
+			inserted:    "mutate8(0x2)",
+		},
+		{
+			name:        "bare syscall name",
+			predictions: []Prediction{{Call: "mutate_integer", Score: 1}},
+			inserted:    "mutate_integer(",
+		},
+		{
+			name:        "base syscall name",
+			predictions: []Prediction{{Call: "mutate8$SyzLLM", Score: 1}},
+			inserted:    "mutate8(",
+		},
+		{
+			name: "predictor error",
+			err:  fmt.Errorf("server is down"),
+		},
+		{
+			name: "retry next candidate",
+			predictions: []Prediction{
+				{Call: "mutate8$SyzLLM(0x2", Score: 0.9},
+				{Call: "mutate8$SyzLLM(0x3)", Score: 0.1},
+			},
+			inserted: "mutate8(0x3)",
+		},
+		{
+			name:     "pending prediction",
+			err:      ErrPredictionPending,
+			fallback: true,
+		},
+		{
+			name:        "unparsable prediction",
+			predictions: []Prediction{{Call: "mutate8$SyzLLM(0x2", Score: 1}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			p, err := target.Deserialize([]byte(prog0), Strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ct := target.BuildChoiceTable(nil, nil)
+			pred := &FakePredictor{Predictions: test.predictions, Err: test.err}
+			ct.SetPredictor(pred, 100)
+			ctx := &mutator{
+				p:      p,
+				r:      newRand(target, rand.NewSource(0)),
+				ncalls: 10,
+				ct:     ct,
+			}
+			op, ok := ctx.applyOp(MutateInsertCallSyzLLM)
+			if test.fallback {
+				if !ok || len(p.Calls) != 4 || op != MutateInsertCall {
+					t.Fatalf("pending prediction did not fall back to insertCall (%v):\n%s", op, p.Serialize())
+				}
+				return
+			}
+			if op != MutateInsertCallSyzLLM {
+				t.Fatalf("applied op %v", op)
+			}
+			if ok != (test.inserted != "") {
+				t.Fatalf("insertCall_SyzLLM returned %v", ok)
+			}
+			requests := pred.Requests()
+			if len(requests) != 1 || len(requests[0]) != 4 {
+				t.Fatalf("bad predictor requests: %q", requests)
+			}
+			data := string(p.Serialize())
+			if !ok {
+				if data != prog0 {
+					t.Fatalf("program changed:\n%s", data)
+				}
+				return
+			}
+			if len(p.Calls) != 4 || !strings.Contains(data, test.inserted) {
+				t.Fatalf("predicted call %q is not inserted:\n%s", test.inserted, data)
+			}
+			mask := -1
+			for i, call := range requests[0] {
+				if call == MASK {
+					mask = i
+				}
+			}
+			if name := p.Calls[mask].Meta.Name; !strings.HasPrefix(test.inserted, name) {
+				t.Fatalf("call %v is inserted at the masked position %v", name, mask)
+			}
+			ctx.notePredictedCall()
+			if p.Predicted == nil || p.Predicted.Position != mask {
+				t.Fatalf("bad predicted call: %+v, want position %v", p.Predicted, mask)
+			}
+		})
+	}
+}
+
+type predictionCallPolicy map[string]bool
+
+func (policy predictionCallPolicy) AllowPredictedCall(meta *Syscall) bool {
+	return policy[meta.Name]
+}
+
+func TestPredictionCallPolicy(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	const prog0 = "mutate0()\nmutate1()\n"
+	enabled := map[*Syscall]bool{
+		target.SyscallMap["mutate0"]: true,
+		target.SyscallMap["mutate1"]: true,
+	}
+	for _, policy := range []PredictionCallPolicy{nil, predictionCallPolicy{"mutate8": true}} {
+		p, err := target.Deserialize([]byte(prog0), Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct := target.BuildChoiceTable(nil, enabled)
+		ct.SetPredictor(&FakePredictor{Predictions: []Prediction{{Call: "mutate8(0x2)", Score: 1}}}, 100)
+		ct.SetPredictionCallPolicy(policy)
+		ctx := &mutator{
+			p:      p,
+			r:      newRand(target, rand.NewSource(0)),
+			ncalls: 10,
+			ct:     ct,
+		}
+		_, res, err := ctx.requestNewCall(p, 1)
+		if policy == nil {
+			if res != PredictionDisabled || !errors.Is(err, ErrPredictedCallDisabled) {
+				t.Fatalf("disabled call is not rejected: %v: %v", res, err)
+			}
+			continue
+		}
+		if res != PredictionInserted {
+			t.Fatalf("allowed call is not inserted: %v: %v", res, err)
+		}
+		if ct.Enabled(target.SyscallMap["mutate8"].ID) {
+			t.Fatalf("prediction has changed the choice table")
+		}
+	}
+}
+
 func TestProcessDescriptor(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,374 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallPredictor predicts calls that fit into a masked position of a call sequence.
+// Implementations must be safe for concurrent use by several mutators.
+type CallPredictor interface {
+	// Predict accepts a sequence of serialized calls with exactly one MASK element
+	// and returns candidates for the masked position ranked by decreasing score.
+	// If MASK is inside one of the calls instead (e.g. "bind(r0, &(0x7f0000000000)=[MASK], 0x10)"),
+	// the masked argument is predicted and candidates are texts of the argument.
+	// Predictors that don't support argument prediction return ErrPredictionRejected for such sequences.
+	Predict(calls []string) ([]Prediction, error)
+}
+
+// BatchCallPredictor is implemented by predictors that can serve several requests at once.
+type BatchCallPredictor interface {
+	CallPredictor
+	// PredictBatch returns predictions for each of the sequences in the same order.
+	// A nil element means that there is no prediction for the corresponding sequence.
+	PredictBatch(seqs [][]string) ([][]Prediction, error)
+}
+
+var (
+	// ErrPredictionPending is returned by asynchronous predictors when the answer is not available yet.
+	// Mutations fall back to the ChoiceTable in this case.
+	ErrPredictionPending = errors.New("prediction is pending")
+	// ErrPredictionRejected is returned when the predictor has nothing to offer for the sequence.
+	ErrPredictionRejected = errors.New("prediction is rejected")
+	// ErrPredictedCallDisabled is returned when the predicted call is not enabled
+	// and the PredictionCallPolicy does not allow it.
+	ErrPredictedCallDisabled = errors.New("predicted call is disabled")
+)
+
+// PredictionResult describes what happened to an attempt to insert a predicted call.
+type PredictionResult int
+
+const (
+	PredictionInserted    PredictionResult = iota
+	PredictionPending                      // the answer is not available yet, ChoiceTable is used instead
+	PredictionServerError                  // the predictor failed, e.g. the server is unreachable
+	PredictionRejected                     // the predictor has no candidates (e.g. State != 0)
+	PredictionParseError                   // none of the candidates could be inserted into the program
+	PredictionUnchanged                    // the program length has not changed after insertion
+	PredictionDisabled                     // the candidates name calls that are not allowed by the policy
+	PredictionArgReplaced                  // the masked argument is replaced with the predicted one
+	PredictionResultCount
+)
+
+var predictionResultNames = [PredictionResultCount]string{
+	PredictionInserted:    "inserted",
+	PredictionPending:     "pending",
+	PredictionServerError: "server error",
+	PredictionRejected:    "rejected",
+	PredictionParseError:  "parse error",
+	PredictionUnchanged:   "unchanged",
+	PredictionDisabled:    "disabled",
+	PredictionArgReplaced: "arg replaced",
+}
+
+func (res PredictionResult) String() string {
+	return predictionResultNames[res]
+}
+
+// BlockingCallPredictor is implemented by asynchronous predictors that can also wait for the answer.
+// GenerateSyzLLM builds programs call by call and has nothing to fall back to on every step,
+// so it waits for predictions instead of getting ErrPredictionPending.
+type BlockingCallPredictor interface {
+	CallPredictor
+	// PredictWait is like Predict, but waits for the answer for an implementation defined time.
+	PredictWait(calls []string) ([]Prediction, error)
+}
+
+// PredictionObserver is implemented by predictors that want to know what happened to their predictions.
+type PredictionObserver interface {
+	// ObservePrediction is called after every attempt to insert a predicted call.
+	// call is the name of the inserted syscall for PredictionInserted, and empty otherwise.
+	ObservePrediction(res PredictionResult, call string)
+}
+
+// PredictorHealth is implemented by predictors that may be temporarily unavailable
+// (e.g. the server is down). While Available returns false, mutations and generation
+// don't consult the predictor at all and behave as if it was not set.
+type PredictorHealth interface {
+	Available() bool
+}
+
+// PredictionCallPolicy decides whether a predicted call that is not enabled in the ChoiceTable
+// may be inserted into a program. Such calls are only inserted, they are never generated
+// by the ChoiceTable. Without a policy predictions of such calls are rejected.
+// Implementations must be safe for concurrent use.
+type PredictionCallPolicy interface {
+	AllowPredictedCall(meta *Syscall) bool
+}
+
+// Prediction is a single candidate call returned by a CallPredictor.
+// Call is either a complete call in the SyzLLM syntax (e.g. "socket$SyzLLM(0x1, 0x1, 0x0)"),
+// or a bare syscall name (e.g. "socket$inet") in which case arguments are generated.
+// ID identifies the predictor response the candidate comes from, it may be empty.
+type Prediction struct {
+	Call  string
+	Score float64
+	ID    string
+}
+
+// PredictedCall describes the predicted call inserted into a program by Mutate.
+// If several calls were inserted during a single Mutate, only the last one is recorded.
+type PredictedCall struct {
+	ID       string
+	Call     string // the prediction as returned by the predictor
+	Position int    // index of the inserted call in the mutated program
+}
+
+const predictedCallMarker = "syzllm prediction"
+
+// String formats pc for logging before the program is executed,
+// so that crashes can be attributed to predictions with ParsePredictedCalls.
+func (pc *PredictedCall) String() string {
+	return fmt.Sprintf("%v id=%v pos=%v call=%q", predictedCallMarker, pc.ID, pc.Position, pc.Call)
+}
+
+var predictedCallRe = regexp.MustCompile(predictedCallMarker + ` id=(\S*) pos=(\d+) call=(".*")`)
+
+// ParsePredictedCalls extracts all predicted calls logged with PredictedCall.String from output.
+func ParsePredictedCalls(output []byte) []*PredictedCall {
+	var res []*PredictedCall
+	for _, match := range predictedCallRe.FindAllSubmatch(output, -1) {
+		pos, err := strconv.Atoi(string(match[2]))
+		if err != nil {
+			continue
+		}
+		call, err := strconv.Unquote(string(match[3]))
+		if err != nil {
+			continue
+		}
+		res = append(res, &PredictedCall{ID: string(match[1]), Call: call, Position: pos})
+	}
+	return res
+}
+
+// PredictionOutcome is feedback about a program mutated with a predicted call.
+type PredictionOutcome struct {
+	ID        string
+	Call      string
+	Position  int
+	NewSignal int    // amount of new signal the program produced
+	Crash     string // title of the crash the program caused, if any
+}
+
+// OutcomeReporter is implemented by predictors that accept feedback about their predictions.
+type OutcomeReporter interface {
+	ReportOutcomes(outcomes []PredictionOutcome) error
+}
+
+// HTTPPredictor asks a SyzLLM inference server for predictions.
+type HTTPPredictor struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPredictor(addr string, timeout time.Duration) *HTTPPredictor {
+	return &HTTPPredictor{
+		url:    fmt.Sprintf("http://%v", addr),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (pred *HTTPPredictor) Predict(calls []string) ([]Prediction, error) {
+	res := SyzLLMResponse{State: -1}
+	if err := pred.post("", SyscallData{Syscalls: calls}, &res); err != nil {
+		return nil, err
+	}
+	if res.State != 0 {
+		return nil, fmt.Errorf("%w: server returned state %v", ErrPredictionRejected, res.State)
+	}
+	return res.predictions(), nil
+}
+
+func (pred *HTTPPredictor) PredictBatch(seqs [][]string) ([][]Prediction, error) {
+	var res SyzLLMBatchResponse
+	if err := pred.post("/batch", SyscallBatchData{Batch: seqs}, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Responses) != len(seqs) {
+		return nil, fmt.Errorf("server returned %v responses for %v requests", len(res.Responses), len(seqs))
+	}
+	predictions := make([][]Prediction, len(seqs))
+	for i, resp := range res.Responses {
+		if resp.State == 0 {
+			predictions[i] = resp.predictions()
+		}
+	}
+	return predictions, nil
+}
+
+func (pred *HTTPPredictor) ReportOutcomes(outcomes []PredictionOutcome) error {
+	var res struct{}
+	return pred.post("/outcome", outcomes, &res)
+}
+
+func (pred *HTTPPredictor) post(path string, req, res interface{}) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	resp, err := pred.client.Post(pred.url+path, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v%v: %v", pred.url, path, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(data, res); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// NgramPredictor predicts the masked call from the names of the calls preceding it
+// based on call sequences observed in the corpus. When the full context was never
+// observed, it backs off to shorter contexts down to plain call frequencies.
+type NgramPredictor struct {
+	n      int
+	counts map[string]map[string]int // context -> next call name -> count
+}
+
+// NewNgramPredictor builds a predictor that conditions on up to n-1 preceding calls.
+// The start of a program is part of the context, so sequences starting with CLS can be generated.
+func NewNgramPredictor(corpus []*Prog, n int) *NgramPredictor {
+	if n < 1 {
+		n = 1
+	}
+	pred := &NgramPredictor{
+		n:      n,
+		counts: make(map[string]map[string]int),
+	}
+	for _, p := range corpus {
+		names := []string{CLS}
+		for _, c := range p.Calls {
+			names = append(names, c.Meta.Name)
+		}
+		for i := 1; i < len(names); i++ {
+			name := names[i]
+			for k := 0; k < n && k <= i; k++ {
+				ctx := ngramContext(names[i-k : i])
+				if pred.counts[ctx] == nil {
+					pred.counts[ctx] = make(map[string]int)
+				}
+				pred.counts[ctx][name]++
+			}
+		}
+	}
+	return pred
+}
+
+func (pred *NgramPredictor) Predict(calls []string) ([]Prediction, error) {
+	pos := -1
+	var names []string
+	for i, call := range calls {
+		if call == MASK {
+			pos = i
+			break
+		}
+		names = append(names, callNameFromText(call))
+	}
+	if pos == -1 {
+		return nil, fmt.Errorf("%w: no %v call in the sequence", ErrPredictionRejected, MASK)
+	}
+	k := pred.n - 1
+	if k > len(names) {
+		k = len(names)
+	}
+	for ; k >= 0; k-- {
+		counts := pred.counts[ngramContext(names[len(names)-k:])]
+		if len(counts) == 0 {
+			continue
+		}
+		total := 0
+		var res []Prediction
+		for name, cnt := range counts {
+			total += cnt
+			res = append(res, Prediction{Call: name, Score: float64(cnt)})
+		}
+		for i := range res {
+			res[i].Score /= float64(total)
+		}
+		sort.Slice(res, func(i, j int) bool {
+			if res[i].Score != res[j].Score {
+				return res[i].Score > res[j].Score
+			}
+			return res[i].Call < res[j].Call
+		})
+		return res, nil
+	}
+	return nil, fmt.Errorf("%w: empty corpus", ErrPredictionRejected)
+}
+
+func ngramContext(names []string) string {
+	return strings.Join(names, " ")
+}
+
+// callNameFromText extracts the syscall name from a serialized call like "r0 = socket$inet(...)".
+func callNameFromText(call string) string {
+	if idx := strings.Index(call, " = "); idx != -1 && !strings.Contains(call[:idx], "(") {
+		call = call[idx+3:]
+	}
+	if idx := strings.IndexByte(call, '('); idx != -1 {
+		call = call[:idx]
+	}
+	return call
+}
+
+// FakePredictor is a deterministic CallPredictor for tests.
+// It returns the same Predictions (or Err) for every request and records the requests and outcomes.
+type FakePredictor struct {
+	Predictions []Prediction
+	Err         error
+
+	mu       sync.Mutex
+	requests [][]string
+	outcomes []PredictionOutcome
+}
+
+func (pred *FakePredictor) Predict(calls []string) ([]Prediction, error) {
+	pred.mu.Lock()
+	defer pred.mu.Unlock()
+	pred.requests = append(pred.requests, append([]string{}, calls...))
+	if pred.Err != nil {
+		return nil, pred.Err
+	}
+	return append([]Prediction{}, pred.Predictions...), nil
+}
+
+// Requests returns all call sequences the predictor was asked about.
+func (pred *FakePredictor) Requests() [][]string {
+	pred.mu.Lock()
+	defer pred.mu.Unlock()
+	return append([][]string{}, pred.requests...)
+}
+
+func (pred *FakePredictor) ReportOutcomes(outcomes []PredictionOutcome) error {
+	pred.mu.Lock()
+	defer pred.mu.Unlock()
+	pred.outcomes = append(pred.outcomes, outcomes...)
+	return nil
+}
+
+// Outcomes returns all outcomes reported to the predictor.
+func (pred *FakePredictor) Outcomes() []PredictionOutcome {
+	pred.mu.Lock()
+	defer pred.mu.Unlock()
+	return append([]PredictionOutcome{}, pred.outcomes...)
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNgramPredictor(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	var corpus []*Prog
+	for _, text := range []string{
+		"mutate0()\nmutate1()\nmutate2()\n",
+		"mutate0()\nmutate1()\nmutate2()\n",
+		"mutate1()\nmutate0()\nmutate1()\n",
+		"r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)\nmutate1()\n",
+	} {
+		p, err := target.Deserialize([]byte(text), Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus = append(corpus, p)
+	}
+	pred := NewNgramPredictor(corpus, 3)
+	tests := []struct {
+		calls []string
+		want  string
+	}{
+		// Full context is known.
+		{[]string{"mutate0()", "mutate1()", MASK}, "mutate2"},
+		// Backs off to a single preceding call.
+		{[]string{"mutate2()", "mutate0()", MASK, "mutate2()"}, "mutate1"},
+		// Result assignments are not part of the call name.
+		{[]string{"r1 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)", MASK}, "mutate1"},
+		// Unknown context backs off to call frequencies.
+		{[]string{"mutate9(&(0x7f0000000000)='./file0\\x00')", MASK}, "mutate1"},
+		// The start of the sequence is part of the context.
+		{[]string{CLS, MASK}, "mutate0"},
+	}
+	for i, test := range tests {
+		res, err := pred.Predict(test.calls)
+		if err != nil {
+			t.Fatalf("#%v: %v", i, err)
+		}
+		if len(res) == 0 || res[0].Call != test.want {
+			t.Errorf("#%v: got %+v, want %v", i, res, test.want)
+		}
+		for j := 1; j < len(res); j++ {
+			if res[j].Score > res[j-1].Score {
+				t.Errorf("#%v: predictions are not sorted: %+v", i, res)
+			}
+		}
+	}
+	if _, err := pred.Predict([]string{"mutate0()"}); err == nil {
+		t.Errorf("no error for a sequence without %v", MASK)
+	}
+}
+
+func TestHTTPPredictor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			var req SyscallData
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Syscalls) != 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(SyzLLMResponse{
+				Syscall: "mutate0$SyzLLM()",
+				Candidates: []SyzLLMCandidate{
+					{"mutate0$SyzLLM()", 0.7},
+					{"mutate1$SyzLLM()", 0.2},
+				},
+			})
+		case "/batch":
+			var req SyscallBatchData
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res := SyzLLMBatchResponse{}
+			for i := range req.Batch {
+				// Old-style response without candidates for the first one, failure for others.
+				res.Responses = append(res.Responses, SyzLLMResponse{State: i, Syscall: "mutate2$SyzLLM()"})
+			}
+			json.NewEncoder(w).Encode(res)
+		case "/outcome":
+			http.Error(w, `{"error": "overloaded"}`, http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	pred := NewHTTPPredictor(server.Listener.Addr().String(), 10*time.Second)
+
+	res, err := pred.Predict([]string{"mutate0()", MASK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Prediction{{Call: "mutate0$SyzLLM()", Score: 0.7}, {Call: "mutate1$SyzLLM()", Score: 0.2}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+
+	batch, err := pred.PredictBatch([][]string{{MASK}, {"mutate0()", MASK}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBatch := [][]Prediction{{{Call: "mutate2$SyzLLM()", Score: 1}}, nil}
+	if !reflect.DeepEqual(batch, wantBatch) {
+		t.Errorf("got %+v, want %+v", batch, wantBatch)
+	}
+
+	// Error pages must not be decoded as responses.
+	if err := pred.ReportOutcomes(nil); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got %v for an error status", err)
+	}
+}
+
+func TestParsePredictedCalls(t *testing.T) {
+	calls := []*PredictedCall{
+		{ID: "42", Call: `mutate8$SyzLLM(0x2)`, Position: 3},
+		{ID: "", Call: "mutate5$SyzLLM(&(0x7f0000000000)='./file0\\x00', 0x0)", Position: 0},
+	}
+	output := "some kernel output\n" + calls[0].String() + "\n12:00:00 executing program 1:\nmutate0()\n" +
+		calls[1].String() + "\nBUG: crash\n"
+	got := ParsePredictedCalls([]byte(output))
+	if !reflect.DeepEqual(got, calls) {
+		t.Fatalf("got %+v, want %+v", got, calls)
+	}
+}
@@ -159,6 +159,100 @@ func (target *Target) calcDynamicPrio(corpus []*Prog) [][]int32 {
 	return prios
 }
 
+// The n-gram component captures the order of calls that the dynamic priorities lose:
+// for every sequence of up to ngramOrder calls in corpus programs it counts the calls
+// that follow the sequence. When a call is inserted, the model is asked for the next call
+// given the calls preceding the insertion point, which makes it a sequence-aware
+// in-process baseline for SyzLLM predictions.
+
+const (
+	ngramOrder = 3  // max number of preceding calls the model conditions on
+	ngramStart = -1 // pseudo call that precedes the first call of every program
+	ngramProb  = 50 // percent of insertions that consult the model
+)
+
+type ngramKey struct {
+	n     int
+	calls [ngramOrder]int32
+}
+
+func makeNgramKey(seq []int32) ngramKey {
+	key := ngramKey{n: len(seq)}
+	copy(key.calls[:], seq)
+	return key
+}
+
+// ngramNext holds the calls that follow an n-gram with cumulated counts, like ChoiceTable.runs.
+type ngramNext struct {
+	calls []int
+	run   []int32
+}
+
+type callNgrams map[ngramKey]*ngramNext
+
+// calcNgrams builds the n-gram model of corpus, only calls for which enabled is true are predicted.
+// Returns nil for an empty corpus.
+func (target *Target) calcNgrams(corpus []*Prog, enabled map[*Syscall]bool) callNgrams {
+	counts := make(map[ngramKey]map[int]int32)
+	for _, p := range corpus {
+		seq := []int32{ngramStart}
+		for _, c := range p.Calls {
+			if enabled[c.Meta] {
+				for n := 1; n <= ngramOrder && n <= len(seq); n++ {
+					key := makeNgramKey(seq[len(seq)-n:])
+					if counts[key] == nil {
+						counts[key] = make(map[int]int32)
+					}
+					counts[key][c.Meta.ID]++
+				}
+			}
+			seq = append(seq, int32(c.Meta.ID))
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	ngrams := make(callNgrams)
+	for key, next := range counts {
+		ent := &ngramNext{}
+		for call := range next {
+			ent.calls = append(ent.calls, call)
+		}
+		sort.Ints(ent.calls)
+		var sum int32
+		for _, call := range ent.calls {
+			sum += next[call]
+			ent.run = append(ent.run, sum)
+		}
+		ngrams[key] = ent
+	}
+	return ngrams
+}
+
+// choose returns a call that followed the longest known suffix of prev in the corpus,
+// or -1 if no suffix of prev is known.
+func (ngrams callNgrams) choose(r *rand.Rand, prev []*Call) int {
+	seq := []int32{ngramStart}
+	if len(prev) >= ngramOrder {
+		seq = nil
+		prev = prev[len(prev)-ngramOrder:]
+	}
+	for _, c := range prev {
+		seq = append(seq, int32(c.Meta.ID))
+	}
+	for n := len(seq); n > 0; n-- {
+		next := ngrams[makeNgramKey(seq[len(seq)-n:])]
+		if next == nil {
+			continue
+		}
+		x := int32(r.Intn(int(next.run[len(next.run)-1])) + 1)
+		return next.calls[sort.Search(len(next.run), func(i int) bool {
+			return next.run[i] >= x
+		})]
+	}
+	return -1
+}
+
 const (
 	prioLow  = 10
 	prioHigh = 1000
@@ -186,6 +280,14 @@ type ChoiceTable struct {
 	runs            [][]int32
 	calls           []*Syscall
 	noGenerateCalls map[int]bool
+	predictor       CallPredictor
+	predictProb     int
+	predictArgProb  int
+	callPolicy      PredictionCallPolicy
+	promptLevel     PromptLevel
+	strictRepair    bool
+	ngrams          callNgrams
+	opSched         *OpScheduler
 }
 
 func (target *Target) BuildChoiceTable(corpus []*Prog, enabled map[*Syscall]bool) *ChoiceTable {
@@ -241,7 +343,60 @@ func (target *Target) BuildChoiceTable(corpus []*Prog, enabled map[*Syscall]bool
 			run[i][j] = sum
 		}
 	}
-	return &ChoiceTable{target, run, generatableCalls, noGenerateCalls}
+	return &ChoiceTable{
+		target:          target,
+		runs:            run,
+		calls:           generatableCalls,
+		noGenerateCalls: noGenerateCalls,
+		ngrams:          target.calcNgrams(corpus, enabled),
+	}
+}
+
+// SetPredictor makes mutations delegate prob percent of call insertions to pred.
+// A nil pred disables predicted insertions.
+func (ct *ChoiceTable) SetPredictor(pred CallPredictor, prob int) {
+	ct.predictor = pred
+	ct.predictProb = prob
+}
+
+// SetArgPredictionProb makes mutations delegate prob percent of argument mutations to the predictor.
+func (ct *ChoiceTable) SetArgPredictionProb(prob int) {
+	ct.predictArgProb = prob
+}
+
+// SetPredictionCallPolicy sets the policy for predicted calls that are not enabled in the table.
+func (ct *ChoiceTable) SetPredictionCallPolicy(policy PredictionCallPolicy) {
+	ct.callPolicy = policy
+}
+
+// SetPromptLevel sets how much of the program is sent to the predictor.
+func (ct *ChoiceTable) SetPromptLevel(level PromptLevel) {
+	ct.promptLevel = level
+}
+
+// SetStrictRepair makes predictions that don't match the descriptions fail instead of being repaired,
+// see DeserializeSyzLLM.
+func (ct *ChoiceTable) SetStrictRepair(strict bool) {
+	ct.strictRepair = strict
+}
+
+// SetOpScheduler makes Mutate choose mutation operators with s, if it's adaptive.
+func (ct *ChoiceTable) SetOpScheduler(s *OpScheduler) {
+	ct.opSched = s
+}
+
+// predictorAvailable says if the predictor is set and can be used now, see PredictorHealth.
+func (ct *ChoiceTable) predictorAvailable() bool {
+	if ct.predictor == nil {
+		return false
+	}
+	health, ok := ct.predictor.(PredictorHealth)
+	return !ok || health.Available()
+}
+
+// predictionAllowed says if a predicted call can be inserted into programs.
+func (ct *ChoiceTable) predictionAllowed(meta *Syscall) bool {
+	return ct.Enabled(meta.ID) || ct.callPolicy != nil && !meta.Attrs.Disabled && ct.callPolicy.AllowPredictedCall(meta)
 }
 
 func (ct *ChoiceTable) Enabled(call int) bool {
@@ -252,6 +407,15 @@ func (ct *ChoiceTable) Generatable(call int) bool {
 	return ct.runs[call] != nil
 }
 
+// prio returns the priority of inserting call into a program that contains bias.
+func (ct *ChoiceTable) prio(bias, call int) int32 {
+	run := ct.runs[bias]
+	if call == 0 {
+		return run[0]
+	}
+	return run[call] - run[call-1]
+}
+
 func (ct *ChoiceTable) choose(r *rand.Rand, bias int) int {
 	if bias < 0 {
 		bias = ct.calls[r.Intn(len(ct.calls))].ID
@@ -282,3 +446,14 @@ func (ct *ChoiceTable) choose(r *rand.Rand, bias int) int {
 
 	return res
 }
+
+// chooseNext chooses a call to insert after the calls prev.
+// Part of the choices follow the n-gram model of the corpus, the rest are biased by bias as in choose.
+func (ct *ChoiceTable) chooseNext(r *rand.Rand, bias int, prev []*Call) int {
+	if ct.ngrams != nil && r.Intn(100) < ngramProb {
+		if call := ct.ngrams.choose(r, prev); call >= 0 {
+			return call
+		}
+	}
+	return ct.choose(r, bias)
+}
@@ -82,7 +82,7 @@ func TestPrioDeterminism(t *testing.T) {
 	}
 	ct0 := target.BuildChoiceTable(corpus, nil)
 	ct1 := target.BuildChoiceTable(corpus, nil)
-	if !reflect.DeepEqual(ct0.runs, ct1.runs) {
+	if !reflect.DeepEqual(ct0.runs, ct1.runs) || !reflect.DeepEqual(ct0.ngrams, ct1.ngrams) {
 		t.Fatal("non-deterministic ChoiceTable")
 	}
 	for i := 0; i < iters; i++ {
@@ -94,3 +94,76 @@ func TestPrioDeterminism(t *testing.T) {
 		}
 	}
 }
+
+func TestNgramChoice(t *testing.T) {
+	target, rs, _ := initRandomTargetTest(t, "test", "64")
+	var corpus []*Prog
+	for _, text := range []string{
+		"mutate0()\nmutate1()\nmutate2()\n",
+		"mutate1()\nmutate0()\nmutate0()\n",
+	} {
+		p, err := target.Deserialize([]byte(text), Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corpus = append(corpus, p)
+	}
+	calls := func(names ...string) []*Call {
+		var res []*Call
+		for _, name := range names {
+			res = append(res, MakeCall(target.SyscallMap[name], nil))
+		}
+		return res
+	}
+	tests := []struct {
+		prev []*Call
+		want []string
+	}{
+		{nil, []string{"mutate0", "mutate1"}},
+		{calls("mutate0", "mutate1"), []string{"mutate2"}},
+		{calls("mutate1", "mutate0"), []string{"mutate0"}},
+		// Backs off to the known suffix.
+		{calls("mutate2", "mutate0", "mutate1"), []string{"mutate2"}},
+		{calls("mutate3"), nil},
+	}
+	ct := target.BuildChoiceTable(corpus, nil)
+	r := rand.New(rs)
+	for i, test := range tests {
+		for it := 0; it < 10; it++ {
+			got := ct.ngrams.choose(r, test.prev)
+			if len(test.want) == 0 {
+				if got != -1 {
+					t.Fatalf("test #%v: chose %v for an unknown sequence", i, target.Syscalls[got].Name)
+				}
+				continue
+			}
+			if got < 0 || target.Syscalls[got].Name != test.want[0] &&
+				(len(test.want) < 2 || target.Syscalls[got].Name != test.want[1]) {
+				t.Fatalf("test #%v: chose %v, want one of %v", i, got, test.want)
+			}
+		}
+	}
+	// Insertions follow the model in ngramProb percent of cases.
+	const n = 1000
+	hits := 0
+	for i := 0; i < n; i++ {
+		if call := ct.chooseNext(r, -1, calls("mutate0", "mutate1")); target.Syscalls[call].Name == "mutate2" {
+			hits++
+		}
+	}
+	if hits < n*ngramProb/100*8/10 {
+		t.Fatalf("n-gram model was followed in %v/%v insertions", hits, n)
+	}
+	// Calls that are not enabled are never chosen.
+	enabled := make(map[*Syscall]bool)
+	for _, name := range []string{"mutate0", "mutate1"} {
+		enabled[target.SyscallMap[name]] = true
+	}
+	ngrams := target.calcNgrams(corpus[:1], enabled)
+	if got := ngrams.choose(r, calls("mutate0", "mutate1")); got != -1 {
+		t.Fatalf("chose disabled call %v", target.Syscalls[got].Name)
+	}
+	if ct := target.BuildChoiceTable(nil, nil); ct.ngrams != nil {
+		t.Fatalf("n-gram model without corpus")
+	}
+}
@@ -12,6 +12,12 @@ type Prog struct {
 	Target   *Target
 	Calls    []*Call
 	Comments []string
+	// Predicted is set by Mutate if the program contains a call inserted by a CallPredictor.
+	// It is neither serialized nor cloned.
+	Predicted *PredictedCall
+	// MutationOps are the operators applied by Mutate, see OpScheduler.
+	// They are neither serialized nor cloned.
+	MutationOps []MutationOp
 }
 
 // These properties are parsed and serialized according to the tag and the type
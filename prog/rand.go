@@ -563,12 +563,13 @@ func (r *randGen) generateCall(s *state, p *Prog, insertionPoint int) []*Call {
 	if insertionPoint > 0 {
 		// Choosing the base call is based on the insertion point of the new calls sequence.
 		insertionCall := p.Calls[r.Intn(insertionPoint)].Meta
-		if !insertionCall.Attrs.NoGenerate {
-			// We must be careful not to bias towards a non-generatable call.
+		if s.ct.Generatable(insertionCall.ID) {
+			// We must be careful not to bias towards a non-generatable call
+			// (e.g. a predicted call that is not enabled in the table).
 			biasCall = insertionCall.ID
 		}
 	}
-	idx := s.ct.choose(r.Rand, biasCall)
+	idx := s.ct.chooseNext(r.Rand, biasCall, p.Calls[:insertionPoint])
 	meta := r.target.Syscalls[idx]
 	return r.generateParticularCall(s, meta)
 }
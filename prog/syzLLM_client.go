@@ -1,34 +1,14 @@
 package prog
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/google/syzkaller/pkg/log"
-	"io"
-	"net/http"
+	"math"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 )
 
-type ServerConfig struct {
-	Port     string
-	Host     string
-	HostName string
-}
-
-func getServerConfig() ServerConfig {
-	var parallel = ServerConfig{
-		Port:     "6678",
-		Host:     "10.211.55.4",
-		HostName: "parallel",
-	}
-
-	return parallel
-}
-
 func InsertMaskToSequence(sequence []string, position int) []string {
 	return Insert(sequence, MASK, position)
 }
@@ -42,26 +22,60 @@ func Insert(sequence []string, target string, position int) []string {
 	return result
 }
 
-const MASK = "[MASK]"
+const (
+	MASK = "[MASK]"
+	UNK  = "[UNK]"
+	CLS  = "[CLS]" // start of a sequence
+	SEP  = "[SEP]" // end of a sequence
+)
 
 type SyscallData struct {
 	Syscalls []string
 }
 
+// SyzLLMResponse is the server reply for a single masked sequence.
+// Candidates holds the server's top-k calls ranked by decreasing score,
+// Syscall is the best candidate and is kept for older servers that don't send Candidates.
+// ID is an opaque identifier of the response that is sent back with outcome records.
 type SyzLLMResponse struct {
-	State   int
+	State      int
+	ID         string
+	Syscall    string
+	Candidates []SyzLLMCandidate
+}
+
+type SyzLLMCandidate struct {
 	Syscall string
+	Score   float64
+}
+
+func (resp *SyzLLMResponse) predictions() []Prediction {
+	if len(resp.Candidates) == 0 {
+		return []Prediction{{Call: resp.Syscall, Score: 1, ID: resp.ID}}
+	}
+	res := make([]Prediction, len(resp.Candidates))
+	for i, cand := range resp.Candidates {
+		res[i] = Prediction{Call: cand.Syscall, Score: cand.Score, ID: resp.ID}
+	}
+	return res
+}
+
+// SyscallBatchData is a request for several masked sequences at once,
+// the server replies with SyzLLMBatchResponse holding one response per sequence.
+type SyscallBatchData struct {
+	Batch [][]string
 }
 
-func enableCalls(program *Prog, table *ChoiceTable) {
+type SyzLLMBatchResponse struct {
+	Responses []SyzLLMResponse
+}
+
+// checkPredictedCalls verifies that calls of the parsed prediction are allowed
+// and drops call properties that can't be used together.
+func checkPredictedCalls(program *Prog, table *ChoiceTable) error {
 	for idx, call := range program.Calls {
-		if !table.Enabled(call.Meta.ID) {
-			table.runs[call.Meta.ID] = make([]int32, max(1, len(table.target.Syscalls)))
-			for i := range table.runs[call.Meta.ID] {
-				table.runs[call.Meta.ID][i] = 1
-			}
-			table.noGenerateCalls[call.Meta.ID] = true
-			// remove previous calls
+		if !table.predictionAllowed(call.Meta) {
+			return fmt.Errorf("%w: %v", ErrPredictedCallDisabled, call.Meta.Name)
 		}
 		if call.Props.Rerun > 0 && call.Props.FailNth > 0 {
 			if idx%2 == 0 {
@@ -70,382 +84,180 @@ func enableCalls(program *Prog, table *ChoiceTable) {
 				call.Props.FailNth = 0
 			}
 		}
-
 	}
+	return nil
 }
 
-func (ctx *mutator) requestNewCallAsync(program *Prog, insertPosition int, choiceTable *ChoiceTable) []*Call {
-	normalizedMaskedSyscallList, maskedSyscallList := addMaskToCalls(program, insertPosition)
-	jsonData, err := json.Marshal(SyscallData{Syscalls: normalizedMaskedSyscallList})
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return program.Calls
+// requestNewCall asks the predictor for a call to insert at insertPosition
+// and returns the resulting call sequence. Candidates are tried in the order
+// chosen by orderPredictions until one of them is successfully inserted.
+func (ctx *mutator) requestNewCall(program *Prog, insertPosition int) ([]*Call, PredictionResult, error) {
+	norm := NormalizeProg(program, ctx.ct.promptLevel)
+	request := InsertMaskToSequence(norm.Calls, insertPosition)
+	if ctx.generate {
+		request = append([]string{CLS}, request...)
 	}
-
-	var serviceConfig = getServerConfig()
-	url := fmt.Sprintf("http://%s:%s", serviceConfig.Host, serviceConfig.Port)
-	client := GetClient()
-	//resp, err := client.SendPostRequest(url, jsonData)
-	//if err != nil {
-	//	fmt.Println("Error reading response:", err)
-	//	return program.Calls
-	//}
-	//defer resp.Body.Close()
-
-	var resp *http.Response
-	responseChan, errorChan := client.SendPostRequestAsync(url, jsonData)
-	select {
-	case respC := <-responseChan:
-		if respC != nil {
-			resp = respC
+	var predictions []Prediction
+	var err error
+	if blocking, ok := ctx.ct.predictor.(BlockingCallPredictor); ok && ctx.generate {
+		predictions, err = blocking.PredictWait(request)
+	} else {
+		predictions, err = ctx.ct.predictor.Predict(request)
+	}
+	if ctx.record != nil {
+		ctx.record.Request = request
+		ctx.record.Predictions = predictions
+	}
+	switch {
+	case errors.Is(err, ErrPredictionPending):
+		return nil, PredictionPending, err
+	case errors.Is(err, ErrPredictionRejected):
+		return nil, PredictionRejected, err
+	case err != nil:
+		return nil, PredictionServerError, err
+	case len(predictions) == 0:
+		return nil, PredictionRejected, ErrPredictionRejected
+	}
+	bias := ctx.predictionBias(program, insertPosition)
+	for _, prediction := range ctx.orderPredictions(program, bias, predictions) {
+		if prediction.Call == SEP {
+			// The model says that the sequence ends here.
+			err = errSequenceEnd
+			if ctx.generate {
+				return nil, PredictionUnchanged, err
+			}
+			continue
 		}
-	case err := <-errorChan:
+		var calls []*Call
+		calls, err = ctx.insertPrediction(program, insertPosition, bias, prediction.Call, norm)
 		if err != nil {
-			fmt.Println("Error reading response:", err)
-			return program.Calls
+			continue
 		}
-	}
-
-	responseByte, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response:", err)
-		return program.Calls
-	}
-
-	//var syzLLMResponse SyzLLMResponse
-	syzLLMResponse := SyzLLMResponse{-1, ""}
-	err = json.Unmarshal(responseByte, &syzLLMResponse)
-	if err != nil {
-		fmt.Println("Error unmarshaling response:", err)
-		return program.Calls
-	}
-	if syzLLMResponse.State != 0 {
-		return program.Calls
-	}
-
-	newCall := ProcessDescriptor(syzLLMResponse.Syscall)
-	calls := ParseNestedResources(newCall, maskedSyscallList, insertPosition)
-	newSyscallSequence := ""
-	for _, call := range calls {
-		if len(newSyscallSequence) > 0 {
-			newSyscallSequence += "\n"
+		if len(calls) == len(program.Calls) {
+			return nil, PredictionUnchanged, fmt.Errorf("program length is unchanged")
 		}
-		newSyscallSequence += call
+		// Calls producing resources for the predicted call are inserted before it.
+		ctx.predictedCall = calls[insertPosition+len(calls)-len(program.Calls)-1]
+		ctx.predicted = &PredictedCall{ID: prediction.ID, Call: prediction.Call}
+		return calls, PredictionInserted, nil
 	}
-	newSyscallBytes := []byte(newSyscallSequence)
-	newProg, err := program.Target.Deserialize(newSyscallBytes, NonStrict)
-	if err != nil {
-		return program.Calls
+	if errors.Is(err, ErrPredictedCallDisabled) {
+		return nil, PredictionDisabled, err
 	}
-
-	enableCalls(newProg, choiceTable)
-
-	return newProg.Calls
-}
-
-type Client struct {
-	client *http.Client
-	once   sync.Once
+	return nil, PredictionParseError, err
 }
 
-var instance *Client
-
-func GetClient() *Client {
-	if instance == nil {
-		instance = &Client{}
-		instance.once.Do(func() {
-			instance.client = &http.Client{}
-		})
+func (ctx *mutator) observePrediction(res PredictionResult) {
+	obs, ok := ctx.ct.predictor.(PredictionObserver)
+	if !ok {
+		return
 	}
-	return instance
+	call := ""
+	if res == PredictionInserted {
+		call = ctx.predictedCall.Meta.Name
+	}
+	obs.ObservePrediction(res, call)
 }
 
-func (c *Client) SendPostRequestAsync(url string, jsonData []byte) (<-chan *http.Response, <-chan error) {
-	responseChan := make(chan *http.Response, 1)
-	errorChan := make(chan error, 1)
-
-	go func() {
-		defer close(responseChan)
-		defer close(errorChan)
-
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			errorChan <- fmt.Errorf("error creating request: %w", err)
-			return
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := c.client.Do(req)
-		if err != nil {
-			errorChan <- fmt.Errorf("error executing request: %w", err)
-			return
+// predictionBias returns a random call preceding the insertion point (the same bias insertCall uses),
+// or -1 if there is no suitable call.
+func (ctx *mutator) predictionBias(program *Prog, insertPosition int) int {
+	if insertPosition > 0 {
+		if meta := program.Calls[ctx.r.Intn(insertPosition)].Meta; ctx.ct.Generatable(meta.ID) {
+			return meta.ID
 		}
-
-		responseChan <- resp
-	}()
-
-	return responseChan, errorChan
-}
-
-func (c *Client) SendPostRequest(url string, jsonData []byte) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Println("Error sending JSON:", err)
-		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		fmt.Println("Error reading response:", err)
-		return nil, err
-	}
-
-	return resp, nil
+	return -1
 }
 
-const (
-	RPrefix = "@RSTART@"
-	RSuffix = "@REND@"
-)
-
-func ParseNestedResources(call string, calls []string, insertPosition int) []string {
-	calls[insertPosition] = call
-	if !HaveResTag(call) && len(ResNumberPattern.FindStringSubmatch(call)) >= 2 {
-		calls[insertPosition] = UpdateResourceCount(call, calls, insertPosition, false, 0)
-		return calls
-	}
-
-	nestCnt := 0
-	for {
-		updated := false
-		updatedCalls := cloneSlice(calls)
-		for i, c := range calls {
-			if HaveResTag(c) {
-				updatedCalls = ParseSingleResource(c, updatedCalls, i, nestCnt)
-				nestCnt += 1
-				updated = true
-			}
+// orderPredictions returns predictions in the order they should be tried.
+// Candidates are sampled without replacement with probability proportional to the
+// model score multiplied by the ChoiceTable priority of the predicted call with respect to bias.
+func (ctx *mutator) orderPredictions(program *Prog, bias int, predictions []Prediction) []Prediction {
+	weights := make([]float64, len(predictions))
+	for i, pred := range predictions {
+		// Unknown calls and zero scores still get a small chance.
+		prio := ctx.predictionPrio(program.Target, bias, callNameFromText(pred.Call))
+		if prio < 0 {
+			prio = 0.5
 		}
-		if !updated {
-			for i, c := range calls {
-				if strings.Contains(c, "@PIPESTART@") && strings.Contains(c, "@PIPEEND@") {
-					updatedCalls = ParsePipeResource(c, updatedCalls, i)
-				}
+		weights[i] = math.Max(pred.Score, 1e-3) * math.Max(prio, 1e-3)
+	}
+	res := make([]Prediction, 0, len(predictions))
+	used := make([]bool, len(predictions))
+	for len(res) < len(predictions) {
+		var sum float64
+		for i, w := range weights {
+			if !used[i] {
+				sum += w
 			}
-			calls = updatedCalls
-			break
 		}
-		calls = updatedCalls
-	}
-	return calls
-}
-
-func isEqual(slice1, slice2 []string) bool {
-	if len(slice1) != len(slice2) {
-		return false
-	}
-	for i, v := range slice1 {
-		if v != slice2[i] {
-			return false
+		x := ctx.r.Float64() * sum
+		choice := -1
+		for i, w := range weights {
+			if used[i] {
+				continue
+			}
+			choice = i
+			if x -= w; x < 0 {
+				break
+			}
 		}
+		used[choice] = true
+		res = append(res, predictions[choice])
 	}
-	return true
-}
-
-func cloneSlice(original []string) []string {
-	cloned := make([]string, len(original))
-	copy(cloned, original)
-	return cloned
-}
-
-func HaveResTag(call string) bool {
-	if strings.Contains(call, RPrefix) && strings.Contains(call, RSuffix) {
-		return true
-	}
-	return false
+	return res
 }
 
-func ParsePipeResource(call string, calls []string, insertPosition int) []string {
-	newCalls := make([]string, len(calls))
-	copy(newCalls, calls)
-
-	pipeStart := "@PIPESTART@"
-	pipeEnd := "@PIPEEND@"
-	if !strings.Contains(call, pipeStart) || !strings.Contains(call, pipeEnd) {
-		return newCalls
-	}
-
-	has, ids := HasPipeBeforeInsertPosition(calls, insertPosition)
-	if has {
-		newCalls[insertPosition] = ReplaceMultipleBetween(call, pipeStart, pipeEnd, ids)
-		return newCalls
-	}
-
-	nextResource := GetNextResource(calls, insertPosition)
-
-	startIdx := strings.Index(call, pipeStart) + len(pipeStart)
-	endIdx := strings.Index(call, pipeEnd)
-	pipeCall := call[startIdx:endIdx]
-	pipeCallResNumUpdated := strings.Replace(pipeCall, "<r1=>", "<r"+strconv.Itoa(nextResource+1)+"=>", 1)
-	pipeCallResNumUpdated = strings.Replace(pipeCallResNumUpdated, "<r0=>", "<r"+strconv.Itoa(nextResource)+"=>", 1)
-
-	numPipeResources := strings.Count(call, pipeStart)
-	modifiedCall := call
-	for i := 0; i < numPipeResources; i++ {
-		start := strings.Index(modifiedCall, pipeStart)
-		end := strings.Index(modifiedCall[start:], pipeEnd) + start + len(pipeEnd)
-		if start == -1 || end <= start {
-			break
+// insertPrediction inserts a single predicted call into the program.
+// norm is the normalization of the program the prediction was requested for.
+func (ctx *mutator) insertPrediction(program *Prog, insertPosition, bias int, prediction string,
+	norm *Normalization) ([]*Call, error) {
+	if !strings.Contains(prediction, "(") {
+		// Just a syscall name, arguments need to be generated.
+		meta := ctx.resolvePredictedSyscall(program, insertPosition, bias, prediction)
+		if meta == nil {
+			return nil, fmt.Errorf("unknown predicted call %v", prediction)
 		}
-		resourceName := "r" + strconv.Itoa(nextResource+i)
-		modifiedCall = modifiedCall[:start] + resourceName + modifiedCall[end:]
+		return ctx.generatePredictedCall(program, insertPosition, meta)
 	}
 
-	numNewResources := 2
-	result := make([]string, 0, len(calls)+1)
-	result = append(result, calls[:insertPosition]...)
-	result = append(result, pipeCallResNumUpdated)
-	result = append(result, modifiedCall)
-	for _, c := range calls[insertPosition+1:] {
-		updatedCall := updateResourceNumbers(c, nextResource, numNewResources)
-		result = append(result, updatedCall)
-	}
-
-	return result
+	newCall := ctx.resolveDescriptors(program, insertPosition, bias, prediction)
+	return ctx.bindPredictedCall(program, insertPosition, newCall, norm)
 }
 
-var ResourceIDsRegex = regexp.MustCompile(`<r(\d+)=>`)
-
-func ExtractResourceIDs(s string) []string {
-	matches := ResourceIDsRegex.FindAllStringSubmatch(s, -1)
-	ids := make([]string, 0, len(matches))
-	for _, match := range matches {
-		if len(match) > 1 {
-			ids = append(ids, "r"+match[1])
-		}
+// generatePredictedCall handles predictions that name a syscall without arguments:
+// the call is generated as insertCall would do it, just without consulting the ChoiceTable.
+func (ctx *mutator) generatePredictedCall(program *Prog, insertPosition int, meta *Syscall) ([]*Call, error) {
+	if !ctx.ct.predictionAllowed(meta) {
+		return nil, fmt.Errorf("%w: %v", ErrPredictedCallDisabled, meta.Name)
 	}
-	return ids
-}
-
-func HasPipeBeforeInsertPosition(calls []string, insertPosition int) (has bool, replacements []string) {
-	for i := insertPosition - 1; i >= 0 && i < len(calls); i-- {
-		if strings.HasPrefix(calls[i], "pipe") {
-			replacements = ExtractResourceIDs(calls[i])
-			return true, replacements
-		}
+	if meta.Attrs.NoGenerate {
+		return nil, fmt.Errorf("predicted call %v is not generatable", meta.Name)
 	}
-	return false, replacements
-}
-
-func ReplaceMultipleBetween(s, prefix, suffix string, replacements []string) string {
-	result := s
-	for _, replacement := range replacements {
-		startIdx := strings.Index(result, prefix)
-		if startIdx == -1 {
-			break
-		}
-		endIdx := strings.Index(result[startIdx:], suffix)
-		if endIdx == -1 {
-			break
-		}
-		endIdx += startIdx + len(suffix)
-		result = result[:startIdx] + replacement + result[endIdx:]
+	var c *Call
+	if insertPosition < len(program.Calls) {
+		c = program.Calls[insertPosition]
 	}
-	return result
+	s := analyze(ctx.ct, ctx.corpus, program, c)
+	newCalls := ctx.r.generateParticularCall(s, meta)
+	calls := make([]*Call, 0, len(program.Calls)+len(newCalls))
+	calls = append(calls, program.Calls[:insertPosition]...)
+	calls = append(calls, newCalls...)
+	calls = append(calls, program.Calls[insertPosition:]...)
+	return calls, nil
 }
 
-func GetNextResource(calls []string, insertPosition int) int {
-	maxN := -1
-	re := regexp.MustCompile(`^r(\d+) = `)
-	for i := 0; i < insertPosition && i < len(calls); i++ {
-		matches := re.FindStringSubmatch(calls[i])
-		if matches != nil {
-			if n, err := strconv.Atoi(matches[1]); err == nil && n > maxN {
-				maxN = n
-			}
-		}
-	}
-	return maxN + 1
-}
-
-func updateResourceNumbers(call string, nextResource, numNewResources int) string {
-	re := regexp.MustCompile(`r(\d+)`)
-	return re.ReplaceAllStringFunc(call, func(match string) string {
-		n, err := strconv.Atoi(match[1:])
-		if err != nil {
-			return match
-		}
-		if n >= nextResource {
-			return "r" + strconv.Itoa(n+numNewResources)
-		}
-		return match
-	})
-}
-
-// ParseResource
-// replace PrefixCallSuffix to r*
-func ParseSingleResource(call string, calls []string, insertPosition int, nestCnt int) []string {
-	var providerText string
-	ParseInner := func(prefixedName string, name string, extractedCall string) string {
-		resCount := 0
-		for i, c := range calls {
-			if count := HasResource(c); count > 0 && i < insertPosition {
-				resCount += count
-			}
-			startIdx := strings.Index(c, prefixedName)
-			if startIdx != -1 && i < insertPosition {
-				res := c[0:startIdx]
-				return res
-			}
-			if i >= insertPosition {
-				res := "r" + strconv.Itoa(resCount)
-				providerText = res + " = " + extractedCall
-				return res
-				/*s := newState(r.target, r.target.DefaultChoiceTable(), nil)
-				meta, exist := GetCallMetaInstance().Get(name)
-				if !exist {
-					return ""
-				}
-				resProvider := r.generateParticularCall(s, meta)
-				p := &Prog{
-					Target:   r.target,
-					Calls:    resProvider,
-					Comments: nil,
-				}
-				providerText := strings.Split(string(p.Serialize()[:]), "\n")
-				for _, callText := range providerText {
-					idx := strings.Index(callText, prefixedName)
-					if idx != -1 {
-						resProviders = append(resProviders, providerText...)
-						res := callText[0:idx]
-						return res
-					}
-				}
-				return ""*/
-			}
-		}
-		return ""
-	}
-
-	parsedCall := ReplaceContentWithinTags(call, func(match string) string {
-		matchWithoutTags := match
-		if strings.HasPrefix(matchWithoutTags, RPrefix) && strings.HasSuffix(matchWithoutTags, RSuffix) {
-			matchWithoutTags = matchWithoutTags[len(RPrefix) : len(matchWithoutTags)-len(RSuffix)]
-		} else {
-			return match
-		}
-		// submatch should look like open$at(0x111, ...)
-		callName := ExtractCallNameFromCallWithinTags(matchWithoutTags)
-		return ParseInner(" = "+callName, callName, matchWithoutTags)
-	})
-	parsedCall = UpdateResourceCount(parsedCall, calls, insertPosition, len(providerText) > 0, nestCnt)
+const (
+	RPrefix = "@RSTART@"
+	RSuffix = "@REND@"
+)
 
-	calls[insertPosition] = parsedCall
-	if len(providerText) > 0 {
-		calls = append(calls[:insertPosition], append([]string{providerText}, calls[insertPosition:]...)...)
+func HaveResTag(call string) bool {
+	if strings.Contains(call, RPrefix) && strings.Contains(call, RSuffix) {
+		return true
 	}
-	return calls
+	return false
 }
 
 func ReplaceContentWithinTags(data string, repl func(string) string) string {
@@ -486,124 +298,10 @@ var CallNamePattern = regexp.MustCompile(`^([a-zA-Z0-9_]+)(\(|\$)`)
 func ExtractCallNameFromCallWithinTags(call string) string {
 	match := CallNamePattern.FindStringSubmatch(call)
 
-	if len(match) > 1 {
-		return match[1]
-	} else {
+	if len(match) <= 1 {
 		panic("Wrong syscall: no brackets")
 	}
-	return ""
-}
-
-func HasResource(call string) int {
-	if len(call) <= 0 {
-		return 0
-	}
-
-	if strings.HasPrefix(call, "pipe") {
-		matches := ResourceIDsRegex.FindAllStringSubmatch(call, -1)
-		return len(matches)
-	}
-
-	if call[0] != 'r' {
-		return 0
-	}
-	for _, c := range call {
-		switch c {
-		case '(':
-			return 0
-		case '=':
-			return 1
-		default:
-			continue
-		}
-	}
-	return 0
-}
-
-func UpdateResourceCount(call string, calls []string, insertPosition int, hasProvider bool, nestCnt int) string {
-	resCountBeforeInsertPosition := 0
-	for i, c := range calls {
-		if i >= insertPosition {
-			break
-		}
-		resCountBeforeInsertPosition += HasResource(c)
-	}
-
-	offset := 0
-	if hasProvider {
-		resCountBeforeInsertPosition += 1
-		offset += 1
-	}
-
-	if HasResource(call) > 0 {
-		resNumber, _ := ExtractResourceNumber(call)
-		call = AssignResource(call, resCountBeforeInsertPosition)
-		calls[insertPosition] = call
-		if nestCnt > 0 {
-			for i := insertPosition + 1; i < len(calls); i++ {
-				calls[i] = strings.Replace(calls[i], "r"+strconv.Itoa(resNumber)+",", "r"+strconv.Itoa(resNumber+offset)+",", -1)
-				calls[i] = strings.Replace(calls[i], "r"+strconv.Itoa(resNumber)+")", "r"+strconv.Itoa(resNumber+offset)+")", -1)
-				calls[i] = strings.Replace(calls[i], "r"+strconv.Itoa(resNumber)+"}", "r"+strconv.Itoa(resNumber+offset)+"}", -1)
-			}
-		} else if nestCnt == 0 {
-			offset += 1
-		}
-	}
-
-	for i := insertPosition + 1; offset > 0 && i < len(calls); i++ {
-		resNumber, hasRes := ExtractResourceNumber(calls[i])
-		if hasRes {
-			calls[i] = AssignResource(calls[i], resNumber+offset)
-			for j := i + 1; j < len(calls); j++ {
-				calls[j] = strings.Replace(calls[j], "r"+strconv.Itoa(resNumber)+",", "r"+strconv.Itoa(resNumber+offset)+",", -1)
-				calls[j] = strings.Replace(calls[j], "r"+strconv.Itoa(resNumber)+")", "r"+strconv.Itoa(resNumber+offset)+")", -1)
-				calls[j] = strings.Replace(calls[j], "r"+strconv.Itoa(resNumber)+"}", "r"+strconv.Itoa(resNumber+offset)+"}", -1)
-			}
-		}
-	}
-
-	return call
-}
-
-var ResourcePattern = regexp.MustCompile(`^r\d+ = `)
-
-func AssignResource(call string, resNumber int) string {
-	newNumberStr := "r" + strconv.Itoa(resNumber)
-	result := ResourcePattern.ReplaceAllString(call, newNumberStr+" = ")
-
-	return result
-}
-
-var ResNumberPattern = regexp.MustCompile(`^r(\d+) = `)
-
-func ExtractResourceNumber(call string) (int, bool) {
-	match := ResNumberPattern.FindStringSubmatch(call)
-
-	hasRes := true
-	if len(match) < 2 {
-		hasRes = false
-		return -1, hasRes
-	}
-
-	number, err := strconv.Atoi(match[1])
-	if err != nil {
-		log.Fatalf("failed to convert number: %v", err)
-	}
-
-	return number, hasRes
-}
-
-func addMaskToCalls(program *Prog, insertPosition int) ([]string, []string) {
-	syscallList := parseProgToSlice(program)
-
-	TryNormalizeArgs(program)
-	normalizedSyscallList := parseProgToSlice(program)
-
-	for i, call := range normalizedSyscallList {
-		normalizedSyscallList[i] = ConvertAnyBlob(call)
-	}
-
-	return InsertMaskToSequence(normalizedSyscallList, insertPosition), InsertMaskToSequence(syscallList, insertPosition)
+	return match[1]
 }
 
 func parseProgToSlice(program *Prog) []string {
@@ -647,35 +345,17 @@ func CopyCounter() map[string]uint64 {
 	return counter
 }
 
-func NormalizeArgs(program *Prog) {
-	for i, call := range program.Calls {
-		fields := call.Meta.Args
-		for j, _arg := range call.Args {
-			switch arg := _arg.(type) {
-			case *ResultArg:
-				continue
-			default:
-				argReplacer := NewArgReplacer(call.Meta.Name)
-				program.Calls[i].Args[j] = argReplacer.DFSArgs(arg, fields[j])
-			}
-		}
-	}
-}
-
-func TryNormalizeArgs(program *Prog) {
-	oldProgram := program
-	defer func() {
-		if r := recover(); r != nil {
-			program = oldProgram
-		}
-	}()
-	NormalizeArgs(program)
-}
-
+// ArgReplacer normalizes arguments of a call in place. If norm is set, the replaced
+// values are recorded in it. In the denormalize mode placeholders are replaced back
+// with the values recorded in norm.
 type ArgReplacer struct {
 	currentCallName string
 	InitAddrCnt     int
 	AddrCounter     map[string]uint64
+	level           PromptLevel
+	norm            *Normalization
+	callIdx         int  // index of the call in the program, for norm
+	denormalize     bool // replace placeholders with values from norm
 }
 
 func NewArgReplacer(callName string) *ArgReplacer {
@@ -708,6 +388,14 @@ func (a *ArgReplacer) GenerateDataArg(dataArg *DataArg, fieldType Type) Arg {
 	const BufferSize = 0x400
 	data := make([]byte, 0)
 
+	if a.level == PromptFull {
+		return dataArg
+	}
+	key := normKey{fieldType.Name(), "", dataArg.Dir()}
+	if a.denormalize {
+		return a.norm.restoreData(dataArg, key, a.callIdx, BufferSize, Path)
+	}
+	a.norm.record(key, a.callIdx, dataArg.Size(), dataArg)
 	if dataArg.ArgCommon.Dir() == DirOut {
 		return MakeOutDataArg(dataArg.ArgCommon.Type(), dataArg.ArgCommon.Dir(), BufferSize)
 	} else if dataArg.ArgCommon.Dir() == DirIn {
@@ -735,17 +423,32 @@ func (a *ArgReplacer) GenerateConstArg(constArg *ConstArg, fieldType Type, field
 	const FD = ^uint64(0)
 	const NUM = 0x111
 
+	if a.level == PromptFull {
+		return constArg
+	}
+	key := normKey{fieldType.Name(), fieldName, constArg.Dir()}
+	if a.denormalize {
+		if _, ok := fieldType.(*FlagsType); ok || fieldName == "mode" {
+			return constArg
+		}
+		if constArg.Val == NUM || fieldName == "fd" && constArg.Val == FD {
+			return a.norm.restoreConst(constArg, key, a.callIdx)
+		}
+		return constArg
+	}
+
 	switch fieldType.(type) {
 	case *FlagsType:
 		return constArg
 	default:
 		if fieldName == "mode" {
 			return constArg
-		} else if fieldName == "fd" {
+		}
+		a.norm.record(key, a.callIdx, constArg.Val, nil)
+		if fieldName == "fd" {
 			return MakeConstArg(constArg.ArgCommon.Type(), constArg.ArgCommon.Dir(), FD)
-		} else {
-			return MakeConstArg(constArg.ArgCommon.Type(), constArg.ArgCommon.Dir(), NUM)
 		}
+		return MakeConstArg(constArg.ArgCommon.Type(), constArg.ArgCommon.Dir(), NUM)
 	}
 }
 
@@ -796,8 +499,7 @@ func (a *ArgReplacer) GenerateGroupArg(groupArg *GroupArg, fieldType Type) Arg {
 }
 
 func (a *ArgReplacer) GeneratePtrArg(ptrArg *PointerArg, fieldType Type) Arg {
-	addr := a.GetAddr()
-	if addr != 0 {
+	if addr := a.GetAddr(); addr != 0 && !a.denormalize {
 		ptrArg.Address = addr
 	}
 
@@ -0,0 +1,169 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// errNoMask means that the argument can't be masked because it's not serialized (trailing default arguments).
+var errNoMask = errors.New("masked argument is not serialized")
+
+// maskableArgs returns struct and union arguments of c that can be predicted.
+// Output arguments and arguments producing resources used by other calls are skipped.
+func maskableArgs(target *Target, c *Call) []Arg {
+	var args []Arg
+	ForeachArg(c, func(arg Arg, ctx *ArgCtx) {
+		if target.isAnyPtr(arg.Type()) {
+			ctx.Stop = true
+			return
+		}
+		switch arg.(type) {
+		case *GroupArg:
+			if _, ok := arg.Type().(*StructType); !ok {
+				return
+			}
+		case *UnionArg:
+		default:
+			return
+		}
+		if arg.Dir() == DirOut || producesUsedResources(arg) {
+			return
+		}
+		args = append(args, arg)
+	})
+	return args
+}
+
+func producesUsedResources(arg Arg) (res bool) {
+	ForeachSubArg(arg, func(arg1 Arg, ctx *ArgCtx) {
+		if a, ok := arg1.(*ResultArg); ok && len(a.uses) != 0 {
+			res = true
+			ctx.Stop = true
+		}
+	})
+	return
+}
+
+// argIndex returns the index of arg in the ForeachArg order of c's arguments, or -1.
+func argIndex(c *Call, arg Arg) int {
+	idx, res := 0, -1
+	ForeachArg(c, func(arg1 Arg, ctx *ArgCtx) {
+		if arg1 == arg {
+			res = idx
+			ctx.Stop = true
+		}
+		idx++
+	})
+	return res
+}
+
+// argAt returns the argument of c with the given index in the ForeachArg order, or nil.
+func argAt(c *Call, idx int) Arg {
+	var res Arg
+	ForeachArg(c, func(arg Arg, ctx *ArgCtx) {
+		if idx == 0 {
+			res = arg
+		}
+		idx--
+		ctx.Stop = res != nil
+	})
+	return res
+}
+
+// requestArg asks the predictor for the arg of call idx and replaces arg with the first
+// candidate that can be decoded.
+func (ctx *mutator) requestArg(p *Prog, idx int, arg Arg) (PredictionResult, error) {
+	norm := normalizeProg(p, ctx.ct.promptLevel, idx, arg)
+	call := norm.Calls[idx]
+	if !strings.Contains(call, MASK) {
+		return PredictionParseError, errNoMask
+	}
+	predictions, err := ctx.ct.predictor.Predict(norm.Calls)
+	switch {
+	case errors.Is(err, ErrPredictionPending):
+		return PredictionPending, err
+	case errors.Is(err, ErrPredictionRejected):
+		return PredictionRejected, err
+	case err != nil:
+		return PredictionServerError, err
+	case len(predictions) == 0:
+		return PredictionRejected, ErrPredictionRejected
+	}
+	for _, prediction := range predictions {
+		text := strings.Replace(call, MASK, prediction.Call, 1)
+		if err = ctx.replacePredictedArg(p.Calls[idx], arg, text, norm, idx); err == nil {
+			return PredictionArgReplaced, nil
+		}
+	}
+	return PredictionParseError, err
+}
+
+// replacePredictedArg decodes the call text with the predicted argument and replaces arg of c with it.
+// Input resources of the predicted argument are bound to resources that precede c,
+// missing ones are created with producer calls inserted before c.
+func (ctx *mutator) replacePredictedArg(c *Call, arg Arg, text string, norm *Normalization, idx int) error {
+	text, _ = extractResourceHints(text)
+	// Prefer values of the call itself.
+	p1, err := ctx.deserializePrediction(text, norm, idx+1)
+	if err != nil {
+		return err
+	}
+	if len(p1.Calls) != 1 || p1.Calls[0].Meta != c.Meta {
+		return fmt.Errorf("predicted argument changed the call")
+	}
+	arg1 := argAt(p1.Calls[0], argIndex(c, arg))
+	if arg1 == nil || reflect.TypeOf(arg1) != reflect.TypeOf(arg) ||
+		arg1.Type() != arg.Type() || arg1.Dir() != arg.Dir() {
+		return fmt.Errorf("predicted argument does not match the masked one")
+	}
+	var inputs []*ResultArg
+	ForeachSubArg(arg1, func(arg2 Arg, _ *ArgCtx) {
+		if a, ok := arg2.(*ResultArg); ok && a.Dir() != DirOut {
+			inputs = append(inputs, a)
+		}
+	})
+	s := analyze(ctx.ct, ctx.corpus, ctx.p, c)
+	var created []*Call
+	for _, in := range inputs {
+		if in.Res != nil {
+			// References to the other arguments of the predicted call.
+			delete(in.Res.uses, in)
+			in.Res = nil
+		}
+		typ := in.Type().(*ResourceType)
+		if res := ctx.r.existingResource(s, typ, in.Dir()); res != nil {
+			replaceResultArg(in, res.(*ResultArg))
+		} else if res, calls := ctx.createResource(s, typ, in.Dir()); res != nil {
+			created = append(created, calls...)
+			replaceResultArg(in, res)
+		} else if in.Val&predictionResPlaceholderMask == predictionResPlaceholder {
+			replaceResultArg(in, typ.DefaultArg(in.Dir()).(*ResultArg))
+		}
+	}
+	ForeachSubArg(arg1, func(arg2 Arg, _ *ArgCtx) {
+		if a, ok := arg2.(*ResultArg); ok {
+			a.uses = nil
+		}
+	})
+	removeArg(arg)
+	switch a := arg.(type) {
+	case *GroupArg:
+		*a = *arg1.(*GroupArg)
+	case *UnionArg:
+		*a = *arg1.(*UnionArg)
+	}
+	if len(created) != 0 {
+		ctx.p.insertBefore(c, created)
+		for idx += len(created); len(ctx.p.Calls) > ctx.ncalls; {
+			idx--
+			ctx.p.RemoveCall(idx)
+		}
+	}
+	ctx.p.Target.assignSizesCall(c)
+	return nil
+}
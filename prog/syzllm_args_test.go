@@ -0,0 +1,135 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRequestArg(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	tests := []struct {
+		prog        string
+		level       PromptLevel
+		arg         int // index in maskableArgs of the last call
+		predictions []string
+		request     string // the masked call
+		result      PredictionResult
+		want        string
+	}{
+		{
+			prog:        "test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n",
+			level:       PromptFull,
+			arg:         1,
+			predictions: []string{"@f2=0x5"},
+			request:     "test$union0(&(0x7f0000000000)={0x1, [MASK]})",
+			result:      PredictionArgReplaced,
+			want:        "test$union0(&(0x7f0000000000)={0x1, @f2=0x5})\n",
+		},
+		{
+			// Candidates that can't be decoded are skipped.
+			prog:        "test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n",
+			level:       PromptFull,
+			arg:         1,
+			predictions: []string{"@f2=0x5})\nmutate0(", "@f1=[0x1, 0x2]"},
+			request:     "test$union0(&(0x7f0000000000)={0x1, [MASK]})",
+			result:      PredictionArgReplaced,
+			want:        "test$union0(&(0x7f0000000000)={0x1, @f1=[0x1, 0x2]})\n",
+		},
+		{
+			prog:        "test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n",
+			level:       PromptFull,
+			arg:         1,
+			predictions: []string{"@f2=0x5})\nmutate0("},
+			request:     "test$union0(&(0x7f0000000000)={0x1, [MASK]})",
+			result:      PredictionParseError,
+			want:        "test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n",
+		},
+		{
+			// Resources of the predicted argument are bound to the program resources.
+			prog:        "r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)\ntest$syz_union4(@f1=0x1)\n",
+			level:       PromptFull,
+			predictions: []string{"@f4=r7"},
+			request:     "test$syz_union4([MASK])",
+			result:      PredictionArgReplaced,
+			want:        "r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)\ntest$syz_union4(@f4=r0)\n",
+		},
+		{
+			// Missing resources of the predicted argument are created.
+			prog:        "test$syz_union4(@f1=0x1)\n",
+			level:       PromptFull,
+			predictions: []string{"@f4=r7"},
+			request:     "test$syz_union4([MASK])",
+			result:      PredictionArgReplaced,
+			want:        "r0 = fallback$0()\ntest$syz_union4(@f4=r0)\n",
+		},
+		{
+			// Placeholders of the normalized prompt are restored.
+			prog:        "test$struct(&(0x7f0000000000)={0x1234, {0x5}})\n",
+			level:       PromptFlags,
+			arg:         1,
+			predictions: []string{"{0x111}"},
+			request:     "test$struct(&(0x7f0000000000)={0x111, [MASK]})",
+			result:      PredictionArgReplaced,
+			want:        "test$struct(&(0x7f0000000000)={0x1234, {0x5}})\n",
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			p, err := target.Deserialize([]byte(test.prog), Strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var predictions []Prediction
+			for _, call := range test.predictions {
+				predictions = append(predictions, Prediction{Call: call, Score: 1})
+			}
+			pred := &FakePredictor{Predictions: predictions}
+			ct := target.DefaultChoiceTable()
+			ct.SetPredictor(pred, 100)
+			ct.SetPromptLevel(test.level)
+			ctx := &mutator{
+				p:      p,
+				r:      newRand(target, rand.NewSource(0)),
+				ct:     ct,
+				ncalls: 10,
+			}
+			idx := len(p.Calls) - 1
+			args := maskableArgs(target, p.Calls[idx])
+			res, err := ctx.requestArg(p, idx, args[test.arg])
+			if res != test.result {
+				t.Fatalf("got result %v (%v), want %v", res, err, test.result)
+			}
+			if reqs := pred.Requests(); len(reqs) != 1 || reqs[0][idx] != test.request {
+				t.Fatalf("got requests %q, want %q", reqs, test.request)
+			}
+			if err := p.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if data := string(p.Serialize()); data != test.want {
+				t.Fatalf("got program:\n%s\nwant:\n%s", data, test.want)
+			}
+		})
+	}
+}
+
+func TestMutateArgSyzLLM(t *testing.T) {
+	target, rs, iters := initRandomTargetTest(t, "test", "64")
+	ct := target.DefaultChoiceTable()
+	pred := &FakePredictor{Predictions: []Prediction{{Call: "{0x1, 0x2}"}, {Call: "@f0=0x1"}}}
+	ct.SetPredictor(pred, 0)
+	ct.SetArgPredictionProb(100)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		p.Mutate(rs, 10, ct, nil, nil)
+		if err := p.validate(); err != nil {
+			t.Fatalf("invalid program: %v\n%s", err, p.Serialize())
+		}
+	}
+	if len(pred.Requests()) == 0 {
+		t.Fatalf("no argument predictions were requested")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SyzLLMBundleVersion is incremented on incompatible changes to the bundle contents.
+const SyzLLMBundleVersion = 1
+
+// SyzLLMBundle holds everything that must agree between SyzLLM training data
+// preprocessing and fuzzing: the call vocabulary, base addresses of calls
+// used to normalize pointers and the call descriptors used to resolve
+// "$SyzLLM" calls to concrete syscalls.
+type SyzLLMBundle struct {
+	Version     int
+	Revision    string            // Target.Revision of the descriptions the bundle was produced with
+	Vocab       []string          // unique calls of the training data
+	AddrBase    map[string]uint64 // call name -> base address
+	Descriptors map[string]string // syscall name -> descriptor used for "$SyzLLM" calls
+}
+
+// NewSyzLLMBundle returns an empty bundle for the target with the default descriptors.
+func (target *Target) NewSyzLLMBundle() *SyzLLMBundle {
+	descriptors := make(map[string]string)
+	for call, desc := range defaultSyzLLMDescriptors {
+		descriptors[call] = desc
+	}
+	return &SyzLLMBundle{
+		Version:     SyzLLMBundleVersion,
+		Revision:    target.Revision,
+		AddrBase:    make(map[string]uint64),
+		Descriptors: descriptors,
+	}
+}
+
+// CheckSyzLLMBundle verifies that the bundle was produced for the same descriptions.
+func (target *Target) CheckSyzLLMBundle(bundle *SyzLLMBundle) error {
+	if bundle.Version != SyzLLMBundleVersion {
+		return fmt.Errorf("SyzLLM bundle version %v, want %v", bundle.Version, SyzLLMBundleVersion)
+	}
+	if bundle.Revision != target.Revision {
+		return fmt.Errorf("SyzLLM bundle is produced for descriptions revision %v, but the target has %v",
+			bundle.Revision, target.Revision)
+	}
+	if len(bundle.AddrBase) == 0 {
+		return fmt.Errorf("SyzLLM bundle has no address table")
+	}
+	return nil
+}
+
+// InstallSyzLLMBundle makes the address table and descriptors of the bundle
+// used by normalization and parsing of SyzLLM calls.
+func InstallSyzLLMBundle(bundle *SyzLLMBundle) {
+	addrGenerator := GetAddrGeneratorInstance()
+	maxAddr := uint64(0)
+	for call, addr := range bundle.AddrBase {
+		addrGenerator.AddrBase[call] = addr
+		addrGenerator.AddrCounter[call] = 0
+		if maxAddr < addr {
+			maxAddr = addr
+		}
+	}
+	addrGenerator.AddrBase[UNK] = maxAddr + 0x80
+	addrGenerator.AddrCounter[UNK] = 0
+
+	syzLLMDescriptorsMu.Lock()
+	defer syzLLMDescriptorsMu.Unlock()
+	syzLLMDescriptors = bundle.Descriptors
+}
+
+// defaultSyzLLMDescriptors is used until a bundle is installed.
+var defaultSyzLLMDescriptors = map[string]string{
+	"socketpair": "unix", "socket": "unix", "connect": "unix", "getsockname": "unix",
+	"openat": "damon_target_ids", "fcntl": "setflags", "accept4": "unix", "read": "FUSE",
+	"quotactl": "Q_QUOTAON", "getpeername": "llc", "sendmmsg": "unix", "getsockopt": "kcm_KCM_RECV_DISABLE",
+	"bind": "unix", "sendto": "llc", "setsockopt": "kcm_KCM_RECV_DISABLE", "write": "damon_target_ids",
+	"ioctl": "FITRIM", "mmap": "IORING_OFF_SQ_RING", "recvmsg": "unix", "sendmsg": "unix",
+	"epoll_ctl": "EPOLL_CTL_ADD", "accept": "unix", "prctl": "PR_SET_PDEATHSIG", "recvfrom": "unix",
+}
+
+var (
+	syzLLMDescriptorsMu sync.RWMutex
+	syzLLMDescriptors   = defaultSyzLLMDescriptors
+)
+
+func currentSyzLLMDescriptors() map[string]string {
+	syzLLMDescriptorsMu.RLock()
+	defer syzLLMDescriptorsMu.RUnlock()
+	return syzLLMDescriptors
+}
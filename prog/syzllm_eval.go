@@ -0,0 +1,100 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// HeldOutCall is a program with one call removed for offline evaluation of predictors.
+type HeldOutCall struct {
+	Meta   *Syscall // the removed call
+	Pos    int
+	Prompt []string // the normalized program with MASK at Pos
+
+	prog *Prog // the program without the call
+	norm *Normalization
+}
+
+// HoldOutCall removes call pos from a clone of p, p is not changed.
+func (p *Prog) HoldOutCall(pos int, level PromptLevel) *HeldOutCall {
+	p1 := p.Clone()
+	p1.RemoveCall(pos)
+	norm := NormalizeProg(p1, level)
+	return &HeldOutCall{
+		Meta:   p.Calls[pos].Meta,
+		Pos:    pos,
+		Prompt: InsertMaskToSequence(append([]string{}, norm.Calls...), pos),
+		prog:   p1,
+		norm:   norm,
+	}
+}
+
+// PredictionCheck says how far a predicted call gets on the way into the program.
+// Each stage implies the previous ones.
+type PredictionCheck struct {
+	Resources    bool  // resource tags are well-formed
+	Deserialized bool  // the call with the tags replaced deserializes
+	Valid        bool  // the program with the call and its resources inserted is valid
+	Err          error // the first error, if any
+}
+
+// NameMatch says if the prediction names the held-out call exactly (variant)
+// or up to the variant (base).
+func (h *HeldOutCall) NameMatch(prediction string) (variant, base bool) {
+	name := callNameFromText(prediction)
+	return name == h.Meta.Name, baseCallName(name) == baseCallName(h.Meta.Name)
+}
+
+// Check inserts the prediction at the masked position of the program the same way mutations do,
+// all calls are considered enabled.
+func (h *HeldOutCall) Check(prediction string, rs rand.Source) PredictionCheck {
+	var res PredictionCheck
+	target := h.prog.Target
+	p := h.prog.Clone()
+	ctx := &mutator{
+		p:  p,
+		r:  newRand(target, rs),
+		ct: target.DefaultChoiceTable(),
+	}
+	if strings.Contains(prediction, "(") {
+		text, _ := extractResourceHints(ctx.resolveDescriptors(p, h.Pos, -1, prediction))
+		if strings.Contains(text, RPrefix) || strings.Contains(text, RSuffix) ||
+			strings.Contains(text, pipePrefix) || strings.Contains(text, pipeSuffix) {
+			res.Err = fmt.Errorf("unbalanced resource tags")
+			return res
+		}
+		res.Resources = true
+		p1, err := target.Deserialize([]byte(text), NonStrict)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		if len(p1.Calls) != 1 {
+			res.Err = fmt.Errorf("predicted %v calls instead of 1", len(p1.Calls))
+			return res
+		}
+	} else {
+		res.Resources = true
+		if ctx.resolvePredictedSyscall(p, h.Pos, -1, prediction) == nil {
+			res.Err = fmt.Errorf("unknown predicted call %v", prediction)
+			return res
+		}
+	}
+	res.Deserialized = true
+	calls, err := ctx.insertPrediction(p, h.Pos, -1, prediction, h.norm)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	p.Calls = calls
+	if err := p.validate(); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Valid = true
+	return res
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestHoldOutCall(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	const prog = "r0 = mutate5(&(0x7f0000000000)='./file1\\x00', 0x1)\n" +
+		"mutate6(r0, &(0x7f0000000100)=\"0102\", 0x2)\n"
+	p, err := target.Deserialize([]byte(prog), Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := p.HoldOutCall(1, PromptFlags)
+	if data := string(p.Serialize()); data != prog {
+		t.Fatalf("the program has changed:\n%s", data)
+	}
+	wantPrompt := []string{"mutate5(&(0x7f0000000000)='./file0\\x00', 0x1)", MASK}
+	if held.Meta.Name != "mutate6" || held.Pos != 1 || !reflect.DeepEqual(held.Prompt, wantPrompt) {
+		t.Fatalf("bad held-out call: %v %v %q", held.Meta.Name, held.Pos, held.Prompt)
+	}
+	tests := []struct {
+		prediction string
+		variant    bool
+		base       bool
+		check      PredictionCheck
+	}{
+		{
+			prediction: "mutate6(@RSTART@mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)@REND@, " +
+				"&(0x7f0000000100)='\\x00'/1024, 0x111)",
+			variant: true,
+			base:    true,
+			check:   PredictionCheck{Resources: true, Deserialized: true, Valid: true},
+		},
+		{
+			prediction: "mutate6$foo",
+			base:       true,
+			check:      PredictionCheck{Resources: true, Deserialized: true, Valid: true},
+		},
+		{
+			prediction: "mutate6(@RSTART@mutate5(&(0x7f0000000000)='./file0\\x00', 0x0), 0x0, 0x0)",
+			variant:    true,
+			base:       true,
+		},
+		{
+			prediction: "mutate6(0x1, &(0x7f0000000100)=",
+			variant:    true,
+			base:       true,
+			check:      PredictionCheck{Resources: true},
+		},
+	}
+	for _, test := range tests {
+		variant, base := held.NameMatch(test.prediction)
+		if variant != test.variant || base != test.base {
+			t.Errorf("%v: got name match %v/%v, want %v/%v",
+				test.prediction, variant, base, test.variant, test.base)
+		}
+		check := held.Check(test.prediction, rand.NewSource(0))
+		check.Err = nil
+		if check != test.check {
+			t.Errorf("%v: got %+v, want %+v", test.prediction, check, test.check)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/google/syzkaller/pkg/log"
+)
+
+// errSequenceEnd means that the predictor ended the sequence with SEP.
+var errSequenceEnd = errors.New("predicted end of the sequence")
+
+// GenerateSyzLLM generates a program call by call: the predictor of ct is asked for the next
+// call of the sequence that starts with CLS, so programs look like the traces the model was
+// trained on. Asynchronous predictors are waited for, see BlockingCallPredictor.
+// Every predicted call is validated and its resources are bound the same way
+// as for insertions of predicted calls.
+// Generation stops at ncalls calls, when the predictor ends the sequence, or when a prediction fails.
+// If the first call can't be predicted, nil and the error are returned, the caller should use Generate.
+func (target *Target) GenerateSyzLLM(rs rand.Source, ncalls int, ct *ChoiceTable) (*Prog, error) {
+	if !ct.predictorAvailable() {
+		return nil, errors.New("predictor is not available")
+	}
+	p := &Prog{
+		Target: target,
+	}
+	ctx := &mutator{
+		p:        p,
+		r:        newRand(target, rs),
+		ncalls:   ncalls,
+		ct:       ct,
+		generate: true,
+	}
+	for len(p.Calls) < ncalls {
+		res, err := ctx.predictCall(len(p.Calls), ctx.r.Int63())
+		if res == PredictionInserted {
+			continue
+		}
+		if len(p.Calls) == 0 {
+			return nil, err
+		}
+		log.Logf(2, "SyzLLM generation stopped after %v calls: %v: %v", len(p.Calls), res, err)
+		break
+	}
+	p.sanitizeFix()
+	p.debugValidate()
+	return p, nil
+}
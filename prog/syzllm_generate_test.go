@@ -0,0 +1,138 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+type funcPredictor func(calls []string) ([]Prediction, error)
+
+func (pred funcPredictor) Predict(calls []string) ([]Prediction, error) {
+	return pred(calls)
+}
+
+// waitPredictor is an asynchronous predictor that answers only when it's waited for.
+type waitPredictor struct {
+	funcPredictor
+}
+
+func (pred waitPredictor) Predict(calls []string) ([]Prediction, error) {
+	return nil, ErrPredictionPending
+}
+
+func (pred waitPredictor) PredictWait(calls []string) ([]Prediction, error) {
+	return pred.funcPredictor(calls)
+}
+
+func TestGenerateSyzLLM(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	tests := []struct {
+		name     string
+		pred     funcPredictor
+		blocking bool
+		want     string
+	}{
+		{
+			name: "full program",
+			pred: func(calls []string) ([]Prediction, error) {
+				return []Prediction{{Call: fmt.Sprintf("mutate8$SyzLLM(0x%x)", len(calls)-2), Score: 1}}, nil
+			},
+			want: "mutate8(0x0)\nmutate8(0x1)\nmutate8(0x2)\nmutate8(0x3)\n",
+		},
+		{
+			name: "sequence end",
+			pred: func(calls []string) ([]Prediction, error) {
+				if len(calls) == 4 {
+					return []Prediction{{Call: SEP, Score: 1}}, nil
+				}
+				return []Prediction{{Call: "mutate8$SyzLLM(0x1)", Score: 1}}, nil
+			},
+			want: "mutate8(0x1)\nmutate8(0x1)\n",
+		},
+		{
+			name: "failed step",
+			pred: func(calls []string) ([]Prediction, error) {
+				if len(calls) == 3 {
+					return nil, fmt.Errorf("server is down")
+				}
+				return []Prediction{{Call: "mutate8$SyzLLM(0x1)", Score: 1}}, nil
+			},
+			want: "mutate8(0x1)\n",
+		},
+		{
+			name: "asynchronous predictor",
+			pred: func(calls []string) ([]Prediction, error) {
+				return []Prediction{{Call: fmt.Sprintf("mutate8$SyzLLM(0x%x)", len(calls)-2), Score: 1}}, nil
+			},
+			blocking: true,
+			want:     "mutate8(0x0)\nmutate8(0x1)\nmutate8(0x2)\nmutate8(0x3)\n",
+		},
+		{
+			name: "failed first step",
+			pred: func(calls []string) ([]Prediction, error) {
+				return nil, ErrPredictionPending
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var requests [][]string
+			ct := target.DefaultChoiceTable()
+			var pred CallPredictor = funcPredictor(func(calls []string) ([]Prediction, error) {
+				requests = append(requests, calls)
+				return test.pred(calls)
+			})
+			if test.blocking {
+				pred = waitPredictor{pred.(funcPredictor)}
+			}
+			ct.SetPredictor(pred, 100)
+			p, err := target.GenerateSyzLLM(rand.NewSource(0), 4, ct)
+			if len(requests) == 0 || requests[0][0] != CLS || requests[0][1] != MASK {
+				t.Fatalf("bad requests: %q", requests)
+			}
+			if test.want == "" {
+				if p != nil || err == nil {
+					t.Fatalf("generation did not fail: %v", err)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatalf("generation failed: %v", err)
+			}
+			if data := string(p.Serialize()); data != test.want {
+				t.Fatalf("got program:\n%s\nwant:\n%s", data, test.want)
+			}
+		})
+	}
+}
+
+type unavailablePredictor struct {
+	FakePredictor
+}
+
+func (pred *unavailablePredictor) Available() bool {
+	return false
+}
+
+func TestUnavailablePredictor(t *testing.T) {
+	target, rs, iters := initRandomTargetTest(t, "test", "64")
+	ct := target.DefaultChoiceTable()
+	pred := &unavailablePredictor{FakePredictor{Predictions: []Prediction{{Call: "mutate8$SyzLLM(0x1)", Score: 1}}}}
+	ct.SetPredictor(pred, 100)
+	ct.SetArgPredictionProb(100)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		p.Mutate(rs, 20, ct, nil, nil)
+	}
+	if p, err := target.GenerateSyzLLM(rs, 10, ct); p != nil || err == nil {
+		t.Fatalf("generated a program with an unavailable predictor")
+	}
+	if requests := pred.Requests(); len(requests) != 0 {
+		t.Fatalf("unavailable predictor got %v requests", len(requests))
+	}
+}
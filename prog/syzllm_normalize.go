@@ -0,0 +1,206 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PromptLevel selects how much of the program is sent to the predictor.
+type PromptLevel int
+
+const (
+	// PromptFlags keeps call names, flags and resources, other arguments are replaced with placeholders.
+	PromptFlags PromptLevel = iota
+	// PromptNames keeps only call names.
+	PromptNames
+	// PromptFull keeps all arguments, only pointers are rebased to the SyzLLM address table.
+	PromptFull
+	promptLevelCount
+)
+
+var promptLevelNames = [promptLevelCount]string{
+	PromptFlags: "flags",
+	PromptNames: "names",
+	PromptFull:  "full",
+}
+
+func (level PromptLevel) String() string {
+	return promptLevelNames[level]
+}
+
+func ParsePromptLevel(name string) (PromptLevel, error) {
+	for level, name1 := range promptLevelNames {
+		if name == name1 {
+			return PromptLevel(level), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown prompt level %q", name)
+}
+
+// Normalization is a program normalized for a SyzLLM prompt along with the mapping
+// from placeholders back to the values the program had.
+type Normalization struct {
+	Level  PromptLevel
+	Calls  []string // normalized calls
+	target *Target
+	values map[normKey][]normValue
+}
+
+// normKey identifies the kind of argument a placeholder replaces.
+type normKey struct {
+	typ   string
+	field string
+	dir   Dir
+}
+
+type normValue struct {
+	call int    // index of the call the value was taken from
+	val  uint64 // value of a ConstArg or size of a DataArg
+	data []byte // data of an input DataArg
+}
+
+// NormalizeProg normalizes a clone of the program for a SyzLLM prompt, p is not changed.
+// If the arguments can't be normalized, the calls are kept as is.
+func NormalizeProg(p *Prog, level PromptLevel) *Normalization {
+	return normalizeProg(p, level, -1, nil)
+}
+
+// normalizeProg is NormalizeProg that also replaces mask (an argument of call maskCall) with MASK.
+// The masked call is serialized in full for PromptNames.
+func normalizeProg(p *Prog, level PromptLevel, maskCall int, mask Arg) *Normalization {
+	norm := &Normalization{
+		Level:  level,
+		target: p.Target,
+		values: make(map[normKey][]normValue),
+	}
+	if level == PromptNames {
+		for _, c := range p.Calls {
+			norm.Calls = append(norm.Calls, c.Meta.Name)
+		}
+		if mask != nil {
+			norm.Calls[maskCall] = ConvertAnyBlob(serializeCalls(p, mask)[maskCall])
+		}
+		return norm
+	}
+	normalized, normalizedMask := p.Clone(), mask
+	if mask != nil {
+		// Normalization replaces only leaf arguments, so the clone of mask stays in the tree.
+		normalizedMask = argAt(normalized.Calls[maskCall], argIndex(p.Calls[maskCall], mask))
+	}
+	if err := norm.normalizeArgs(normalized); err != nil {
+		normalized, normalizedMask = p, mask
+		norm.values = make(map[normKey][]normValue)
+	}
+	for _, call := range serializeCalls(normalized, normalizedMask) {
+		norm.Calls = append(norm.Calls, ConvertAnyBlob(call))
+	}
+	return norm
+}
+
+// serializeCalls serializes each call of p with mask replaced by MASK.
+// The program is not validated: normalized arguments don't have to match the descriptions
+// (e.g. fixed-size buffers are replaced with BufferSize long ones).
+func serializeCalls(p *Prog, mask Arg) []string {
+	calls := strings.Split(string(p.serializeMasked(mask)), "\n")
+	return calls[:len(calls)-1]
+}
+
+func (norm *Normalization) normalizeArgs(p *Prog) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to normalize arguments: %v", r)
+		}
+	}()
+	for i, call := range p.Calls {
+		norm.walkArgs(call, i, false)
+	}
+	return nil
+}
+
+// Denormalize replaces placeholders in the arguments of the predicted call with values
+// that the same kind of arguments had in the normalized program, preferring the values
+// of the calls closest to pos.
+func (norm *Normalization) Denormalize(p *Prog, pos int) {
+	if norm == nil || len(norm.values) == 0 {
+		return
+	}
+	for _, c := range p.Calls {
+		norm.walkArgs(c, pos, true)
+		norm.target.assignSizesCall(c)
+	}
+}
+
+func (norm *Normalization) walkArgs(c *Call, idx int, denormalize bool) {
+	for j, arg := range c.Args {
+		if _, ok := arg.(*ResultArg); ok {
+			continue
+		}
+		argReplacer := NewArgReplacer(c.Meta.Name)
+		argReplacer.level = norm.Level
+		argReplacer.norm = norm
+		argReplacer.callIdx = idx
+		argReplacer.denormalize = denormalize
+		c.Args[j] = argReplacer.DFSArgs(arg, c.Meta.Args[j])
+	}
+}
+
+func (norm *Normalization) record(key normKey, call int, val uint64, arg *DataArg) {
+	if norm == nil {
+		return
+	}
+	v := normValue{call: call, val: val}
+	if arg != nil && arg.Dir() != DirOut {
+		v.data = append([]byte{}, arg.Data()...)
+	}
+	norm.values[key] = append(norm.values[key], v)
+}
+
+// lookup returns the value recorded for the last call before pos, or for the first call after it.
+func (norm *Normalization) lookup(key normKey, pos int) (normValue, bool) {
+	values := norm.values[key]
+	if len(values) == 0 {
+		return normValue{}, false
+	}
+	res := values[0]
+	for _, v := range values {
+		if v.call >= pos {
+			break
+		}
+		res = v
+	}
+	return res, true
+}
+
+func (norm *Normalization) restoreConst(arg *ConstArg, key normKey, pos int) Arg {
+	v, ok := norm.lookup(key, pos)
+	if !ok {
+		return arg
+	}
+	return MakeConstArg(arg.Type(), arg.Dir(), v.val)
+}
+
+// restoreData restores buffers that look like placeholders: zero-filled (or sized for output)
+// buffers of placeholderSize and placeholderPath filenames.
+func (norm *Normalization) restoreData(arg *DataArg, key normKey, pos int,
+	placeholderSize uint64, placeholderPath string) Arg {
+	v, ok := norm.lookup(key, pos)
+	if !ok {
+		return arg
+	}
+	if arg.Dir() == DirOut {
+		if arg.Size() != placeholderSize {
+			return arg
+		}
+		return MakeOutDataArg(arg.Type(), arg.Dir(), v.val)
+	}
+	data := arg.Data()
+	if string(data) != placeholderPath &&
+		(uint64(len(data)) != placeholderSize || !bytes.Equal(data, make([]byte, placeholderSize))) {
+		return arg
+	}
+	return MakeDataArg(arg.Type(), arg.Dir(), v.data)
+}
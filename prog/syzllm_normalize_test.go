@@ -0,0 +1,119 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeProg(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	const prog = "r0 = mutate5(&(0x7f0000000000)='./file1\\x00', 0x1)\n" +
+		"mutate_flags(&(0x7f0000000040)='./file0\\x00', 0x1234, 0x1, 0x2)\n" +
+		"mutate6(r0, &(0x7f0000000100)=\"0102\", 0x2)\n"
+	tests := []struct {
+		level PromptLevel
+		calls []string
+	}{
+		{
+			level: PromptNames,
+			calls: []string{"mutate5", "mutate_flags", "mutate6"},
+		},
+		{
+			level: PromptFlags,
+			calls: []string{
+				"r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x1)",
+				"mutate_flags(&(0x7f0000000040)='./file0\\x00', 0x111, 0x111, 0x2)",
+				"mutate6(r0, &(0x7f0000000100)='\\x00'/1024, 0x111)",
+			},
+		},
+		{
+			level: PromptFull,
+			calls: []string{
+				"r0 = mutate5(&(0x7f0000000000)='./file1\\x00', 0x1)",
+				"mutate_flags(&(0x7f0000000040)='./file0\\x00', 0x1234, 0x1, 0x2)",
+				"mutate6(r0, &(0x7f0000000100)=\"0102\", 0x2)",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.level.String(), func(t *testing.T) {
+			level, err := ParsePromptLevel(test.level.String())
+			if err != nil || level != test.level {
+				t.Fatalf("failed to parse %v: %v, %v", test.level, level, err)
+			}
+			p, err := target.Deserialize([]byte(prog), Strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			norm := NormalizeProg(p, test.level)
+			if !reflect.DeepEqual(norm.Calls, test.calls) {
+				t.Fatalf("got calls:\n%q\nwant:\n%q", norm.Calls, test.calls)
+			}
+			if data := string(p.Serialize()); data != prog {
+				t.Fatalf("the program has changed:\n%s", data)
+			}
+		})
+	}
+	if _, err := ParsePromptLevel("foo"); err == nil {
+		t.Fatalf("parsed unknown prompt level")
+	}
+}
+
+func TestDenormalize(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	p, err := target.Deserialize([]byte(
+		"mutate_flags(&(0x7f0000000000)='./file0\\x00', 0x1234, 0x1, 0x2)\n"+
+			"mutate0()\n"+
+			"mutate_flags(&(0x7f0000000040)='./file1\\x00', 0x5678, 0x0, 0x2)\n"+
+			"mutate6(0xffffffffffffffff, &(0x7f0000000100)=\"0102\", 0x2)\n"), Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	norm := NormalizeProg(p, PromptFlags)
+	tests := []struct {
+		pos  int
+		call string
+		want string
+	}{
+		{
+			pos:  2,
+			call: "mutate_flags(&(0x7f0000000000)='./file0\\x00', 0x111, 0x111, 0x1)",
+			want: "mutate_flags(&(0x7f0000000000)='./file0\\x00', 0x1234, 0x1, 0x1)\n",
+		},
+		{
+			pos:  4,
+			call: "mutate_flags(&(0x7f0000000000)='./file0\\x00', 0x111, 0x111, 0x1)",
+			want: "mutate_flags(&(0x7f0000000000)='./file1\\x00', 0x5678, 0x0, 0x1)\n",
+		},
+		{
+			// Values that are not placeholders are kept.
+			pos:  4,
+			call: "mutate_flags(&(0x7f0000000000)='./file2\\x00', 0x42, 0x111, 0x1)",
+			want: "mutate_flags(&(0x7f0000000000)='./file2\\x00', 0x42, 0x0, 0x1)\n",
+		},
+		{
+			pos:  4,
+			call: "mutate6(0xffffffffffffffff, &(0x7f0000000100)='\\x00'/1024, 0x111)",
+			want: "mutate6(0xffffffffffffffff, &(0x7f0000000100)=\"0102\", 0x2)\n",
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			p1, err := target.Deserialize([]byte(test.call), NonStrict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			norm.Denormalize(p1, test.pos)
+			if err := p1.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if data := string(p1.Serialize()); data != test.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", data, test.want)
+			}
+		})
+	}
+}
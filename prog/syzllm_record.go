@@ -0,0 +1,124 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/google/syzkaller/pkg/hash"
+)
+
+// PredictionRecord describes a single attempt to insert a predicted call.
+// It holds everything needed to repeat the insertion with ReplayPrediction without the predictor.
+type PredictionRecord struct {
+	ProgHash    string // hash of Prog
+	Prog        string // the program before the insertion
+	Pos         int    // the masked position
+	Seed        int64  // seed of all random choices made during the insertion
+	NCalls      int    // the allowed maximum number of calls
+	Generate    bool   // the call was asked for by GenerateSyzLLM
+	Prompt      string // the prompt level
+	Request     []string
+	Predictions []Prediction
+	Repairs     []string // repairs of predicted calls, see DeserializeSyzLLM
+	Err         string
+	Result      string
+	Mutated     string // the program after the insertion, if the call was inserted
+}
+
+// PredictionRecorder is implemented by predictors that want to record all insertion attempts,
+// e.g. to reproduce a bad mutation later with ReplayPrediction.
+type PredictionRecorder interface {
+	RecordPrediction(rec *PredictionRecord)
+}
+
+// startRecord starts recording of the insertion at pos, if the predictor records predictions.
+func (ctx *mutator) startRecord(pos int, seed int64) {
+	ctx.record = nil
+	if _, ok := ctx.ct.predictor.(PredictionRecorder); !ok {
+		return
+	}
+	data := ctx.p.serializeMasked(nil)
+	ctx.record = &PredictionRecord{
+		ProgHash: hash.String(data),
+		Prog:     string(data),
+		Pos:      pos,
+		Seed:     seed,
+		NCalls:   ctx.ncalls,
+		Generate: ctx.generate,
+		Prompt:   ctx.ct.promptLevel.String(),
+	}
+}
+
+func (ctx *mutator) finishRecord(res PredictionResult, err error) {
+	rec := ctx.record
+	ctx.record = nil
+	if rec == nil || res == PredictionPending {
+		return
+	}
+	rec.Result = res.String()
+	if err != nil {
+		rec.Err = err.Error()
+	}
+	if res == PredictionInserted {
+		rec.Mutated = string(ctx.p.serializeMasked(nil))
+	}
+	ctx.ct.predictor.(PredictionRecorder).RecordPrediction(rec)
+}
+
+// ReplayPrediction repeats the insertion described by rec using the recorded predictions
+// instead of the predictor, and returns the resulting program.
+// The result is the same only if ct and corpus match the ones used during fuzzing
+// (same enabled calls and the same corpus programs).
+func (target *Target) ReplayPrediction(rec *PredictionRecord, ct *ChoiceTable, corpus []*Prog) (
+	*Prog, PredictionResult, error) {
+	p, err := target.Deserialize([]byte(rec.Prog), NonStrict)
+	if err != nil {
+		return nil, PredictionParseError, fmt.Errorf("failed to deserialize the recorded program: %w", err)
+	}
+	if rec.Pos < 0 || rec.Pos > len(p.Calls) {
+		return nil, PredictionParseError, fmt.Errorf("bad recorded position %v for %v calls",
+			rec.Pos, len(p.Calls))
+	}
+	replayCt := *ct
+	replayCt.predictor = &replayPredictor{rec}
+	if rec.Prompt != "" {
+		if replayCt.promptLevel, err = ParsePromptLevel(rec.Prompt); err != nil {
+			return nil, PredictionParseError, err
+		}
+	}
+	ctx := &mutator{
+		p:        p,
+		r:        newRand(target, rand.NewSource(rec.Seed)),
+		ncalls:   rec.NCalls,
+		ct:       &replayCt,
+		corpus:   corpus,
+		generate: rec.Generate,
+	}
+	res, err := ctx.predictCall(rec.Pos, rec.Seed)
+	return p, res, err
+}
+
+// replayPredictor answers with the recorded predictions.
+type replayPredictor struct {
+	rec *PredictionRecord
+}
+
+func (pred *replayPredictor) Predict(calls []string) ([]Prediction, error) {
+	if len(pred.rec.Predictions) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPredictionRejected, pred.rec.Err)
+	}
+	if len(calls) != len(pred.rec.Request) {
+		return nil, errors.New("the request does not match the recorded one")
+	}
+	for i := range calls {
+		if calls[i] != pred.rec.Request[i] {
+			return nil, fmt.Errorf("the request does not match the recorded one at %v:\n%v\n%v",
+				i, calls[i], pred.rec.Request[i])
+		}
+	}
+	return pred.rec.Predictions, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type recordingPredictor struct {
+	FakePredictor
+	records []*PredictionRecord
+}
+
+func (pred *recordingPredictor) RecordPrediction(rec *PredictionRecord) {
+	pred.records = append(pred.records, rec)
+}
+
+func TestReplayPrediction(t *testing.T) {
+	target, rs, iters := initRandomTargetTest(t, "test", "64")
+	ct := target.DefaultChoiceTable()
+	pred := &recordingPredictor{FakePredictor: FakePredictor{Predictions: []Prediction{
+		{Call: "mutate6$SyzLLM(@RSTART@mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)@REND@, " +
+			"&(0x7f0000000100)=\"0102\", 0x2)", Score: 0.5},
+		{Call: "mutate8$SyzLLM(0x2)", Score: 0.3},
+		{Call: "mutate_integer", Score: 0.2},
+	}}}
+	ct.SetPredictor(pred, 100)
+	r := newRand(target, rs)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		ctx := &mutator{
+			p:      p,
+			r:      r,
+			ncalls: 20,
+			ct:     ct,
+		}
+		ctx.insertCall_SyzLLM()
+	}
+	if len(pred.records) != iters {
+		t.Fatalf("recorded %v insertions, want %v", len(pred.records), iters)
+	}
+	inserted := 0
+	for i, rec := range pred.records {
+		// Records go through a file.
+		data, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec1 := new(PredictionRecord)
+		if err := json.Unmarshal(data, rec1); err != nil {
+			t.Fatal(err)
+		}
+		p, res, err := target.ReplayPrediction(rec1, ct, nil)
+		if res.String() != rec.Result {
+			t.Fatalf("record #%v: replay result %v (%v), recorded %v (%v)", i, res, err, rec.Result, rec.Err)
+		}
+		if res != PredictionInserted {
+			continue
+		}
+		inserted++
+		if data := string(p.Serialize()); data != rec.Mutated {
+			t.Fatalf("record #%v: replayed program differs:\n%s\nrecorded:\n%s", i, data, rec.Mutated)
+		}
+	}
+	if inserted == 0 {
+		t.Fatalf("no predicted calls were inserted")
+	}
+}
+
+func TestReplayPredictionMismatch(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	rec := &PredictionRecord{
+		Prog:        "mutate8(0x1)\n",
+		Pos:         1,
+		NCalls:      10,
+		Request:     []string{"mutate7(0x1)", MASK},
+		Predictions: []Prediction{{Call: "mutate8$SyzLLM(0x2)", Score: 1}},
+	}
+	ct := target.DefaultChoiceTable()
+	if _, res, err := target.ReplayPrediction(rec, ct, nil); res != PredictionServerError || err == nil {
+		t.Fatalf("mismatching request is replayed: %v: %v", res, err)
+	}
+	rec.Request[0] = "mutate8(0x111)"
+	p, res, err := target.ReplayPrediction(rec, ct, nil)
+	if res != PredictionInserted {
+		t.Fatalf("replay failed: %v: %v", res, err)
+	}
+	if data := string(p.Serialize()); data != "mutate8(0x1)\nmutate8(0x2)\n" {
+		t.Fatalf("bad replayed program:\n%s", data)
+	}
+}
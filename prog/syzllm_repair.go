@@ -0,0 +1,246 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/log"
+)
+
+// RepairError is returned by DeserializeSyzLLM in the Strict mode for programs that needed repairs.
+type RepairError struct {
+	Repairs []string
+}
+
+func (err *RepairError) Error() string {
+	return fmt.Sprintf("program needs %v repairs: %v", len(err.Repairs), strings.Join(err.Repairs, "; "))
+}
+
+// DeserializeSyzLLM deserializes a program derived from SyzLLM output (e.g. a predicted call).
+// Such programs often don't match the descriptions: fixed-size buffers and arrays have wrong sizes,
+// union options don't match the union, etc. So unlike for other programs, the mismatches are
+// repaired once here: the program is parsed in the NonStrict mode, placeholders are replaced
+// with the values of norm preferred at pos (if norm is set), the arguments are fixed up to match
+// their types and sizes are reassigned, and only then the program is validated. The result is
+// a valid program that the rest of prog can handle as any other one.
+// All repairs are returned. In the Strict mode, programs that needed repairs fail with RepairError.
+func (target *Target) DeserializeSyzLLM(data []byte, mode DeserializeMode, norm *Normalization,
+	pos int) (*Prog, []string, error) {
+	var repairs []string
+	p, fixups, err := target.deserializeFixup(data, NonStrict, func(p *Prog) {
+		norm.Denormalize(p, pos)
+		for _, c := range p.Calls {
+			repairs = append(repairs, target.repairCall(c)...)
+		}
+	})
+	repairs = append(fixups, repairs...)
+	if err != nil {
+		return nil, repairs, err
+	}
+	if mode == Strict && len(repairs) != 0 {
+		return nil, repairs, &RepairError{repairs}
+	}
+	return p, repairs, nil
+}
+
+// deserializePrediction deserializes the text of a predicted call with DeserializeSyzLLM
+// in the mode chosen by ChoiceTable.SetStrictRepair.
+func (ctx *mutator) deserializePrediction(text string, norm *Normalization, pos int) (*Prog, error) {
+	mode := NonStrict
+	if ctx.ct.strictRepair {
+		mode = Strict
+	}
+	p, repairs, err := ctx.ct.target.DeserializeSyzLLM([]byte(text), mode, norm, pos)
+	if len(repairs) != 0 {
+		level := 2
+		if mode == Strict {
+			level = 1
+		}
+		log.Logf(level, "SyzLLM: predicted call %q needed repairs: %v", text, strings.Join(repairs, "; "))
+		if ctx.record != nil {
+			ctx.record.Repairs = append(ctx.record.Repairs, repairs...)
+		}
+	}
+	return p, err
+}
+
+// argRepairer fixes up arguments of a call that don't match their types.
+type argRepairer struct {
+	target  *Target
+	call    *Call
+	repairs []string
+}
+
+// repairCall fixes up arguments of c and returns descriptions of the repairs.
+func (target *Target) repairCall(c *Call) []string {
+	rep := &argRepairer{
+		target: target,
+		call:   c,
+	}
+	lens := make(map[*ConstArg]uint64)
+	for i, arg := range c.Args {
+		c.Args[i] = rep.repair(arg, c.Meta.Args[i].Type, DirIn)
+	}
+	ForeachArg(c, func(arg Arg, _ *ArgCtx) {
+		if a, ok := arg.(*ConstArg); ok {
+			if _, ok := a.Type().(*LenType); ok {
+				lens[a] = a.Val
+			}
+		}
+	})
+	rep.target.assignSizesCall(c)
+	ForeachArg(c, func(arg Arg, _ *ArgCtx) {
+		if a, ok := arg.(*ConstArg); ok {
+			if val, ok := lens[a]; ok && val != a.Val {
+				rep.notef(a.Type(), "size %v -> %v", val, a.Val)
+			}
+		}
+	})
+	return rep.repairs
+}
+
+func (rep *argRepairer) notef(typ Type, msg string, args ...interface{}) {
+	rep.repairs = append(rep.repairs, fmt.Sprintf("%v: %v: %v", rep.call.Meta.Name, typ.Name(),
+		fmt.Sprintf(msg, args...)))
+}
+
+// repair returns arg fixed up to match typ, it's either arg itself or the default arg of typ.
+func (rep *argRepairer) repair(arg Arg, typ Type, dir Dir) Arg {
+	if _, ok := typ.(*PtrType); ok {
+		dir = DirIn
+	}
+	if arg == nil || arg.Type() != typ && !rep.target.isAnyPtr(arg.Type()) ||
+		arg.Dir() != dir && dir != DirInOut {
+		rep.notef(typ, "replaced mismatching arg with the default")
+		if arg != nil {
+			removeArg(arg)
+		}
+		return typ.DefaultArg(dir)
+	}
+	switch a := arg.(type) {
+	case *ConstArg:
+		rep.repairConst(a)
+	case *DataArg:
+		rep.repairData(a)
+	case *GroupArg:
+		return rep.repairGroup(a, dir)
+	case *UnionArg:
+		return rep.repairUnion(a, dir)
+	case *PointerArg:
+		rep.repairPointer(a)
+	}
+	return arg
+}
+
+func (rep *argRepairer) repairConst(arg *ConstArg) {
+	if isDefault(arg) {
+		return
+	}
+	switch typ := arg.Type().(type) {
+	case *ProcType:
+		if arg.Val >= typ.ValuesPerProc {
+			rep.notef(typ, "proc value %v -> %v", arg.Val, arg.Val%typ.ValuesPerProc)
+			arg.Val %= typ.ValuesPerProc
+		}
+	case *CsumType:
+		rep.notef(typ, "csum value %v -> 0", arg.Val)
+		arg.Val = 0
+	case *IntType:
+		if arg.Dir() == DirOut {
+			rep.notef(typ, "out value %v -> default", arg.Val)
+			arg.Val = typ.DefaultArg(DirOut).(*ConstArg).Val
+		}
+	}
+}
+
+func (rep *argRepairer) repairData(arg *DataArg) {
+	typ := arg.Type().(*BufferType)
+	var size uint64
+	switch {
+	case !typ.Varlen():
+		size = typ.Size()
+	case typ.Kind == BufferString && typ.TypeSize != 0:
+		size = typ.TypeSize
+	default:
+		return
+	}
+	if arg.Size() == size {
+		return
+	}
+	rep.notef(typ, "buffer size %v -> %v", arg.Size(), size)
+	if arg.Dir() == DirOut {
+		arg.size = size
+		return
+	}
+	data := arg.Data()
+	if uint64(len(data)) < size {
+		data = append(data, make([]byte, size-uint64(len(data)))...)
+	}
+	arg.SetData(data[:size])
+}
+
+func (rep *argRepairer) repairGroup(arg *GroupArg, dir Dir) Arg {
+	switch typ := arg.Type().(type) {
+	case *StructType:
+		if len(arg.Inner) != len(typ.Fields) {
+			rep.notef(typ, "%v fields instead of %v, replaced with the default", len(arg.Inner), len(typ.Fields))
+			removeArg(arg)
+			return typ.DefaultArg(dir)
+		}
+		for i, fld := range arg.Inner {
+			arg.Inner[i] = rep.repair(fld, typ.Fields[i].Type, typ.Fields[i].Dir(dir))
+		}
+	case *ArrayType:
+		if typ.Kind == ArrayRangeLen && typ.RangeBegin == typ.RangeEnd && uint64(len(arg.Inner)) != typ.RangeBegin {
+			rep.notef(typ, "%v elements instead of %v", len(arg.Inner), typ.RangeBegin)
+			for uint64(len(arg.Inner)) < typ.RangeBegin {
+				arg.Inner = append(arg.Inner, typ.Elem.DefaultArg(dir))
+			}
+			for _, elem := range arg.Inner[typ.RangeBegin:] {
+				removeArg(elem)
+			}
+			arg.Inner = arg.Inner[:typ.RangeBegin]
+		}
+		for i, elem := range arg.Inner {
+			arg.Inner[i] = rep.repair(elem, typ.Elem, dir)
+		}
+	}
+	return arg
+}
+
+func (rep *argRepairer) repairUnion(arg *UnionArg, dir Dir) Arg {
+	typ := arg.Type().(*UnionType)
+	if arg.Index < 0 || arg.Index >= len(typ.Fields) {
+		rep.notef(typ, "bad option %v, replaced with the default", arg.Index)
+		removeArg(arg)
+		return typ.DefaultArg(dir)
+	}
+	opt := typ.Fields[arg.Index]
+	arg.Option = rep.repair(arg.Option, opt.Type, opt.Dir(dir))
+	if !typ.Varlen() && arg.Option.Size() > typ.Size() {
+		rep.notef(typ, "option %v doesn't fit, replaced with the default", opt.Name)
+		removeArg(arg.Option)
+		arg.Option = opt.Type.DefaultArg(opt.Dir(dir))
+	}
+	return arg
+}
+
+func (rep *argRepairer) repairPointer(arg *PointerArg) {
+	typ, ok := arg.Type().(*PtrType)
+	if !ok || arg.Res == nil {
+		return
+	}
+	arg.Res = rep.repair(arg.Res, typ.Elem, typ.ElemDir)
+	if arg.IsSpecial() {
+		return
+	}
+	maxMem := rep.target.NumPages * rep.target.PageSize
+	if size := arg.Res.Size(); size <= maxMem && arg.Address+size > maxMem {
+		addr := (maxMem - size) / rep.target.PageSize * rep.target.PageSize
+		rep.notef(typ, "address 0x%x -> 0x%x", arg.Address, addr)
+		arg.Address = addr
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDeserializeSyzLLM(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	tests := []struct {
+		prog    string
+		want    string
+		repairs int
+	}{
+		{
+			prog: "test$length22(&(0x7f0000000000)=\"0102\", 0x10)\n",
+			want: "test$length22(&(0x7f0000000000)=\"0102\", 0x10)\n",
+		},
+		{
+			prog:    "test$length22(&(0x7f0000000000)=\"0102\", 0x5)\n",
+			want:    "test$length22(&(0x7f0000000000)=\"0102\", 0x10)\n",
+			repairs: 1,
+		},
+		{
+			prog:    "test$regression2(&(0x7f0000000000)=[0x1])\n",
+			want:    "test$regression2(&(0x7f0000000000)=[0x1])\n",
+			repairs: 3,
+		},
+		{
+			// Rejected by validation without the repair.
+			prog:    "mutate8(0x9)\n",
+			want:    "mutate8(0x1)\n",
+			repairs: 1,
+		},
+	}
+	if _, err := target.Deserialize([]byte(tests[len(tests)-1].prog), NonStrict); err == nil {
+		t.Fatalf("invalid program is deserialized")
+	}
+	for i, test := range tests {
+		p, repairs, err := target.DeserializeSyzLLM([]byte(test.prog), NonStrict, nil, 0)
+		if err != nil {
+			t.Fatalf("test #%v: %v", i, err)
+		}
+		if len(repairs) != test.repairs {
+			t.Fatalf("test #%v: got %v repairs, want %v: %q", i, len(repairs), test.repairs, repairs)
+		}
+		if got := string(p.Serialize()); got != test.want {
+			t.Fatalf("test #%v: got:\n%v\nwant:\n%v", i, got, test.want)
+		}
+		_, repairs, err = target.DeserializeSyzLLM([]byte(test.prog), Strict, nil, 0)
+		var repairErr *RepairError
+		if errors.As(err, &repairErr) != (test.repairs != 0) ||
+			repairErr != nil && len(repairErr.Repairs) != len(repairs) {
+			t.Fatalf("test #%v: strict mode returned %v", i, err)
+		}
+	}
+}
+
+func TestRepairCall(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	p, err := target.Deserialize([]byte(
+		"test$array2(&(0x7f0000000000)={0x1, \"00112233445566778899aabbccddeeff\", 0x2})\n"+
+			"test$regression2(&(0x7f0000000000)=[0x1, 0x2, 0x3, 0x4])\n"+
+			"test$union0(&(0x7f0000000000)={0x1, @f0=0x2})\n"), Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Break the arguments the way denormalization of LLM output does.
+	blob := p.Calls[0].Args[0].(*PointerArg).Res.(*GroupArg).Inner[1].(*DataArg)
+	blob.SetData([]byte(strings.Repeat("\xff", 20)))
+	arr := p.Calls[1].Args[0].(*PointerArg).Res.(*GroupArg)
+	arr.Inner = append(arr.Inner, arr.Inner[0].Type().DefaultArg(DirIn))
+	union := p.Calls[2].Args[0].(*PointerArg).Res.(*GroupArg).Inner[1].(*UnionArg)
+	union.Index = 1
+	if err := p.validate(); err == nil {
+		t.Fatalf("broken program is valid")
+	}
+	var repairs []string
+	for _, c := range p.Calls {
+		repairs = append(repairs, target.repairCall(c)...)
+	}
+	if len(repairs) != 3 {
+		t.Fatalf("got %v repairs: %q", len(repairs), repairs)
+	}
+	if err := p.validate(); err != nil {
+		t.Fatalf("repaired program is invalid: %v\n%q", err, repairs)
+	}
+	want := "test$array2(&(0x7f0000000000)={0x1, \"" + strings.Repeat("ff", 16) + "\", 0x2})\n" +
+		"test$regression2(&(0x7f0000000000)=[0x1, 0x2, 0x3, 0x4])\n" +
+		"test$union0(&(0x7f0000000000)={0x1, @f1})\n"
+	if got := string(p.Serialize()); got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
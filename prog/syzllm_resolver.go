@@ -0,0 +1,119 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// resolvePredictedSyscall maps a call name predicted by SyzLLM to a syscall of the target.
+// Exact variant names (e.g. "socket$inet") are used as is. Base names (e.g. "socket"
+// or "socket$SyzLLM") are resolved to one of the generatable variants of the syscall
+// chosen randomly with weights given by predictionVariantWeight.
+// Returns nil if there are no suitable syscalls.
+func (ctx *mutator) resolvePredictedSyscall(p *Prog, pos, bias int, name string) *Syscall {
+	if meta := p.Target.SyscallMap[name]; meta != nil {
+		return meta
+	}
+	variants := p.Target.callVariants[baseCallName(name)]
+	var candidates []*Syscall
+	var weights []float64
+	var sum float64
+	resources := precedingResources(p, pos)
+	for _, meta := range variants {
+		if !ctx.ct.Generatable(meta.ID) {
+			continue
+		}
+		w := ctx.predictionVariantWeight(meta, bias, resources)
+		candidates = append(candidates, meta)
+		weights = append(weights, w)
+		sum += w
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	x := ctx.r.Float64() * sum
+	for i, w := range weights {
+		if x -= w; x < 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// predictionVariantWeight is the ChoiceTable priority of the variant with respect to bias
+// multiplied by the fraction of input resources of the variant that can be satisfied
+// by the resources in ctxRes (+1 so that variants without inputs don't get an advantage
+// over variants with all inputs satisfied).
+func (ctx *mutator) predictionVariantWeight(meta *Syscall, bias int, ctxRes []*ResourceDesc) float64 {
+	prio := 1.0
+	if bias >= 0 {
+		prio = math.Max(float64(ctx.ct.prio(bias, meta.ID))/prioHigh, 1e-3)
+	}
+	satisfied := 0
+	for _, in := range meta.inputResources {
+		for _, res := range ctxRes {
+			if isCompatibleResourceImpl(in.Kind, res.Kind, true) {
+				satisfied++
+				break
+			}
+		}
+	}
+	return prio * float64(1+satisfied) / float64(1+len(meta.inputResources))
+}
+
+// predictionPrio returns the priority of the best variant of the predicted call
+// with respect to bias, or -1 if the call is unknown.
+func (ctx *mutator) predictionPrio(target *Target, bias int, name string) float64 {
+	if bias < 0 {
+		return -1
+	}
+	if meta := target.SyscallMap[name]; meta != nil {
+		return float64(ctx.ct.prio(bias, meta.ID)) / prioHigh
+	}
+	best := -1.0
+	for _, meta := range target.callVariants[baseCallName(name)] {
+		if ctx.ct.Generatable(meta.ID) {
+			best = math.Max(best, float64(ctx.ct.prio(bias, meta.ID))/prioHigh)
+		}
+	}
+	return best
+}
+
+// baseCallName strips the variant from the call name: "socket$SyzLLM" -> "socket".
+func baseCallName(name string) string {
+	if idx := strings.IndexByte(name, '$'); idx != -1 {
+		return name[:idx]
+	}
+	return name
+}
+
+func precedingResources(p *Prog, pos int) []*ResourceDesc {
+	var res []*ResourceDesc
+	for _, c := range p.Calls[:pos] {
+		res = append(res, c.Meta.outputResources...)
+	}
+	return res
+}
+
+// syzLLMCallStartRe matches what precedes a call name: start of the text, a resource tag or a result assignment.
+var syzLLMCallStartRe = `(^|` + regexp.QuoteMeta(RPrefix) + `|= )`
+
+// syzLLMCallNameRe matches names of the predicted call and of the calls nested in its resource tags.
+var syzLLMCallNameRe = regexp.MustCompile(syzLLMCallStartRe + `([a-zA-Z0-9_]+(?:\$[a-zA-Z0-9_]+)?)\(`)
+
+// resolveDescriptors replaces names of all calls in the predicted call text that are
+// not exact variant names with variants chosen by resolvePredictedSyscall.
+func (ctx *mutator) resolveDescriptors(p *Prog, pos, bias int, call string) string {
+	return syzLLMCallNameRe.ReplaceAllStringFunc(call, func(match string) string {
+		sub := syzLLMCallNameRe.FindStringSubmatch(match)
+		meta := ctx.resolvePredictedSyscall(p, pos, bias, sub[2])
+		if meta == nil {
+			return match
+		}
+		return sub[1] + meta.Name + "("
+	})
+}
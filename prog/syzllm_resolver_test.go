@@ -0,0 +1,60 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestResolvePredictedSyscall(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	enabled := make(map[*Syscall]bool)
+	for _, name := range []string{"test$res0", "test$res1", "test$str0", "mutate0"} {
+		enabled[target.SyscallMap[name]] = true
+	}
+	ct := target.BuildChoiceTable(nil, enabled)
+	resolve := func(prog, name string) map[string]int {
+		p, err := target.Deserialize([]byte(prog), Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := &mutator{
+			p:  p,
+			r:  newRand(target, rand.NewSource(0)),
+			ct: ct,
+		}
+		counts := make(map[string]int)
+		for i := 0; i < 3000; i++ {
+			meta := ctx.resolvePredictedSyscall(p, len(p.Calls), -1, name)
+			if meta == nil {
+				t.Fatalf("failed to resolve %v", name)
+			}
+			counts[meta.Name]++
+		}
+		return counts
+	}
+
+	// Exact variant names are used as is.
+	if counts := resolve("mutate0()\n", "test$res1"); counts["test$res1"] != 3000 {
+		t.Fatalf("exact name is not preserved: %v", counts)
+	}
+	// Base names are resolved only to enabled variants.
+	withRes := resolve("test$res0()\n", "test$SyzLLM")
+	withoutRes := resolve("mutate0()\n", "test$SyzLLM")
+	for _, counts := range []map[string]int{withRes, withoutRes} {
+		if len(counts) != 3 || counts["test$res0"]+counts["test$res1"]+counts["test$str0"] != 3000 {
+			t.Fatalf("resolved to disabled calls: %v", counts)
+		}
+	}
+	// test$res1 consumes the resource produced by test$res0,
+	// so it must be preferred when test$res0 precedes the insertion point.
+	if withRes["test$res1"] <= withoutRes["test$res1"]*5/4 {
+		t.Fatalf("resources are not taken into account: with %v, without %v", withRes, withoutRes)
+	}
+	if meta := (&mutator{r: newRand(target, rand.NewSource(0)), ct: ct}).resolvePredictedSyscall(
+		&Prog{Target: target}, 0, -1, "foo$SyzLLM"); meta != nil {
+		t.Fatalf("unknown call is resolved to %v", meta.Name)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	pipePrefix = "@PIPESTART@"
+	pipeSuffix = "@PIPEEND@"
+
+	// predictionResPlaceholder is the value resource tags are replaced with before deserialization
+	// (+ index of the tag), it's then used to find the ResultArg that corresponds to the tag.
+	predictionResPlaceholder     = uint64(0x5359a11a00000000)
+	predictionResPlaceholderMask = uint64(0xffffffff00000000)
+
+	// maxPredictionNesting limits recursion over calls nested in resource tags.
+	maxPredictionNesting = 4
+)
+
+// predictionResHint is a resource tag of a predicted call: the call that should produce the resource.
+type predictionResHint struct {
+	name string // syscall name of the producer
+	text string // the producer call itself, may contain more tags
+}
+
+// extractResourceHints replaces outermost resource tags (@RSTART@call(...)@REND@ and
+// @PIPESTART@pipe(...)@PIPEEND@) in the predicted call text with placeholder values,
+// so that the call can be deserialized alone. Returns the call and the tags in the order
+// of the placeholders.
+func extractResourceHints(call string) (string, []predictionResHint) {
+	var hints []predictionResHint
+	var res strings.Builder
+	for i := 0; i < len(call); {
+		var text string
+		switch {
+		case strings.HasPrefix(call[i:], RPrefix):
+			end := matchingResSuffix(call, i)
+			if end == -1 {
+				res.WriteString(call[i:])
+				i = len(call)
+				continue
+			}
+			text = call[i+len(RPrefix) : end]
+			i = end + len(RSuffix)
+		case strings.HasPrefix(call[i:], pipePrefix):
+			end := strings.Index(call[i:], pipeSuffix)
+			if end == -1 {
+				res.WriteString(call[i:])
+				i = len(call)
+				continue
+			}
+			text = call[i+len(pipePrefix) : i+end]
+			i += end + len(pipeSuffix)
+		default:
+			res.WriteByte(call[i])
+			i++
+			continue
+		}
+		fmt.Fprintf(&res, "0x%x", predictionResPlaceholder+uint64(len(hints)))
+		hints = append(hints, predictionResHint{
+			name: callNameFromText(text),
+			text: text,
+		})
+	}
+	return res.String(), hints
+}
+
+// matchingResSuffix returns index of RSuffix that closes RPrefix at call[start:], or -1.
+func matchingResSuffix(call string, start int) int {
+	depth := 0
+	for i := start; i < len(call); {
+		switch {
+		case strings.HasPrefix(call[i:], RPrefix):
+			depth++
+			i += len(RPrefix)
+		case strings.HasPrefix(call[i:], RSuffix):
+			if depth--; depth == 0 {
+				return i
+			}
+			i += len(RSuffix)
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+func predictionResHintIndex(arg *ResultArg, hints []predictionResHint) (int, bool) {
+	if arg.Res != nil || arg.Val&predictionResPlaceholderMask != predictionResPlaceholder {
+		return 0, false
+	}
+	idx := int(arg.Val - predictionResPlaceholder)
+	return idx, idx < len(hints)
+}
+
+// predictionBinder reconciles resources of a predicted call with the program it's inserted into.
+// The predicted call is deserialized alone, then each of its input resources is bound
+// to a compatible resource produced earlier in the program or by a producer call
+// inserted right before the predicted call.
+type predictionBinder struct {
+	ctx    *mutator
+	target *Target
+	s      *state  // resources available at the insertion point
+	prefix []*Call // program calls before the insertion point
+	calls  []*Call // calls to insert, the predicted call is the last one
+	used   map[*ResultArg]bool
+	norm   *Normalization // restores placeholders in arguments of the inserted calls
+	pos    int
+}
+
+// bindPredictedCall parses the predicted call text and returns the program calls
+// with the call and producers of its resources inserted at insertPosition.
+// If norm is not nil, argument placeholders are replaced with values from the program.
+func (ctx *mutator) bindPredictedCall(program *Prog, insertPosition int, call string,
+	norm *Normalization) ([]*Call, error) {
+	var c *Call
+	if insertPosition < len(program.Calls) {
+		c = program.Calls[insertPosition]
+	}
+	b := &predictionBinder{
+		ctx:    ctx,
+		target: program.Target,
+		s:      analyze(ctx.ct, ctx.corpus, program, c),
+		prefix: program.Calls[:insertPosition],
+		used:   make(map[*ResultArg]bool),
+		norm:   norm,
+		pos:    insertPosition,
+	}
+	if _, err := b.add(call, 0); err != nil {
+		return nil, err
+	}
+	calls := make([]*Call, 0, len(program.Calls)+len(b.calls))
+	calls = append(calls, program.Calls[:insertPosition]...)
+	calls = append(calls, b.calls...)
+	calls = append(calls, program.Calls[insertPosition:]...)
+	return calls, nil
+}
+
+// add deserializes the call text, binds its input resources and appends it to b.calls.
+func (b *predictionBinder) add(text string, depth int) (*Call, error) {
+	text, hints := extractResourceHints(text)
+	p, err := b.ctx.deserializePrediction(text, b.norm, b.pos)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Calls) != 1 {
+		return nil, fmt.Errorf("predicted %v calls instead of 1", len(p.Calls))
+	}
+	if err := checkPredictedCalls(p, b.ctx.ct); err != nil {
+		return nil, err
+	}
+	c := p.Calls[0]
+	var inputs []*ResultArg
+	ForeachArg(c, func(arg Arg, _ *ArgCtx) {
+		if a, ok := arg.(*ResultArg); ok && a.Res == nil {
+			inputs = append(inputs, a)
+		}
+	})
+	for _, arg := range inputs {
+		typ := arg.Type().(*ResourceType)
+		idx, tagged := predictionResHintIndex(arg, hints)
+		var res *ResultArg
+		switch {
+		case arg.Dir() == DirOut:
+			if tagged {
+				replaceResultArg(arg, typ.DefaultArg(DirOut).(*ResultArg))
+			}
+			continue
+		case tagged:
+			res = b.bindTagged(typ, arg.Dir(), hints[idx], depth)
+		default:
+			if res = b.existing(typ, arg.Dir()); res == nil {
+				res = b.create(typ, arg.Dir())
+			}
+		}
+		if res == nil {
+			if tagged {
+				replaceResultArg(arg, typ.DefaultArg(arg.Dir()).(*ResultArg))
+			}
+			continue
+		}
+		b.used[res.Res] = true
+		replaceResultArg(arg, res)
+	}
+	b.calls = append(b.calls, c)
+	b.s.analyze(c)
+	return c, nil
+}
+
+// bindTagged returns a resource for an input tagged with the producer call.
+// In order of preference: an unused output of the closest preceding call of the hinted syscall,
+// output of the tagged call inserted before the predicted call, any compatible existing resource,
+// a resource created with createResource.
+func (b *predictionBinder) bindTagged(typ *ResourceType, dir Dir, hint predictionResHint, depth int) *ResultArg {
+	if meta := b.target.SyscallMap[hint.name]; meta != nil {
+		if res := b.producedBy(typ, dir, meta); res != nil {
+			return res
+		}
+	}
+	if depth < maxPredictionNesting {
+		if c, err := b.add(hint.text, depth+1); err == nil {
+			if res := b.output(typ, dir, c); res != nil {
+				return res
+			}
+		}
+	}
+	if res := b.existing(typ, dir); res != nil {
+		return res
+	}
+	return b.create(typ, dir)
+}
+
+// create returns a resource created with createResource, its producers are inserted before the predicted call.
+func (b *predictionBinder) create(typ *ResourceType, dir Dir) *ResultArg {
+	res, calls := b.ctx.createResource(b.s, typ, dir)
+	b.calls = append(b.calls, calls...)
+	return res
+}
+
+// producedBy returns an output of the closest call of meta that precedes the predicted call.
+func (b *predictionBinder) producedBy(typ *ResourceType, dir Dir, meta *Syscall) *ResultArg {
+	for i := len(b.calls) - 1; i >= 0; i-- {
+		if b.calls[i].Meta == meta {
+			return b.output(typ, dir, b.calls[i])
+		}
+	}
+	for i := len(b.prefix) - 1; i >= 0; i-- {
+		if b.prefix[i].Meta == meta {
+			return b.output(typ, dir, b.prefix[i])
+		}
+	}
+	return nil
+}
+
+// output returns a compatible resource produced by c preferring ones that are not yet used
+// by the predicted call (e.g. for pipe(&{r0, r1}) the second tagged input gets r1).
+func (b *predictionBinder) output(typ *ResourceType, dir Dir, c *Call) *ResultArg {
+	var outputs []*ResultArg
+	ForeachArg(c, func(arg Arg, _ *ArgCtx) {
+		if a, ok := arg.(*ResultArg); ok && a.Dir() != DirIn &&
+			b.target.isCompatibleResource(typ.Desc.Name, a.Type().Name()) {
+			outputs = append(outputs, a)
+		}
+	})
+	if len(outputs) == 0 {
+		return nil
+	}
+	res := outputs[0]
+	for _, out := range outputs {
+		if !b.used[out] {
+			res = out
+			break
+		}
+	}
+	return MakeResultArg(typ, dir, res, 0)
+}
+
+func (b *predictionBinder) existing(typ *ResourceType, dir Dir) *ResultArg {
+	if arg := b.ctx.r.existingResource(b.s, typ, dir); arg != nil {
+		return arg.(*ResultArg)
+	}
+	return nil
+}
+
+// createResource creates a resource with new producer calls as generation does.
+// The calls are analyzed in s, but it's up to the caller to insert them.
+func (ctx *mutator) createResource(s *state, typ *ResourceType, dir Dir) (*ResultArg, []*Call) {
+	r := ctx.r
+	r.inGenerateResource = true
+	defer func() { r.inGenerateResource = false }()
+	arg, calls := r.createResource(s, typ, dir)
+	for _, c := range calls {
+		s.analyze(c)
+	}
+	if arg == nil {
+		return nil, nil
+	}
+	return arg.(*ResultArg), calls
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestExtractResourceHints(t *testing.T) {
+	tests := []struct {
+		call  string
+		want  string
+		hints []string
+	}{
+		{
+			call:  "read(0x1)",
+			want:  "read(0x1)",
+			hints: nil,
+		},
+		{
+			call: "sendto$llc(@RSTART@openat(@RSTART@openat(0x35, 0x0)@REND@, 0x0)@REND@, 0xb8)",
+			want: fmt.Sprintf("sendto$llc(0x%x, 0xb8)", predictionResPlaceholder),
+			hints: []string{
+				"openat(@RSTART@openat(0x35, 0x0)@REND@, 0x0)",
+			},
+		},
+		{
+			call: "epoll_ctl$EPOLL_CTL_ADD(@RSTART@epoll_create1(0x80000)@REND@, 0x1, " +
+				"@PIPESTART@pipe(&(0x7f0000064000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})@PIPEEND@, 0x0)",
+			want: fmt.Sprintf("epoll_ctl$EPOLL_CTL_ADD(0x%x, 0x1, 0x%x, 0x0)",
+				predictionResPlaceholder, predictionResPlaceholder+1),
+			hints: []string{
+				"epoll_create1(0x80000)",
+				"pipe(&(0x7f0000064000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})",
+			},
+		},
+		{
+			call:  "read(@RSTART@openat(0x35, 0x0), 0x1)",
+			want:  "read(@RSTART@openat(0x35, 0x0), 0x1)",
+			hints: nil,
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			got, hints := extractResourceHints(test.call)
+			if got != test.want {
+				t.Fatalf("got call:\n%v\nwant:\n%v", got, test.want)
+			}
+			if len(hints) != len(test.hints) {
+				t.Fatalf("got %v hints, want %v", len(hints), len(test.hints))
+			}
+			for i, hint := range hints {
+				if hint.text != test.hints[i] || hint.name != callNameFromText(test.hints[i]) {
+					t.Fatalf("hint #%v: got %+v, want %v", i, hint, test.hints[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBindPredictedCall(t *testing.T) {
+	target := initTargetTest(t, "test", "64")
+	tests := []struct {
+		prog     string
+		pos      int
+		call     string
+		want     string
+		producer bool // the tagged producer is inserted before the call
+	}{
+		{
+			prog: "r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)\nmutate0()\n",
+			pos:  2,
+			call: "mutate6(@RSTART@mutate5(&(0x7f0000000000)='./file1\\x00', 0x0)@REND@, &(0x7f0000000100)=\"0102\", 0x2)",
+			want: "r0 = mutate5(&(0x7f0000000000)='./file0\\x00', 0x0)\nmutate0()\n" +
+				"mutate6(r0, &(0x7f0000000100)=\"0102\", 0x2)\n",
+		},
+		{
+			prog: "mutate0()\n",
+			pos:  1,
+			call: "mutate6(@RSTART@mutate5(&(0x7f0000000000)='./file1\\x00', 0x0)@REND@, &(0x7f0000000100)=\"0102\", 0x2)",
+			want: "mutate0()\nr0 = mutate5(&(0x7f0000000000)='./file1\\x00', 0x0)\n" +
+				"mutate6(r0, &(0x7f0000000100)=\"0102\", 0x2)\n",
+		},
+		{
+			prog: "mutate0()\nmutate1()\n",
+			pos:  1,
+			call: "test$res1(@RSTART@test$res0()@REND@)",
+			want: "mutate0()\nr0 = test$res0()\ntest$res1(r0)\nmutate1()\n",
+		},
+		{
+			// Resource references in the predicted call are not preserved.
+			prog: "r0 = test$res0()\n",
+			pos:  1,
+			call: "test$res1(r5)",
+			want: "r0 = test$res0()\ntest$res1(r0)\n",
+		},
+		{
+			// There is no compatible resource for the untagged input, so it's created with createResource.
+			prog:     "",
+			pos:      0,
+			call:     "mutate6(r5, &(0x7f0000000100)=\"0102\", 0x2)",
+			producer: true,
+		},
+		{
+			// The producer can't be parsed, so the resource is created with createResource.
+			prog:     "mutate0()\n",
+			pos:      1,
+			call:     "mutate6(@RSTART@foo(@REND@, &(0x7f0000000100)=\"0102\", 0x2)",
+			producer: true,
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			p, err := target.Deserialize([]byte(test.prog), Strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := &mutator{
+				p:  p,
+				r:  newRand(target, rand.NewSource(0)),
+				ct: target.DefaultChoiceTable(),
+			}
+			calls, err := ctx.bindPredictedCall(p, test.pos, test.call, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Calls = calls
+			if err := p.validate(); err != nil {
+				t.Fatalf("invalid program: %v\n%s", err, p.Serialize())
+			}
+			data := string(p.Serialize())
+			if test.want != "" && data != test.want {
+				t.Fatalf("got program:\n%s\nwant:\n%s", data, test.want)
+			}
+			if test.producer {
+				c := calls[len(calls)-1]
+				if res, ok := c.Args[0].(*ResultArg); !ok || res.Res == nil || len(calls) < test.pos+2 {
+					t.Fatalf("resource is not created:\n%s", data)
+				}
+				if !strings.HasPrefix(data, test.prog) {
+					t.Fatalf("program prefix changed:\n%s", data)
+				}
+			}
+		})
+	}
+}
@@ -70,7 +70,9 @@ type Target struct {
 	resourceMap map[string]*ResourceDesc
 	// Maps resource name to a list of calls that can create the resource.
 	resourceCtors map[string][]*Syscall
-	any           anyTypes
+	// Maps CallName to all variants of the syscall.
+	callVariants map[string][]*Syscall
+	any          anyTypes
 
 	// The default ChoiceTable is used only by tests and utilities, so we initialize it lazily.
 	defaultOnce        sync.Once
@@ -163,9 +165,11 @@ func (target *Target) initTarget() {
 	target.initAnyTypes()
 
 	target.SyscallMap = make(map[string]*Syscall)
+	target.callVariants = make(map[string][]*Syscall)
 	for i, c := range target.Syscalls {
 		c.ID = i
 		target.SyscallMap[c.Name] = c
+		target.callVariants[c.CallName] = append(target.callVariants[c.CallName], c)
 	}
 
 	target.populateResourceCtors()
@@ -0,0 +1,121 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/rpctype"
+)
+
+// Power schedule: corpus inputs are chosen for mutation proportionally to their energy.
+// The energy starts at the static signal priority of the input, grows with the number of mutants
+// of the input that gave new signal and decays exponentially for inputs that were mutated many times
+// without that (exhausted seeds). In directed fuzzing, inputs close to the targets get more energy.
+// The counters are synced to the manager, so that they survive fuzzer and manager restarts
+// and are passed to fuzzers that receive the input on corpus rotation.
+const (
+	// The energy is multiplied by 1 + the number of mutants with new signal, up to this bound.
+	seedMaxBoost = 16
+	// The energy is halved every seedExhaustPeriod mutations per mutant with new signal.
+	seedExhaustPeriod = 200
+	// Limit on the number of halvings, so that exhausted inputs are still mutated from time to time.
+	seedMaxDecay = 10
+	// In directed fuzzing, the energy of inputs covering a target function is multiplied by 1<<directedMaxShift,
+	// the multiplier is halved for every further function on the call path to the targets (rpctype.Input.Distance).
+	directedMaxShift = 6
+)
+
+// seed is the power schedule state of a corpus input.
+type seed struct {
+	// The counters go first to be 64-bit aligned for atomic operations on 32-bit platforms.
+	// They include the counters received from the manager.
+	mutations uint64
+	newSignal uint64
+	// Part of the counters already sent to the manager, used only by grabSeedStats.
+	synced rpctype.SeedStats
+
+	sig      hash.Sig
+	prio     int64  // static priority based on the input signal
+	distance uint32 // to directed fuzzing targets, 0 if none
+}
+
+func newSeed(sig hash.Sig, prio int64, stats rpctype.SeedStats, distance uint32) *seed {
+	return &seed{
+		mutations: stats.Mutations,
+		newSignal: stats.NewSignal,
+		synced:    stats,
+		sig:       sig,
+		prio:      prio,
+		distance:  distance,
+	}
+}
+
+// noteMutant accounts an execution of a mutant of the input.
+func (s *seed) noteMutant(newSignal bool) {
+	atomic.AddUint64(&s.mutations, 1)
+	if newSignal {
+		atomic.AddUint64(&s.newSignal, 1)
+	}
+}
+
+func (s *seed) energy() int64 {
+	mutations := atomic.LoadUint64(&s.mutations)
+	newSignal := atomic.LoadUint64(&s.newSignal)
+	boost := newSignal
+	if boost > seedMaxBoost {
+		boost = seedMaxBoost
+	}
+	decay := mutations / (seedExhaustPeriod * (newSignal + 1))
+	if decay > seedMaxDecay {
+		decay = seedMaxDecay
+	}
+	directed := uint64(0)
+	if s.distance != 0 && s.distance <= directedMaxShift {
+		directed = directedMaxShift + 1 - uint64(s.distance)
+	}
+	energy := s.prio * int64(1+boost) << directed >> decay
+	if energy < 1 {
+		energy = 1
+	}
+	return energy
+}
+
+// updateEnergy recalculates the corpus priorities from the current energy of the inputs.
+// Snapshots share the priorities, so they are replaced rather than modified.
+func (fuzzer *Fuzzer) updateEnergy() {
+	fuzzer.corpusMu.Lock()
+	defer fuzzer.corpusMu.Unlock()
+	prios := make([]int64, len(fuzzer.corpusSeeds))
+	sum := int64(0)
+	for i, s := range fuzzer.corpusSeeds {
+		sum += s.energy()
+		prios[i] = sum
+	}
+	fuzzer.corpusPrios = prios
+	fuzzer.sumPrios = sum
+}
+
+// grabSeedStats returns the counters accumulated since the last call for the manager.
+func (fuzzer *Fuzzer) grabSeedStats() map[string]rpctype.SeedStats {
+	fuzzer.corpusMu.RLock()
+	defer fuzzer.corpusMu.RUnlock()
+	res := make(map[string]rpctype.SeedStats)
+	for _, s := range fuzzer.corpusSeeds {
+		stats := rpctype.SeedStats{
+			Mutations: atomic.LoadUint64(&s.mutations),
+			NewSignal: atomic.LoadUint64(&s.newSignal),
+		}
+		if stats == s.synced {
+			continue
+		}
+		res[s.sig.String()] = rpctype.SeedStats{
+			Mutations: stats.Mutations - s.synced.Mutations,
+			NewSignal: stats.NewSignal - s.synced.NewSignal,
+		}
+		s.synced = stats
+	}
+	return res
+}
@@ -40,8 +40,19 @@ type Fuzzer struct {
 	gate        *ipc.Gate
 	workQueue   *WorkQueue
 	needPoll    chan struct{}
-	choiceTable *prog.ChoiceTable
 	noMutate    map[int]bool
+	opSched     *prog.OpScheduler
+	predictions *PredictionService // nil unless SyzLLM server is used
+	predStats   *predictionStats   // nil unless SyzLLM is enabled
+	predictor   prog.CallPredictor // nil unless SyzLLM is enabled
+	ngrams      *corpusNgrams      // nil unless the "ngram" predictor is used
+	insertProb  int
+	argProb     int
+	genProb     int
+	strict      bool        // drop predictions that need repairs
+	callPolicy  *callPolicy // nil unless SyzLLM is enabled
+	promptLevel prog.PromptLevel
+	syzLLMCalls []int // calls enabled on all VMs by the "propose" SyzLLM policy
 	// The stats field cannot unfortunately be just an uint64 array, because it
 	// results in "unaligned 64-bit atomic operation" errors on 32-bit platforms.
 	stats             []uint64
@@ -57,7 +68,8 @@ type Fuzzer struct {
 	corpusMu     sync.RWMutex
 	corpus       []*prog.Prog
 	corpusHashes map[hash.Sig]struct{}
-	corpusPrios  []int64
+	corpusSeeds  []*seed // power schedule state of the corpus inputs
+	corpusPrios  []int64 // prefix sums of the energy of the corpus inputs, see updateEnergy
 	sumPrios     int64
 
 	signalMu     sync.RWMutex
@@ -65,12 +77,20 @@ type Fuzzer struct {
 	maxSignal    signal.Signal // max signal ever observed including flakes
 	newSignal    signal.Signal // diff of maxSignal since last sync with master
 
+	// The table is replaced when calls are enabled by the manager or the corpus grows,
+	// but never modified.
+	choiceTableMu sync.RWMutex
+	choiceTable   *prog.ChoiceTable
+	// Corpus size the table priorities and n-gram model were built from.
+	choiceTableCorpus int
+
 	checkResult *rpctype.CheckArgs
 	logMu       sync.Mutex
 }
 
 type FuzzerSnapshot struct {
 	corpus      []*prog.Prog
+	corpusSeeds []*seed
 	corpusPrios []int64
 	sumPrios    int64
 }
@@ -79,6 +99,7 @@ type Stat int
 
 const (
 	StatGenerate Stat = iota
+	StatGenerateSyzLLM
 	StatFuzz
 	StatCandidate
 	StatTriage
@@ -93,6 +114,7 @@ const (
 
 var statNames = [StatCount]string{
 	StatGenerate:       "exec gen",
+	StatGenerateSyzLLM: "exec gen syzllm",
 	StatFuzz:           "exec fuzz",
 	StatCandidate:      "exec candidate",
 	StatTriage:         "exec triage",
@@ -157,6 +179,7 @@ func main() {
 		flagTest     = flag.Bool("test", false, "enable image testing mode")      // used by syz-ci
 		flagRunTest  = flag.Bool("runtest", false, "enable program testing mode") // used by pkg/runtest
 		flagRawCover = flag.Bool("raw_cover", false, "fetch raw coverage")
+		flagRecord   = flag.String("syzllm_record", "", "record SyzLLM predictions to JSONL file for syz-mutate")
 	)
 	defer tool.Init()()
 	outputType := parseOutputType(*flagOutput)
@@ -280,11 +303,13 @@ func main() {
 		checkResult:              r.CheckResult,
 		fetchRawCover:            *flagRawCover,
 		noMutate:                 r.NoMutateCalls,
+		opSched:                  prog.NewOpScheduler(r.AdaptiveMutations),
+		syzLLMCalls:              r.SyzLLM.AcceptedCalls,
 		stats:                    make([]uint64, StatCount),
 	}
 	gateCallback := fuzzer.useBugFrames(r, *flagProcs)
 	fuzzer.gate = ipc.NewGate(2**flagProcs, gateCallback)
-	BuildTable(target)
+	BuildTable(target, r.SyzLLM.Bundle)
 
 	for needCandidates, more := true, true; more; needCandidates = false {
 		more = fuzzer.poll(needCandidates, nil)
@@ -292,24 +317,13 @@ func main() {
 		log.Logf(0, "fetching corpus: %v, signal %v/%v (executing program)",
 			len(fuzzer.corpus), len(fuzzer.corpusSignal), len(fuzzer.maxSignal))
 	}
-	calls := make(map[*prog.Syscall]bool)
-	for _, id := range r.CheckResult.EnabledCalls[sandbox] {
-		calls[target.Syscalls[id]] = true
-	}
-	fuzzer.choiceTable = target.BuildChoiceTable(fuzzer.corpus, calls)
+	fuzzer.setupPredictor(&r.SyzLLM, *flagRecord)
+	fuzzer.setChoiceTable(fuzzer.buildChoiceTable())
 
 	if r.CoverFilterBitmap != nil {
 		fuzzer.execOpts.Flags |= ipc.FlagEnableCoverageFilter
 	}
 
-	//dummyProg := prog.Prog{
-	//	Target:   target,
-	//	Calls:    make([]*prog.Call, 0),
-	//	Comments: make([]string, 0),
-	//}
-	//dummyProg.RequestAndVerifyCall()
-	//log.Fatalf("Verify done")
-
 	log.Logf(0, "starting %v fuzzer processes", *flagProcs)
 	for pid := 0; pid < *flagProcs; pid++ {
 		proc, err := newProc(fuzzer, pid)
@@ -323,6 +337,131 @@ func main() {
 	fuzzer.pollLoop()
 }
 
+// setupPredictor makes mutations consult the configured SyzLLM predictor, if any.
+// If recordFile is set, all insertions of predicted calls are recorded there.
+func (fuzzer *Fuzzer) setupPredictor(cfg *rpctype.SyzLLMConfig, recordFile string) {
+	if cfg.Predictor == "" {
+		return
+	}
+	fuzzer.predStats = newPredictionStats()
+	pred := &observedPredictor{stats: fuzzer.predStats}
+	switch cfg.Predictor {
+	case "http":
+		log.Logf(0, "using SyzLLM server at %v", cfg.Addr)
+		// Requests from all procs are batched and answered asynchronously.
+		fuzzer.predictions = newPredictionService(prog.NewHTTPPredictor(cfg.Addr, cfg.Timeout), fuzzer.predStats)
+		go fuzzer.predictions.loop()
+		pred.CallPredictor = fuzzer.predictions
+	case "ngram":
+		log.Logf(0, "using n-gram predictor built from %v corpus programs", len(fuzzer.corpus))
+		fuzzer.ngrams = &corpusNgrams{pred: prog.NewNgramPredictor(fuzzer.corpus, ngramSize)}
+		pred.CallPredictor = fuzzer.ngrams
+		pred.timed = true
+	default:
+		log.SyzFatalf("unknown SyzLLM predictor %q", cfg.Predictor)
+	}
+	promptLevel, err := prog.ParsePromptLevel(cfg.Prompt)
+	if err != nil {
+		log.SyzFatalf("bad SyzLLM config: %v", err)
+	}
+	fuzzer.predictor = pred
+	if recordFile != "" {
+		fuzzer.predictor, err = newPredictionLog(pred, recordFile)
+		if err != nil {
+			log.SyzFatalf("%v", err)
+		}
+	}
+	fuzzer.insertProb = cfg.InsertProb
+	fuzzer.argProb = cfg.ArgProb
+	fuzzer.genProb = cfg.GenerateProb
+	fuzzer.strict = cfg.StrictRepair
+	fuzzer.promptLevel = promptLevel
+	supported := cfg.SupportedCalls
+	if supported == nil {
+		supported = fuzzer.checkResult.EnabledCalls[ipc.FlagsToSandbox(fuzzer.config.Flags)]
+	}
+	fuzzer.callPolicy = newCallPolicy(fuzzer.target, cfg.DisabledCalls, supported)
+}
+
+// buildChoiceTable builds the table for the calls enabled on the VM
+// and the calls enabled by the manager under the "propose" SyzLLM policy.
+func (fuzzer *Fuzzer) buildChoiceTable() *prog.ChoiceTable {
+	calls := make(map[*prog.Syscall]bool)
+	for _, id := range fuzzer.checkResult.EnabledCalls[ipc.FlagsToSandbox(fuzzer.config.Flags)] {
+		calls[fuzzer.target.Syscalls[id]] = true
+	}
+	for _, id := range fuzzer.syzLLMCalls {
+		calls[fuzzer.target.Syscalls[id]] = true
+	}
+	corpus := fuzzer.snapshot().corpus
+	fuzzer.choiceTableCorpus = len(corpus)
+	if fuzzer.ngrams != nil {
+		fuzzer.ngrams.rebuild(corpus)
+	}
+	ct := fuzzer.target.BuildChoiceTable(corpus, calls)
+	ct.SetOpScheduler(fuzzer.opSched)
+	if fuzzer.predictor != nil {
+		ct.SetPredictor(fuzzer.predictor, fuzzer.insertProb)
+		ct.SetArgPredictionProb(fuzzer.argProb)
+		ct.SetPredictionCallPolicy(fuzzer.callPolicy)
+		ct.SetPromptLevel(fuzzer.promptLevel)
+		ct.SetStrictRepair(fuzzer.strict)
+	}
+	return ct
+}
+
+func (fuzzer *Fuzzer) setChoiceTable(ct *prog.ChoiceTable) {
+	fuzzer.choiceTableMu.Lock()
+	defer fuzzer.choiceTableMu.Unlock()
+	fuzzer.choiceTable = ct
+}
+
+func (fuzzer *Fuzzer) getChoiceTable() *prog.ChoiceTable {
+	fuzzer.choiceTableMu.RLock()
+	defer fuzzer.choiceTableMu.RUnlock()
+	return fuzzer.choiceTable
+}
+
+// acceptSyzLLMCalls rebuilds the choice table when the manager enables new calls
+// under the "propose" SyzLLM policy. Procs pick up the new table on their next iteration.
+func (fuzzer *Fuzzer) acceptSyzLLMCalls(calls []int) {
+	if len(calls) <= len(fuzzer.syzLLMCalls) {
+		return
+	}
+	for _, id := range calls[len(fuzzer.syzLLMCalls):] {
+		log.Logf(0, "SyzLLM: manager enabled call %v", fuzzer.target.Syscalls[id].Name)
+	}
+	fuzzer.syzLLMCalls = calls
+	if fuzzer.getChoiceTable() == nil {
+		// Still fetching the corpus, the table will be built with the calls.
+		return
+	}
+	fuzzer.setChoiceTable(fuzzer.buildChoiceTable())
+}
+
+// ngramSize is the order of the in-process n-gram predictor:
+// it conditions on ngramSize-1 preceding calls.
+const ngramSize = 3
+
+// corpusNgrams is the "ngram" predictor, the model is rebuilt from the corpus along with the choice table.
+type corpusNgrams struct {
+	mu   sync.RWMutex
+	pred *prog.NgramPredictor
+}
+
+func (cn *corpusNgrams) Predict(calls []string) ([]prog.Prediction, error) {
+	cn.mu.RLock()
+	defer cn.mu.RUnlock()
+	return cn.pred.Predict(calls)
+}
+
+func (cn *corpusNgrams) rebuild(corpus []*prog.Prog) {
+	pred := prog.NewNgramPredictor(corpus, ngramSize)
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.pred = pred
+}
+
 func collectMachineInfos(target *prog.Target) ([]byte, []host.KernelModule) {
 	machineInfo, err := host.CollectMachineInfo()
 	if err != nil {
@@ -417,19 +556,54 @@ func (fuzzer *Fuzzer) pollLoop() {
 				stats[statNames[stat]] = v
 				execTotal += v
 			}
+			if fuzzer.predStats != nil {
+				fuzzer.predStats.collect(stats)
+			}
+			for op, stat := range fuzzer.opSched.TakeStats() {
+				stats[rpctype.MutationTriesStat+prog.MutationOp(op).String()] = stat.Tries
+				stats[rpctype.MutationSignalStat+prog.MutationOp(op).String()] = stat.NewSignal
+			}
 			if !fuzzer.poll(needCandidates, stats) {
 				lastPoll = time.Now()
 			}
+			fuzzer.refreshChoiceTable()
+			fuzzer.updateEnergy()
 		}
 	}
 }
 
+// refreshChoiceTable rebuilds the choice table once the corpus has grown by choiceTableGrowth percent,
+// so that call priorities and the n-gram call model (and the "ngram" predictor, if used) follow the corpus.
+func (fuzzer *Fuzzer) refreshChoiceTable() {
+	fuzzer.corpusMu.RLock()
+	size := len(fuzzer.corpus)
+	fuzzer.corpusMu.RUnlock()
+	if size < choiceTableMinCorpus || size*100 < fuzzer.choiceTableCorpus*(100+choiceTableGrowth) {
+		return
+	}
+	log.Logf(1, "rebuilding choice table for %v corpus programs", size)
+	fuzzer.setChoiceTable(fuzzer.buildChoiceTable())
+}
+
+const (
+	choiceTableMinCorpus = 100
+	choiceTableGrowth    = 20
+)
+
 func (fuzzer *Fuzzer) poll(needCandidates bool, stats map[string]uint64) bool {
 	a := &rpctype.PollArgs{
 		Name:           fuzzer.name,
 		NeedCandidates: needCandidates,
 		MaxSignal:      fuzzer.grabNewSignal().Serialize(),
 		Stats:          stats,
+
+		SyzLLMProposals: fuzzer.callPolicy.grabProposals(),
+		SyzLLMDown:      !fuzzer.predictions.Available(),
+		MutationWeights: make(map[string]uint64),
+		SeedStats:       fuzzer.grabSeedStats(),
+	}
+	for op, weight := range fuzzer.opSched.Weights() {
+		a.MutationWeights[prog.MutationOp(op).String()] = uint64(weight * 1000)
 	}
 	r := &rpctype.PollRes{}
 	if err := fuzzer.manager.Call("Manager.Poll", a, r); err != nil {
@@ -445,20 +619,24 @@ func (fuzzer *Fuzzer) poll(needCandidates bool, stats map[string]uint64) bool {
 	for _, candidate := range r.Candidates {
 		fuzzer.addCandidateInput(candidate)
 	}
+	fuzzer.acceptSyzLLMCalls(r.SyzLLMCalls)
 	if needCandidates && len(r.Candidates) == 0 && atomic.LoadUint32(&fuzzer.triagedCandidates) == 0 {
 		atomic.StoreUint32(&fuzzer.triagedCandidates, 1)
 	}
 	return len(r.NewInputs) != 0 || len(r.Candidates) != 0 || maxSignal.Len() != 0
 }
 
-func (fuzzer *Fuzzer) sendInputToManager(inp rpctype.Input) {
+// sendInputToManager returns the power schedule counters and the distance of the input saved by the manager.
+func (fuzzer *Fuzzer) sendInputToManager(inp rpctype.Input) *rpctype.NewInputRes {
 	a := &rpctype.NewInputArgs{
 		Name:  fuzzer.name,
 		Input: inp,
 	}
-	if err := fuzzer.manager.Call("Manager.NewInput", a, nil); err != nil {
+	r := &rpctype.NewInputRes{}
+	if err := fuzzer.manager.Call("Manager.NewInput", a, r); err != nil {
 		log.SyzFatalf("Manager.NewInput call failed: %v", err)
 	}
+	return r
 }
 
 func (fuzzer *Fuzzer) addInputFromAnotherFuzzer(inp rpctype.Input) {
@@ -468,7 +646,7 @@ func (fuzzer *Fuzzer) addInputFromAnotherFuzzer(inp rpctype.Input) {
 	}
 	sig := hash.Hash(inp.Prog)
 	sign := inp.Signal.Deserialize()
-	fuzzer.addInputToCorpus(p, sign, sig)
+	fuzzer.addInputToCorpus(p, sign, sig, inp.Seed, inp.Distance)
 }
 
 func (fuzzer *Fuzzer) addCandidateInput(candidate rpctype.Candidate) {
@@ -486,6 +664,7 @@ func (fuzzer *Fuzzer) addCandidateInput(candidate rpctype.Candidate) {
 	fuzzer.workQueue.enqueue(&WorkCandidate{
 		p:     p,
 		flags: flags,
+		prov:  candidate.Provenance,
 	})
 }
 
@@ -496,7 +675,7 @@ func (fuzzer *Fuzzer) deserializeInput(inp []byte) *prog.Prog {
 	}
 	// We build choice table only after we received the initial corpus,
 	// so we don't check the initial corpus here, we check it later in BuildChoiceTable.
-	if fuzzer.choiceTable != nil {
+	if fuzzer.getChoiceTable() != nil {
 		fuzzer.checkDisabledCalls(p)
 	}
 	if len(p.Calls) > prog.MaxCalls {
@@ -506,8 +685,9 @@ func (fuzzer *Fuzzer) deserializeInput(inp []byte) *prog.Prog {
 }
 
 func (fuzzer *Fuzzer) checkDisabledCalls(p *prog.Prog) {
+	ct := fuzzer.getChoiceTable()
 	for _, call := range p.Calls {
-		if !fuzzer.choiceTable.Enabled(call.Meta.ID) {
+		if !ct.Enabled(call.Meta.ID) && !fuzzer.callPolicy.allowed(call.Meta) {
 			fmt.Printf("executing disabled syscall %v [%v]\n", call.Meta.Name, call.Meta.ID)
 			sandbox := ipc.FlagsToSandbox(fuzzer.config.Flags)
 			fmt.Printf("check result for sandbox=%v:\n", sandbox)
@@ -517,7 +697,7 @@ func (fuzzer *Fuzzer) checkDisabledCalls(p *prog.Prog) {
 			}
 			fmt.Printf("choice table:\n")
 			for i, meta := range fuzzer.target.Syscalls {
-				fmt.Printf("  #%v: %v [%v]: enabled=%v\n", i, meta.Name, meta.ID, fuzzer.choiceTable.Enabled(meta.ID))
+				fmt.Printf("  #%v: %v [%v]: enabled=%v\n", i, meta.Name, meta.ID, ct.Enabled(meta.ID))
 			}
 			panic("disabled syscall")
 		}
@@ -525,14 +705,19 @@ func (fuzzer *Fuzzer) checkDisabledCalls(p *prog.Prog) {
 }
 
 func (fuzzer *FuzzerSnapshot) chooseProgram(r *rand.Rand) *prog.Prog {
+	return fuzzer.corpus[fuzzer.chooseSeed(r)]
+}
+
+// chooseSeed returns index of a corpus input chosen proportionally to its energy.
+func (fuzzer *FuzzerSnapshot) chooseSeed(r *rand.Rand) int {
 	randVal := r.Int63n(fuzzer.sumPrios + 1)
-	idx := sort.Search(len(fuzzer.corpusPrios), func(i int) bool {
+	return sort.Search(len(fuzzer.corpusPrios), func(i int) bool {
 		return fuzzer.corpusPrios[i] >= randVal
 	})
-	return fuzzer.corpus[idx]
 }
 
-func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig hash.Sig) {
+func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig hash.Sig,
+	stats rpctype.SeedStats, distance uint32) {
 	fuzzer.corpusMu.Lock()
 	if _, ok := fuzzer.corpusHashes[sig]; !ok {
 		fuzzer.corpus = append(fuzzer.corpus, p)
@@ -541,7 +726,9 @@ func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig has
 		if sign.Empty() {
 			prio = 1
 		}
-		fuzzer.sumPrios += prio
+		s := newSeed(sig, prio, stats, distance)
+		fuzzer.corpusSeeds = append(fuzzer.corpusSeeds, s)
+		fuzzer.sumPrios += s.energy()
 		fuzzer.corpusPrios = append(fuzzer.corpusPrios, fuzzer.sumPrios)
 	}
 	fuzzer.corpusMu.Unlock()
@@ -557,7 +744,7 @@ func (fuzzer *Fuzzer) addInputToCorpus(p *prog.Prog, sign signal.Signal, sig has
 func (fuzzer *Fuzzer) snapshot() FuzzerSnapshot {
 	fuzzer.corpusMu.RLock()
 	defer fuzzer.corpusMu.RUnlock()
-	return FuzzerSnapshot{fuzzer.corpus, fuzzer.corpusPrios, fuzzer.sumPrios}
+	return FuzzerSnapshot{fuzzer.corpus, fuzzer.corpusSeeds, fuzzer.corpusPrios, fuzzer.sumPrios}
 }
 
 func (fuzzer *Fuzzer) addMaxSignal(sign signal.Signal) {
@@ -589,19 +776,35 @@ func (fuzzer *Fuzzer) corpusSignalDiff(sign signal.Signal) signal.Signal {
 func (fuzzer *Fuzzer) checkNewSignal(p *prog.Prog, info *ipc.ProgInfo) (calls []int, extra bool) {
 	fuzzer.signalMu.RLock()
 	defer fuzzer.signalMu.RUnlock()
+	newSignal := 0
 	for i, inf := range info.Calls {
-		if fuzzer.checkNewCallSignal(p, &inf, i) {
+		if n := fuzzer.checkNewCallSignal(p, &inf, i); n != 0 {
 			calls = append(calls, i)
+			newSignal += n
 		}
 	}
-	extra = fuzzer.checkNewCallSignal(p, &info.Extra, -1)
+	n := fuzzer.checkNewCallSignal(p, &info.Extra, -1)
+	extra = n != 0
+	newSignal += n
+	if len(p.MutationOps) != 0 {
+		fuzzer.opSched.NoteResult(p.MutationOps, newSignal != 0)
+	}
+	if newSignal != 0 && p.Predicted != nil && fuzzer.predictions != nil {
+		fuzzer.predictions.reportOutcome(prog.PredictionOutcome{
+			ID:        p.Predicted.ID,
+			Call:      p.Predicted.Call,
+			Position:  p.Predicted.Position,
+			NewSignal: newSignal,
+		})
+	}
 	return
 }
 
-func (fuzzer *Fuzzer) checkNewCallSignal(p *prog.Prog, info *ipc.CallInfo, call int) bool {
+// checkNewCallSignal merges new signal of the call into maxSignal and returns its amount.
+func (fuzzer *Fuzzer) checkNewCallSignal(p *prog.Prog, info *ipc.CallInfo, call int) int {
 	diff := fuzzer.maxSignal.DiffRaw(info.Signal, signalPrio(p, info, call))
 	if diff.Empty() {
-		return false
+		return 0
 	}
 	fuzzer.signalMu.RUnlock()
 	fuzzer.signalMu.Lock()
@@ -609,7 +812,7 @@ func (fuzzer *Fuzzer) checkNewCallSignal(p *prog.Prog, info *ipc.CallInfo, call
 	fuzzer.newSignal.Merge(diff)
 	fuzzer.signalMu.Unlock()
 	fuzzer.signalMu.RLock()
-	return true
+	return diff.Len()
 }
 
 func signalPrio(p *prog.Prog, info *ipc.CallInfo, call int) (prio uint8) {
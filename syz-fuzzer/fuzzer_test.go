@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/pkg/signal"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
@@ -39,7 +40,7 @@ func TestChooseProgram(t *testing.T) {
 			sizeSig = 0
 		}
 		inp := generateInput(target, rs, 10, sizeSig)
-		fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig)
+		fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig, rpctype.SeedStats{}, 0)
 		priorities[inp.p] = int64(len(inp.sign))
 	}
 	snapshot := fuzzer.snapshot()
@@ -71,7 +72,7 @@ func TestAddInputConcurrency(t *testing.T) {
 			r := rand.New(rs)
 			for it := 0; it < iters; it++ {
 				inp := generateInput(target, rs, 10, it)
-				fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig)
+				fuzzer.addInputToCorpus(inp.p, inp.sign, inp.sig, rpctype.SeedStats{}, 0)
 				snapshot := fuzzer.snapshot()
 				snapshot.chooseProgram(r).Clone()
 			}
@@ -79,6 +80,71 @@ func TestAddInputConcurrency(t *testing.T) {
 	}
 }
 
+func TestSeedEnergy(t *testing.T) {
+	tests := []struct {
+		stats    rpctype.SeedStats
+		distance uint32
+		energy   int64
+	}{
+		{rpctype.SeedStats{}, 0, 100},
+		{rpctype.SeedStats{Mutations: seedExhaustPeriod - 1}, 0, 100},
+		{rpctype.SeedStats{Mutations: seedExhaustPeriod}, 0, 50},
+		{rpctype.SeedStats{Mutations: 3 * seedExhaustPeriod}, 0, 12},
+		{rpctype.SeedStats{Mutations: 100 * seedExhaustPeriod}, 0, 1},
+		{rpctype.SeedStats{Mutations: seedExhaustPeriod, NewSignal: 1}, 0, 200},
+		{rpctype.SeedStats{Mutations: 4 * seedExhaustPeriod, NewSignal: 1}, 0, 50},
+		{rpctype.SeedStats{Mutations: 100, NewSignal: 100}, 0, 100 * (1 + seedMaxBoost)},
+		{rpctype.SeedStats{}, 1, 100 << directedMaxShift},
+		{rpctype.SeedStats{}, 3, 100 << (directedMaxShift - 2)},
+		{rpctype.SeedStats{}, directedMaxShift, 200},
+		{rpctype.SeedStats{}, directedMaxShift + 1, 100},
+		{rpctype.SeedStats{Mutations: 100 * seedExhaustPeriod}, 1, 100 << directedMaxShift >> seedMaxDecay},
+	}
+	for i, test := range tests {
+		if energy := newSeed(hash.Sig{}, 100, test.stats, test.distance).energy(); energy != test.energy {
+			t.Errorf("test #%v: energy %v, want %v", i, energy, test.energy)
+		}
+	}
+}
+
+func TestSeedStats(t *testing.T) {
+	rs := rand.NewSource(0)
+	r := rand.New(rs)
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	fuzzer := &Fuzzer{corpusHashes: make(map[hash.Sig]struct{})}
+	exhausted := generateInput(target, rs, 10, 100)
+	fuzzer.addInputToCorpus(exhausted.p, exhausted.sign, exhausted.sig,
+		rpctype.SeedStats{Mutations: 100 * seedExhaustPeriod}, 0)
+	fresh := generateInput(target, rs, 10, 100)
+	fuzzer.addInputToCorpus(fresh.p, fresh.sign, fresh.sig, rpctype.SeedStats{}, 0)
+	if stats := fuzzer.grabSeedStats(); len(stats) != 0 {
+		t.Fatalf("counters received from the manager are reported back: %v", stats)
+	}
+	snapshot := fuzzer.snapshot()
+	for i := 0; i < 1000; i++ {
+		idx := snapshot.chooseSeed(r)
+		snapshot.corpusSeeds[idx].noteMutant(snapshot.corpus[idx] == fresh.p && i%10 == 0)
+	}
+	stats := fuzzer.grabSeedStats()
+	if len(stats) != 2 {
+		t.Fatalf("got counters for %v inputs, want 2", len(stats))
+	}
+	freshStats, exhaustedStats := stats[fresh.sig.String()], stats[exhausted.sig.String()]
+	if freshStats.Mutations+exhaustedStats.Mutations != 1000 || exhaustedStats.NewSignal != 0 {
+		t.Fatalf("wrong counters: fresh %+v, exhausted %+v", freshStats, exhaustedStats)
+	}
+	if exhaustedStats.Mutations*10 > freshStats.Mutations {
+		t.Fatalf("exhausted input is chosen too often: fresh %+v, exhausted %+v", freshStats, exhaustedStats)
+	}
+	if stats := fuzzer.grabSeedStats(); len(stats) != 0 {
+		t.Fatalf("counters are reported twice: %v", stats)
+	}
+	fuzzer.updateEnergy()
+	if want := fuzzer.corpusSeeds[0].energy() + fuzzer.corpusSeeds[1].energy(); fuzzer.sumPrios != want {
+		t.Fatalf("sum of priorities is %v, want %v", fuzzer.sumPrios, want)
+	}
+}
+
 func generateInput(target *prog.Target, rs rand.Source, ncalls, sizeSig int) (inp InputTest) {
 	inp.p = target.Generate(rs, ncalls, target.DefaultChoiceTable())
 	var raw []uint32
@@ -0,0 +1,459 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+)
+
+const (
+	predictBatchSize     = 32
+	predictBatchInterval = 100 * time.Millisecond
+	predictCacheSize     = 1 << 14
+	predictMaxPending    = 4 * predictBatchSize
+	predictMaxOutcomes   = 1 << 10
+	predictWaitTimeout   = 10 * time.Second
+	// After predictMaxFailures consecutive failed requests the server is considered down.
+	// Requests are retried with exponential backoff between predictMinBackoff and predictMaxBackoff.
+	predictMaxFailures = 3
+	predictMinBackoff  = time.Second
+	predictMaxBackoff  = 5 * time.Minute
+)
+
+// PredictionService sits between mutations of all procs and the actual predictor.
+// Requests are answered from a cache keyed by the masked sequence. On a miss
+// the sequence is queued and ErrPredictionPending is returned, so mutation falls
+// back to the ChoiceTable instead of waiting for the network. Queued sequences are
+// sent to the predictor in batches in the background and the answers are cached.
+// Outcomes of predictions are sent back to the predictor in the same loop.
+// Generation of programs can't fall back to anything, so it waits for the answers, see PredictWait.
+//
+// Failed requests are retried with exponential backoff. After several consecutive failures
+// the circuit opens: the service reports that it's not available, so mutations don't use it
+// and behave as stock syzkaller, and the server is re-probed with the same backoff until it answers.
+type PredictionService struct {
+	backend prog.CallPredictor
+	stats   *predictionStats
+
+	down     uint32 // the circuit is open, accessed atomically
+	failures int    // consecutive failures, the rest is used only by loop
+	backoff  time.Duration
+	retry    time.Time
+
+	mu         sync.Mutex
+	cache      map[hash.Sig][]prog.Prediction
+	cacheOrder []hash.Sig // in insertion order, for eviction
+	pending    map[hash.Sig][]string
+	outcomes   []prog.PredictionOutcome
+	kick       chan struct{}
+	answered   chan struct{} // closed and replaced after every batch request, see PredictWait
+}
+
+func newPredictionService(backend prog.CallPredictor, stats *predictionStats) *PredictionService {
+	ps := &PredictionService{
+		backend:  backend,
+		stats:    stats,
+		cache:    make(map[hash.Sig][]prog.Prediction),
+		pending:  make(map[hash.Sig][]string),
+		kick:     make(chan struct{}, 1),
+		answered: make(chan struct{}),
+	}
+	return ps
+}
+
+func (ps *PredictionService) Predict(calls []string) ([]prog.Prediction, error) {
+	key := hash.Hash([]byte(strings.Join(calls, "\n")))
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if res, ok := ps.cache[key]; ok {
+		return res, nil
+	}
+	if _, ok := ps.pending[key]; !ok && len(ps.pending) < predictMaxPending {
+		ps.pending[key] = append([]string{}, calls...)
+		if len(ps.pending) >= predictBatchSize {
+			select {
+			case ps.kick <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return nil, prog.ErrPredictionPending
+}
+
+// PredictWait implements prog.BlockingCallPredictor: on a cache miss the batch is sent right away
+// and the answer is waited for, but not longer than predictWaitTimeout and only while the server is up.
+func (ps *PredictionService) PredictWait(calls []string) ([]prog.Prediction, error) {
+	deadline := time.NewTimer(predictWaitTimeout)
+	defer deadline.Stop()
+	for {
+		ps.mu.Lock()
+		answered := ps.answered
+		ps.mu.Unlock()
+		res, err := ps.Predict(calls)
+		if !errors.Is(err, prog.ErrPredictionPending) || !ps.Available() {
+			return res, err
+		}
+		select {
+		case ps.kick <- struct{}{}:
+		default:
+		}
+		select {
+		case <-answered:
+		case <-deadline.C:
+			return nil, err
+		}
+	}
+}
+
+// Available implements prog.PredictorHealth.
+func (ps *PredictionService) Available() bool {
+	return ps == nil || atomic.LoadUint32(&ps.down) == 0
+}
+
+func (ps *PredictionService) loop() {
+	ticker := time.NewTicker(predictBatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ps.kick:
+		}
+		ps.flush()
+	}
+}
+
+// reportOutcome queues feedback about a prediction for sending to the backend.
+func (ps *PredictionService) reportOutcome(outcome prog.PredictionOutcome) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if len(ps.outcomes) < predictMaxOutcomes {
+		ps.outcomes = append(ps.outcomes, outcome)
+	}
+}
+
+// flush sends all pending sequences and outcomes to the backend and caches the answers.
+func (ps *PredictionService) flush() {
+	if time.Now().Before(ps.retry) {
+		return
+	}
+	if !ps.Available() {
+		ps.probe()
+		return
+	}
+	ps.mu.Lock()
+	var keys []hash.Sig
+	var seqs [][]string
+	for key, seq := range ps.pending {
+		keys = append(keys, key)
+		seqs = append(seqs, seq)
+	}
+	ps.pending = make(map[hash.Sig][]string)
+	outcomes := ps.outcomes
+	ps.outcomes = nil
+	ps.mu.Unlock()
+	if reporter, ok := ps.backend.(prog.OutcomeReporter); ok && len(outcomes) != 0 {
+		if err := reporter.ReportOutcomes(outcomes); err != nil {
+			log.Logf(1, "failed to report %v SyzLLM outcomes: %v", len(outcomes), err)
+		}
+	}
+	if len(seqs) == 0 {
+		return
+	}
+	defer ps.noteAnswered()
+	res, failed, err := ps.predict(seqs)
+	if err != nil {
+		// Don't cache anything, the sequences will be requested again.
+		log.Logf(1, "SyzLLM batch prediction of %v sequences failed: %v", len(seqs), err)
+		ps.noteFailure(err)
+		return
+	}
+	ps.noteSuccess()
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for i, key := range keys {
+		if _, ok := ps.cache[key]; ok || failed[i] {
+			// Failed sequences are not cached, so that they are requested again.
+			continue
+		}
+		if len(ps.cacheOrder) >= predictCacheSize {
+			delete(ps.cache, ps.cacheOrder[0])
+			ps.cacheOrder = ps.cacheOrder[1:]
+		}
+		// Empty answers are cached as well, so that we don't ask again.
+		ps.cache[key] = res[i]
+		ps.cacheOrder = append(ps.cacheOrder, key)
+	}
+}
+
+// noteAnswered wakes up PredictWait callers after a batch request, successful or not.
+func (ps *PredictionService) noteAnswered() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	close(ps.answered)
+	ps.answered = make(chan struct{})
+}
+
+// predict returns predictions for seqs and marks sequences for which the request failed
+// (rejections are answers, not failures). An error is returned if all requests failed.
+func (ps *PredictionService) predict(seqs [][]string) ([][]prog.Prediction, []bool, error) {
+	failed := make([]bool, len(seqs))
+	if batch, ok := ps.backend.(prog.BatchCallPredictor); ok {
+		start := time.Now()
+		res, err := batch.PredictBatch(seqs)
+		ps.stats.noteLatency(time.Since(start))
+		return res, failed, err
+	}
+	res := make([][]prog.Prediction, len(seqs))
+	nfailed := 0
+	var lastErr error
+	for i, seq := range seqs {
+		start := time.Now()
+		var err error
+		res[i], err = ps.backend.Predict(seq)
+		ps.stats.noteLatency(time.Since(start))
+		if err != nil {
+			log.Logf(2, "SyzLLM prediction failed: %v", err)
+			if !errors.Is(err, prog.ErrPredictionRejected) {
+				failed[i] = true
+				nfailed++
+				lastErr = err
+			}
+		}
+	}
+	if nfailed == len(seqs) {
+		return nil, nil, lastErr
+	}
+	return res, failed, nil
+}
+
+// probe checks if the server is back. Rejection of the request means that the server works.
+func (ps *PredictionService) probe() {
+	_, err := ps.backend.Predict([]string{prog.MASK})
+	if err != nil && !errors.Is(err, prog.ErrPredictionRejected) {
+		log.Logf(1, "SyzLLM server probe failed: %v", err)
+		ps.noteFailure(err)
+		return
+	}
+	ps.noteSuccess()
+}
+
+func (ps *PredictionService) noteFailure(err error) {
+	ps.failures++
+	ps.backoff *= 2
+	if ps.backoff < predictMinBackoff {
+		ps.backoff = predictMinBackoff
+	}
+	if ps.backoff > predictMaxBackoff {
+		ps.backoff = predictMaxBackoff
+	}
+	ps.retry = time.Now().Add(ps.backoff)
+	if ps.failures >= predictMaxFailures && atomic.CompareAndSwapUint32(&ps.down, 0, 1) {
+		log.Logf(0, "SyzLLM server is down, mutating without it: %v", err)
+		ps.stats.noteServerDown()
+		// The queued sequences will be requested again when the server is back.
+		ps.mu.Lock()
+		ps.pending = make(map[hash.Sig][]string)
+		ps.mu.Unlock()
+	}
+}
+
+func (ps *PredictionService) noteSuccess() {
+	ps.failures = 0
+	ps.backoff = 0
+	ps.retry = time.Time{}
+	if atomic.CompareAndSwapUint32(&ps.down, 1, 0) {
+		log.Logf(0, "SyzLLM server is up")
+	}
+}
+
+// predictionStats counts what happens to predictions in mutations.
+// They are reported to the manager with the rpctype.SyzLLM*Stat prefixes.
+type predictionStats struct {
+	results [prog.PredictionResultCount]uint64
+	latency []uint64 // histogram with rpctype.SyzLLMLatencyBuckets
+	downs   uint64   // times the server was considered down
+
+	mu    sync.Mutex
+	calls map[string]uint64 // inserted syscall -> count
+}
+
+func newPredictionStats() *predictionStats {
+	return &predictionStats{
+		latency: make([]uint64, len(rpctype.SyzLLMLatencyBuckets)+1),
+		calls:   make(map[string]uint64),
+	}
+}
+
+func (st *predictionStats) noteLatency(d time.Duration) {
+	bucket := sort.Search(len(rpctype.SyzLLMLatencyBuckets), func(i int) bool {
+		return d <= rpctype.SyzLLMLatencyBuckets[i]
+	})
+	atomic.AddUint64(&st.latency[bucket], 1)
+}
+
+func (st *predictionStats) noteServerDown() {
+	atomic.AddUint64(&st.downs, 1)
+}
+
+func (st *predictionStats) noteResult(res prog.PredictionResult, call string) {
+	atomic.AddUint64(&st.results[res], 1)
+	if call != "" {
+		st.mu.Lock()
+		st.calls[call]++
+		st.mu.Unlock()
+	}
+}
+
+// collect moves all counters into stats.
+func (st *predictionStats) collect(stats map[string]uint64) {
+	for res := prog.PredictionResult(0); res < prog.PredictionResultCount; res++ {
+		stats[rpctype.SyzLLMResultStat+res.String()] = atomic.SwapUint64(&st.results[res], 0)
+	}
+	for i := range st.latency {
+		if v := atomic.SwapUint64(&st.latency[i], 0); v != 0 {
+			stats[rpctype.SyzLLMLatencyStat+rpctype.SyzLLMLatencyBucket(i)] = v
+		}
+	}
+	if v := atomic.SwapUint64(&st.downs, 0); v != 0 {
+		stats[rpctype.SyzLLMServerDownStat] = v
+	}
+	st.mu.Lock()
+	calls := st.calls
+	st.calls = make(map[string]uint64)
+	st.mu.Unlock()
+	for call, v := range calls {
+		stats[rpctype.SyzLLMCallStat+call] = v
+	}
+}
+
+// observedPredictor is the predictor installed into the ChoiceTable,
+// it records results of predictions and, if timed, latency of the wrapped predictor.
+type observedPredictor struct {
+	prog.CallPredictor
+	stats *predictionStats
+	timed bool
+}
+
+func (op *observedPredictor) Predict(calls []string) ([]prog.Prediction, error) {
+	if !op.timed {
+		return op.CallPredictor.Predict(calls)
+	}
+	start := time.Now()
+	res, err := op.CallPredictor.Predict(calls)
+	op.stats.noteLatency(time.Since(start))
+	return res, err
+}
+
+// PredictWait implements prog.BlockingCallPredictor, predictors that can't wait are just asked.
+func (op *observedPredictor) PredictWait(calls []string) ([]prog.Prediction, error) {
+	if blocking, ok := op.CallPredictor.(prog.BlockingCallPredictor); ok {
+		return blocking.PredictWait(calls)
+	}
+	return op.Predict(calls)
+}
+
+// Available implements prog.PredictorHealth for predictors that may be unavailable.
+func (op *observedPredictor) Available() bool {
+	health, ok := op.CallPredictor.(prog.PredictorHealth)
+	return !ok || health.Available()
+}
+
+func (op *observedPredictor) ObservePrediction(res prog.PredictionResult, call string) {
+	op.stats.noteResult(res, call)
+}
+
+// predictionLog additionally writes every insertion of a predicted call to a JSONL file,
+// records can be replayed with syz-mutate -replay.
+type predictionLog struct {
+	*observedPredictor
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newPredictionLog(pred *observedPredictor, file string) (*predictionLog, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SyzLLM record file: %w", err)
+	}
+	log.Logf(0, "recording SyzLLM predictions to %v", file)
+	// The file is never closed, records are written unbuffered to survive crashes.
+	return &predictionLog{
+		observedPredictor: pred,
+		enc:               json.NewEncoder(f),
+	}, nil
+}
+
+func (pl *predictionLog) RecordPrediction(rec *prog.PredictionRecord) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if err := pl.enc.Encode(rec); err != nil {
+		log.Logf(0, "failed to record SyzLLM prediction: %v", err)
+	}
+}
+
+// callPolicy implements prog.PredictionCallPolicy for the syzllm.disabled_calls config parameter.
+// The choice table is never modified: calls accepted by the manager under the "propose"
+// policy are enabled by building a new table, see Fuzzer.acceptSyzLLMCalls.
+type callPolicy struct {
+	policy    string
+	supported map[*prog.Syscall]bool // enabled in the manager config and supported by the machine
+
+	mu        sync.Mutex
+	proposals map[int]uint64 // calls rejected under the "propose" policy since the last poll
+}
+
+func newCallPolicy(target *prog.Target, policy string, supported []int) *callPolicy {
+	cp := &callPolicy{
+		policy:    policy,
+		supported: make(map[*prog.Syscall]bool),
+		proposals: make(map[int]uint64),
+	}
+	for _, id := range supported {
+		cp.supported[target.Syscalls[id]] = true
+	}
+	return cp
+}
+
+func (cp *callPolicy) AllowPredictedCall(meta *prog.Syscall) bool {
+	if cp.policy == "propose" {
+		cp.mu.Lock()
+		cp.proposals[meta.ID]++
+		cp.mu.Unlock()
+	}
+	return cp.allowed(meta)
+}
+
+// allowed says if programs may contain the call even though it's not enabled in the choice table.
+func (cp *callPolicy) allowed(meta *prog.Syscall) bool {
+	return cp != nil && cp.policy == "allow_if_supported" && cp.supported[meta]
+}
+
+// grabProposals returns calls proposed since the last call to grabProposals.
+func (cp *callPolicy) grabProposals() map[int]uint64 {
+	if cp == nil || cp.policy != "propose" {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if len(cp.proposals) == 0 {
+		return nil
+	}
+	res := cp.proposals
+	cp.proposals = make(map[int]uint64)
+	return res
+}
@@ -0,0 +1,317 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestPredictionService(t *testing.T) {
+	backend := &prog.FakePredictor{
+		Predictions: []prog.Prediction{{Call: "mutate0", Score: 1}},
+	}
+	ps := newPredictionService(backend, newPredictionStats())
+	seq1 := []string{"mutate1()", prog.MASK}
+	seq2 := []string{prog.MASK, "mutate1()"}
+
+	for i := 0; i < 2; i++ {
+		// Misses must not block and must be queued only once.
+		for _, seq := range [][]string{seq1, seq2} {
+			if _, err := ps.Predict(seq); !errors.Is(err, prog.ErrPredictionPending) {
+				t.Fatalf("got %v, want ErrPredictionPending", err)
+			}
+		}
+	}
+	if len(backend.Requests()) != 0 {
+		t.Fatalf("backend is called synchronously")
+	}
+	ps.flush()
+	if got := len(backend.Requests()); got != 2 {
+		t.Fatalf("backend got %v requests, want 2", got)
+	}
+	for _, seq := range [][]string{seq1, seq2} {
+		res, err := ps.Predict(seq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Call != "mutate0" {
+			t.Fatalf("bad cached predictions: %+v", res)
+		}
+	}
+	ps.flush()
+	if got := len(backend.Requests()); got != 2 {
+		t.Fatalf("cached sequences are requested again: %v requests", got)
+	}
+}
+
+func TestPredictionServiceGenerate(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	backend := &prog.FakePredictor{
+		Predictions: []prog.Prediction{{Call: "mutate8$SyzLLM(0x1)", Score: 1}},
+	}
+	ps := newPredictionService(backend, newPredictionStats())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-ps.kick:
+				ps.flush()
+			case <-done:
+				return
+			}
+		}
+	}()
+	ct := target.DefaultChoiceTable()
+	ct.SetPredictor(&observedPredictor{CallPredictor: ps, stats: newPredictionStats()}, 100)
+	// Every prefix is a cache miss, generation must wait for the answers instead of stopping.
+	p, err := target.GenerateSyzLLM(rand.NewSource(0), 4, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(p.Calls); got != 4 {
+		t.Fatalf("generated %v calls, want 4:\n%s", got, p.Serialize())
+	}
+	if got := len(backend.Requests()); got != 4 {
+		t.Fatalf("backend got %v requests, want 4", got)
+	}
+}
+
+func TestPredictionServiceError(t *testing.T) {
+	backend := &prog.FakePredictor{Err: errors.New("server is down")}
+	stats := newPredictionStats()
+	ps := newPredictionService(backend, stats)
+	seq := []string{"mutate1()", prog.MASK}
+	for i := 1; i <= predictMaxFailures; i++ {
+		ps.Predict(seq)
+		ps.flush()
+		if got := len(backend.Requests()); got != i {
+			t.Fatalf("backend got %v requests, want %v", got, i)
+		}
+		// Failed sequences are not cached and are requested again after the backoff.
+		if _, err := ps.Predict(seq); !errors.Is(err, prog.ErrPredictionPending) {
+			t.Fatalf("got %v, want ErrPredictionPending", err)
+		}
+		ps.flush()
+		if got := len(backend.Requests()); got != i {
+			t.Fatalf("backend is requested during backoff")
+		}
+		if ps.Available() != (i < predictMaxFailures) {
+			t.Fatalf("available = %v after %v failures", ps.Available(), i)
+		}
+		ps.retry = time.Time{}
+	}
+	if ps.backoff != 4*predictMinBackoff {
+		t.Fatalf("backoff is %v, want %v", ps.backoff, 4*predictMinBackoff)
+	}
+	// The server is probed, but nothing else is sent while it's down.
+	ps.flush()
+	requests := backend.Requests()
+	if len(requests) != predictMaxFailures+1 || len(requests[predictMaxFailures]) != 1 {
+		t.Fatalf("bad probe: %q", requests)
+	}
+	ps.retry = time.Time{}
+	backend.Err = fmt.Errorf("%w: no predictions", prog.ErrPredictionRejected)
+	ps.flush()
+	if !ps.Available() || ps.backoff != 0 {
+		t.Fatalf("server is not up after a successful probe")
+	}
+	all := make(map[string]uint64)
+	stats.collect(all)
+	if all[rpctype.SyzLLMServerDownStat] != 1 {
+		t.Fatalf("server down is not counted: %v", all)
+	}
+}
+
+// flakyPredictor fails requests for sequences starting with fail.
+type flakyPredictor struct {
+	prog.FakePredictor
+	fail string
+}
+
+func (pred *flakyPredictor) Predict(calls []string) ([]prog.Prediction, error) {
+	res, err := pred.FakePredictor.Predict(calls)
+	if calls[0] == pred.fail {
+		return nil, errors.New("connection reset")
+	}
+	return res, err
+}
+
+func TestPredictionServicePartialError(t *testing.T) {
+	backend := &flakyPredictor{
+		FakePredictor: prog.FakePredictor{Predictions: []prog.Prediction{{Call: "mutate0", Score: 1}}},
+		fail:          "mutate2()",
+	}
+	ps := newPredictionService(backend, newPredictionStats())
+	seq1 := []string{"mutate1()", prog.MASK}
+	seq2 := []string{"mutate2()", prog.MASK}
+	for _, seq := range [][]string{seq1, seq2} {
+		ps.Predict(seq)
+	}
+	ps.flush()
+	if res, err := ps.Predict(seq1); err != nil || len(res) != 1 {
+		t.Fatalf("got %+v, %v for the answered sequence", res, err)
+	}
+	// The failed sequence is not cached as rejected and is requested again once the server recovers.
+	if _, err := ps.Predict(seq2); !errors.Is(err, prog.ErrPredictionPending) {
+		t.Fatalf("got %v for the failed sequence, want ErrPredictionPending", err)
+	}
+	backend.fail = ""
+	ps.flush()
+	if got := len(backend.Requests()); got != 3 {
+		t.Fatalf("backend got %v requests, want 3", got)
+	}
+	if res, err := ps.Predict(seq2); err != nil || len(res) != 1 {
+		t.Fatalf("got %+v, %v after the server recovered", res, err)
+	}
+}
+
+func TestPredictionServiceOutcomes(t *testing.T) {
+	backend := &prog.FakePredictor{}
+	ps := newPredictionService(backend, newPredictionStats())
+	outcome := prog.PredictionOutcome{ID: "1", Call: "mutate0", Position: 2, NewSignal: 10}
+	ps.reportOutcome(outcome)
+	ps.flush()
+	ps.flush()
+	got := backend.Outcomes()
+	if len(got) != 1 || got[0] != outcome {
+		t.Fatalf("got outcomes %+v, want %+v", got, outcome)
+	}
+}
+
+func TestPredictionStats(t *testing.T) {
+	st := newPredictionStats()
+	st.noteResult(prog.PredictionInserted, "socket")
+	st.noteResult(prog.PredictionInserted, "socket")
+	st.noteResult(prog.PredictionServerError, "")
+	st.noteLatency(time.Millisecond)
+	st.noteLatency(time.Minute)
+
+	stats := make(map[string]uint64)
+	st.collect(stats)
+	want := map[string]uint64{
+		rpctype.SyzLLMResultStat + "inserted":     2,
+		rpctype.SyzLLMResultStat + "pending":      0,
+		rpctype.SyzLLMResultStat + "server error": 1,
+		rpctype.SyzLLMResultStat + "rejected":     0,
+		rpctype.SyzLLMResultStat + "parse error":  0,
+		rpctype.SyzLLMResultStat + "unchanged":    0,
+		rpctype.SyzLLMResultStat + "disabled":     0,
+		rpctype.SyzLLMResultStat + "arg replaced": 0,
+		rpctype.SyzLLMLatencyStat + "<=10ms":      1,
+		rpctype.SyzLLMLatencyStat + ">5s":         1,
+		rpctype.SyzLLMCallStat + "socket":         2,
+	}
+	if diff := cmp.Diff(want, stats); diff != "" {
+		t.Fatal(diff)
+	}
+	// Counters are reset after collection.
+	stats = make(map[string]uint64)
+	st.collect(stats)
+	if len(stats) != int(prog.PredictionResultCount) || stats[rpctype.SyzLLMResultStat+"inserted"] != 0 {
+		t.Fatalf("stats are not reset: %v", stats)
+	}
+}
+
+func TestPredictionLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "syzllm.jsonl")
+	pl, err := newPredictionLog(&observedPredictor{stats: newPredictionStats()}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := []*prog.PredictionRecord{
+		{Prog: "getpid()\n", Pos: 1, Seed: 1, Result: "rejected", Err: "no predictions"},
+		{Prog: "getpid()\n", Pos: 0, Seed: 2, Result: "inserted", Mutated: "getuid()\ngetpid()\n",
+			Request:     []string{prog.MASK, "getpid()"},
+			Predictions: []prog.Prediction{{Call: "getuid()", Score: 1}}},
+	}
+	for _, rec := range recs {
+		pl.RecordPrediction(rec)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []*prog.PredictionRecord
+	for s := bufio.NewScanner(f); s.Scan(); {
+		rec := new(prog.PredictionRecord)
+		if err := json.Unmarshal(s.Bytes(), rec); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+	if diff := cmp.Diff(recs, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestCorpusNgrams(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	parse := func(text string) *prog.Prog {
+		p, err := target.Deserialize([]byte(text), prog.Strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	cn := &corpusNgrams{pred: prog.NewNgramPredictor(nil, ngramSize)}
+	seq := []string{"mutate0()", prog.MASK}
+	if _, err := cn.Predict(seq); !errors.Is(err, prog.ErrPredictionRejected) {
+		t.Fatalf("got %v for an empty corpus", err)
+	}
+	cn.rebuild([]*prog.Prog{parse("mutate0()\nmutate1()\n")})
+	res, err := cn.Predict(seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Call != "mutate1" {
+		t.Fatalf("bad predictions after rebuild: %+v", res)
+	}
+}
+
+func TestCallPolicy(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	mutate0, mutate1 := target.SyscallMap["mutate0"], target.SyscallMap["mutate1"]
+	supported := []int{mutate0.ID}
+
+	allow := newCallPolicy(target, "allow_if_supported", supported)
+	if !allow.AllowPredictedCall(mutate0) || allow.AllowPredictedCall(mutate1) {
+		t.Fatalf("allow_if_supported allows wrong calls")
+	}
+	if allow.grabProposals() != nil {
+		t.Fatalf("allow_if_supported proposes calls")
+	}
+
+	propose := newCallPolicy(target, "propose", supported)
+	if propose.AllowPredictedCall(mutate0) || propose.AllowPredictedCall(mutate1) ||
+		propose.AllowPredictedCall(mutate1) {
+		t.Fatalf("propose allows calls")
+	}
+	want := map[int]uint64{mutate0.ID: 1, mutate1.ID: 2}
+	if diff := cmp.Diff(want, propose.grabProposals()); diff != "" {
+		t.Fatal(diff)
+	}
+	if propose.grabProposals() != nil {
+		t.Fatalf("proposals are not reset")
+	}
+
+	var none *callPolicy
+	if none.allowed(mutate0) || none.grabProposals() != nil {
+		t.Fatalf("nil policy allows calls")
+	}
+}
@@ -73,7 +73,7 @@ func (proc *Proc) loop() {
 			case *WorkTriage:
 				proc.triageInput(item)
 			case *WorkCandidate:
-				proc.execute(proc.execOpts, item.p, item.flags, StatCandidate)
+				proc.execute(proc.execOpts, item.p, item.flags, StatCandidate, item.prov)
 			case *WorkSmash:
 				proc.smashInput(item)
 			default:
@@ -82,23 +82,44 @@ func (proc *Proc) loop() {
 			continue
 		}
 
-		ct := proc.fuzzer.choiceTable
+		ct := proc.fuzzer.getChoiceTable()
 		fuzzerSnapshot := proc.fuzzer.snapshot()
 		if len(fuzzerSnapshot.corpus) == 0 || i%generatePeriod == 0 {
 			// Generate a new prog.
-			p := proc.fuzzer.target.Generate(proc.rnd, prog.RecommendedCalls, ct)
+			p, stat := proc.generate(ct)
 			log.Logf(1, "#%v: generated", proc.pid)
-			proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatGenerate)
+			prov := rpctype.Provenance{Origin: rpctype.OriginGenerate}
+			if stat == StatGenerateSyzLLM {
+				prov.Origin = rpctype.OriginGenerateSyzLLM
+			}
+			proc.executeAndCollide(proc.execOpts, p, ProgNormal, stat, prov)
 		} else {
 			// Mutate an existing prog.
-			p := fuzzerSnapshot.chooseProgram(proc.rnd).Clone()
+			idx := fuzzerSnapshot.chooseSeed(proc.rnd)
+			p := fuzzerSnapshot.corpus[idx].Clone()
 			p.Mutate(proc.rnd, prog.RecommendedCalls, ct, proc.fuzzer.noMutate, fuzzerSnapshot.corpus)
 			log.Logf(1, "#%v: mutated", proc.pid)
-			proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatFuzz)
+			seed := fuzzerSnapshot.corpusSeeds[idx]
+			prov := rpctype.Provenance{Origin: rpctype.OriginMutate, Parent: seed.sig.String()}
+			newSignal := proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatFuzz, prov)
+			seed.noteMutant(newSignal)
 		}
 	}
 }
 
+// generate generates a new program, a share of programs is produced by the SyzLLM predictor.
+func (proc *Proc) generate(ct *prog.ChoiceTable) (*prog.Prog, Stat) {
+	fuzzer := proc.fuzzer
+	if fuzzer.predictor != nil && fuzzer.genProb > 0 && proc.rnd.Intn(100) < fuzzer.genProb {
+		p, err := fuzzer.target.GenerateSyzLLM(proc.rnd, prog.RecommendedCalls, ct)
+		if p != nil {
+			return p, StatGenerateSyzLLM
+		}
+		log.Logf(2, "#%v: SyzLLM generation failed: %v", proc.pid, err)
+	}
+	return fuzzer.target.Generate(proc.rnd, prog.RecommendedCalls, ct), StatGenerate
+}
+
 func (proc *Proc) triageInput(item *WorkTriage) {
 	log.Logf(1, "#%v: triaging type=%x", proc.pid, item.flags)
 
@@ -146,10 +167,12 @@ func (proc *Proc) triageInput(item *WorkTriage) {
 		inputCover.Merge(thisCover)
 	}
 	if item.flags&ProgMinimized == 0 {
+		// Inputs found while minimizing have the same lineage as the minimized program.
+		minimizeProv := rpctype.Provenance{Origin: rpctype.OriginMinimize, Parent: item.prov.Parent}
 		item.p, item.call = prog.Minimize(item.p, item.call, false,
 			func(p1 *prog.Prog, call1 int) bool {
 				for i := 0; i < minimizeAttempts; i++ {
-					info := proc.execute(proc.execOpts, p1, ProgNormal, StatMinimize)
+					info := proc.execute(proc.execOpts, p1, ProgNormal, StatMinimize, minimizeProv)
 					if !reexecutionSuccess(info, &item.info, call1) {
 						// The call was not executed or failed.
 						continue
@@ -167,19 +190,22 @@ func (proc *Proc) triageInput(item *WorkTriage) {
 	sig := hash.Hash(data)
 
 	log.Logf(2, "added new input for %v to corpus:\n%s", logCallName, data)
-	proc.fuzzer.sendInputToManager(rpctype.Input{
+	saved := proc.fuzzer.sendInputToManager(rpctype.Input{
 		Call:     callName,
 		CallID:   item.call,
 		Prog:     data,
 		Signal:   inputSignal.Serialize(),
 		Cover:    inputCover.Serialize(),
 		RawCover: rawCover,
+		SyzLLM:   item.predicted,
+
+		Provenance: item.prov,
 	})
 
-	proc.fuzzer.addInputToCorpus(item.p, inputSignal, sig)
+	proc.fuzzer.addInputToCorpus(item.p, inputSignal, sig, saved.Seed, saved.Distance)
 
 	if item.flags&ProgSmashed == 0 {
-		proc.fuzzer.workQueue.enqueue(&WorkSmash{item.p, item.call})
+		proc.fuzzer.workQueue.enqueue(&WorkSmash{item.p, item.call, sig})
 	}
 }
 
@@ -211,14 +237,15 @@ func (proc *Proc) smashInput(item *WorkSmash) {
 		proc.failCall(item.p, item.call)
 	}
 	if proc.fuzzer.comparisonTracingEnabled && item.call != -1 {
-		proc.executeHintSeed(item.p, item.call)
+		proc.executeHintSeed(item.p, item.call, item.sig)
 	}
 	fuzzerSnapshot := proc.fuzzer.snapshot()
+	prov := rpctype.Provenance{Origin: rpctype.OriginSmash, Parent: item.sig.String()}
 	for i := 0; i < 100; i++ {
 		p := item.p.Clone()
-		p.Mutate(proc.rnd, prog.RecommendedCalls, proc.fuzzer.choiceTable, proc.fuzzer.noMutate, fuzzerSnapshot.corpus)
+		p.Mutate(proc.rnd, prog.RecommendedCalls, proc.fuzzer.getChoiceTable(), proc.fuzzer.noMutate, fuzzerSnapshot.corpus)
 		log.Logf(1, "#%v: smash mutated", proc.pid)
-		proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatSmash)
+		proc.executeAndCollide(proc.execOpts, p, ProgNormal, StatSmash, prov)
 	}
 }
 
@@ -234,10 +261,11 @@ func (proc *Proc) failCall(p *prog.Prog, call int) {
 	}
 }
 
-func (proc *Proc) executeHintSeed(p *prog.Prog, call int) {
+func (proc *Proc) executeHintSeed(p *prog.Prog, call int, sig hash.Sig) {
 	log.Logf(1, "#%v: collecting comparisons", proc.pid)
+	prov := rpctype.Provenance{Origin: rpctype.OriginHint, Parent: sig.String()}
 	// First execute the original program to dump comparisons from KCOV.
-	info := proc.execute(proc.execOptsComps, p, ProgNormal, StatSeed)
+	info := proc.execute(proc.execOptsComps, p, ProgNormal, StatSeed, prov)
 	if info == nil {
 		return
 	}
@@ -247,50 +275,72 @@ func (proc *Proc) executeHintSeed(p *prog.Prog, call int) {
 	// Execute each of such mutants to check if it gives new coverage.
 	p.MutateWithHints(call, info.Calls[call].Comps, func(p *prog.Prog) {
 		log.Logf(1, "#%v: executing comparison hint", proc.pid)
-		proc.execute(proc.execOpts, p, ProgNormal, StatHint)
+		proc.execute(proc.execOpts, p, ProgNormal, StatHint, prov)
 	})
 }
 
-func (proc *Proc) execute(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat) *ipc.ProgInfo {
+// execute executes the program and triages new signal, prov is how the program was produced.
+func (proc *Proc) execute(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat,
+	prov rpctype.Provenance) *ipc.ProgInfo {
+	info, _ := proc.executeNewSignal(execOpts, p, flags, stat, prov)
+	return info
+}
+
+// executeNewSignal is execute that also says if the program gave new signal.
+func (proc *Proc) executeNewSignal(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes,
+	stat Stat, prov rpctype.Provenance) (*ipc.ProgInfo, bool) {
 	info := proc.executeRaw(execOpts, p, stat)
 	if info == nil {
-		return nil
+		return nil, false
 	}
 	calls, extra := proc.fuzzer.checkNewSignal(p, info)
 	for _, callIndex := range calls {
-		proc.enqueueCallTriage(p, flags, callIndex, info.Calls[callIndex])
+		proc.enqueueCallTriage(p, flags, callIndex, info.Calls[callIndex], prov)
 	}
 	if extra {
-		proc.enqueueCallTriage(p, flags, -1, info.Extra)
+		proc.enqueueCallTriage(p, flags, -1, info.Extra, prov)
 	}
-	return info
+	return info, len(calls) != 0 || extra
 }
 
-func (proc *Proc) enqueueCallTriage(p *prog.Prog, flags ProgTypes, callIndex int, info ipc.CallInfo) {
+func (proc *Proc) enqueueCallTriage(p *prog.Prog, flags ProgTypes, callIndex int, info ipc.CallInfo,
+	prov rpctype.Provenance) {
 	// info.Signal points to the output shmem region, detach it before queueing.
 	info.Signal = append([]uint32{}, info.Signal...)
 	// None of the caller use Cover, so just nil it instead of detaching.
 	// Note: triage input uses executeRaw to get coverage.
 	info.Cover = nil
+	// Mutation operators are not cloned with the program.
+	if len(p.MutationOps) != 0 {
+		prov.Ops = make([]string, len(p.MutationOps))
+		for i, op := range p.MutationOps {
+			prov.Ops[i] = op.String()
+		}
+	}
 	proc.fuzzer.workQueue.enqueue(&WorkTriage{
-		p:     p.Clone(),
-		call:  callIndex,
-		info:  info,
-		flags: flags,
+		p:         p.Clone(),
+		call:      callIndex,
+		info:      info,
+		flags:     flags,
+		predicted: p.Predicted != nil,
+		prov:      prov,
 	})
 }
 
-func (proc *Proc) executeAndCollide(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat) {
-	proc.execute(execOpts, p, flags, stat)
+// executeAndCollide says if the program gave new signal.
+func (proc *Proc) executeAndCollide(execOpts *ipc.ExecOpts, p *prog.Prog, flags ProgTypes, stat Stat,
+	prov rpctype.Provenance) bool {
+	_, newSignal := proc.executeNewSignal(execOpts, p, flags, stat, prov)
 
 	if proc.execOptsCollide.Flags&ipc.FlagThreaded == 0 {
 		// We cannot collide syscalls without being in the threaded mode.
-		return
+		return newSignal
 	}
 	const collideIterations = 2
 	for i := 0; i < collideIterations; i++ {
 		proc.executeRaw(proc.execOptsCollide, proc.randomCollide(p), StatCollide)
 	}
+	return newSignal
 }
 
 func (proc *Proc) randomCollide(origP *prog.Prog) *prog.Prog {
@@ -353,6 +403,11 @@ func (proc *Proc) logProgram(opts *ipc.ExecOpts, p *prog.Prog) {
 	}
 
 	data := p.Serialize()
+	// Lets the manager attribute crashes to SyzLLM predictions.
+	predicted := ""
+	if p.Predicted != nil {
+		predicted = p.Predicted.String() + "\n"
+	}
 
 	// The following output helps to understand what program crashed kernel.
 	// It must not be intermixed.
@@ -360,16 +415,16 @@ func (proc *Proc) logProgram(opts *ipc.ExecOpts, p *prog.Prog) {
 	case OutputStdout:
 		now := time.Now()
 		proc.fuzzer.logMu.Lock()
-		fmt.Printf("%02v:%02v:%02v executing program %v:\n%s\n",
-			now.Hour(), now.Minute(), now.Second(),
+		fmt.Printf("%s%02v:%02v:%02v executing program %v:\n%s\n",
+			predicted, now.Hour(), now.Minute(), now.Second(),
 			proc.pid, data)
 		proc.fuzzer.logMu.Unlock()
 	case OutputDmesg:
 		fd, err := syscall.Open("/dev/kmsg", syscall.O_WRONLY, 0)
 		if err == nil {
 			buf := new(bytes.Buffer)
-			fmt.Fprintf(buf, "syzkaller: executing program %v:\n%s\n",
-				proc.pid, data)
+			fmt.Fprintf(buf, "%ssyzkaller: executing program %v:\n%s\n",
+				predicted, proc.pid, data)
 			syscall.Write(fd, buf.Bytes())
 			syscall.Close(fd)
 		}
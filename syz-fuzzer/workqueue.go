@@ -6,7 +6,9 @@ package main
 import (
 	"sync"
 
+	"github.com/google/syzkaller/pkg/hash"
 	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/prog"
 )
 
@@ -39,10 +41,12 @@ const (
 // During triage we understand if these programs in fact give new coverage,
 // and if yes, minimize them and add to corpus.
 type WorkTriage struct {
-	p     *prog.Prog
-	call  int
-	info  ipc.CallInfo
-	flags ProgTypes
+	p         *prog.Prog
+	call      int
+	info      ipc.CallInfo
+	flags     ProgTypes
+	predicted bool // the program was mutated with a SyzLLM-predicted call
+	prov      rpctype.Provenance
 }
 
 // WorkCandidate are programs from hub.
@@ -51,6 +55,7 @@ type WorkTriage struct {
 type WorkCandidate struct {
 	p     *prog.Prog
 	flags ProgTypes
+	prov  rpctype.Provenance
 }
 
 // WorkSmash are programs just added to corpus.
@@ -59,6 +64,7 @@ type WorkCandidate struct {
 type WorkSmash struct {
 	p    *prog.Prog
 	call int
+	sig  hash.Sig
 }
 
 func newWorkQueue(procs int, needCandidates chan struct{}) *WorkQueue {
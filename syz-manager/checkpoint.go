@@ -0,0 +1,308 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/pkg/signal"
+)
+
+// Without a checkpoint, a restarted manager sends the whole corpus to fuzzers as candidates
+// and starts with empty max signal, re-triaging big corpora takes hours of VM time.
+// So the manager saves signal and coverage of corpus inputs, max signal and pending hub candidates
+// to signal.db. On restart, corpus inputs whose signal was recorded for the same kernel build
+// are added to the corpus and sent to fuzzers without triage (unless -retriage is given).
+// Other pending candidates are not saved, they are recreated from corpus.db and seeds on restart.
+const (
+	signalDBVersion        = 1
+	signalCheckpointPeriod = 10 * time.Minute
+
+	signalKeyBuild      = "build"
+	signalKeyMax        = "max signal"
+	signalKeyCandidates = "candidates"
+	signalKeyInput      = "input " // followed by the input hash
+)
+
+// inputSignal is the checkpoint of a corpus input.
+type inputSignal struct {
+	Call   string
+	Signal signal.Serial
+	Cover  []uint32
+	SyzLLM bool
+}
+
+// resumedState is the state restored from the checkpoint on machine check, see RPCServer.resume.
+type resumedState struct {
+	inputs       []rpctype.Input
+	corpusSignal signal.Signal
+	corpusCover  []uint32
+	maxSignal    signal.Signal
+}
+
+// openSignalDB opens signal.db and drops the recorded signal if it's not valid for the kernel anymore.
+func (mgr *Manager) openSignalDB(retriage bool) {
+	signalDB, err := db.Open(filepath.Join(mgr.cfg.Workdir, "signal.db"), true)
+	if err != nil {
+		if signalDB == nil {
+			log.Fatalf("failed to open signal database: %v", err)
+		}
+		log.Errorf("read %v signal records and got error: %v", len(signalDB.Records), err)
+	}
+	mgr.signalDB = signalDB
+	build := mgr.kernelBuildID()
+	saved := string(signalDB.Records[signalKeyBuild].Val)
+	switch {
+	case len(signalDB.Records) == 0:
+	case retriage:
+		log.Logf(0, "re-triaging the whole corpus as requested")
+	case build == "":
+		log.Logf(0, "unknown kernel build, re-triaging the whole corpus")
+	case build != saved || signalDB.Version != signalDBVersion:
+		log.Logf(0, "kernel build has changed (%q -> %q), re-triaging the whole corpus", saved, build)
+	default:
+		mgr.resumeSignal = true
+		return
+	}
+	// Pending candidates don't depend on the kernel.
+	for key := range signalDB.Records {
+		if key != signalKeyCandidates {
+			signalDB.Delete(key)
+		}
+	}
+	signalDB.Save(signalKeyBuild, []byte(build), 0)
+	if err := signalDB.BumpVersion(signalDBVersion); err != nil {
+		log.Errorf("failed to save signal database: %v", err)
+	}
+}
+
+// resumeInput adds the corpus input to the corpus without triage if its signal is recorded.
+func (mgr *Manager) resumeInput(sig string, data []byte, res *resumedState) bool {
+	rec, ok := mgr.signalDB.Records[signalKeyInput+sig]
+	if !ok {
+		return false
+	}
+	var inp inputSignal
+	if err := json.Unmarshal(rec.Val, &inp); err != nil || len(inp.Signal.Elems) != len(inp.Signal.Prios) {
+		log.Errorf("bad signal record for %v: %v", sig, err)
+		return false
+	}
+	if bad, disabled := checkProgram(mgr.target, mgr.targetEnabledSyscalls, data); bad != nil || disabled {
+		return false
+	}
+	item := CorpusItem{
+		Call:   inp.Call,
+		Prog:   data,
+		Signal: inp.Signal,
+		Cover:  inp.Cover,
+		SyzLLM: inp.SyzLLM,
+		Seed:   mgr.savedSeed(sig),
+
+		Provenance: mgr.savedProvenance(sig),
+		Distance:   mgr.directed.distance(inp.Cover),
+	}
+	mgr.corpus[sig] = item
+	inp1 := item.RPCInput()
+	inp1.Cover = nil // fuzzers don't need coverage of corpus inputs
+	res.inputs = append(res.inputs, inp1)
+	res.corpusSignal.Merge(inp.Signal.Deserialize())
+	res.corpusCover = append(res.corpusCover, inp.Cover...)
+	return true
+}
+
+// resumeMaxSignal returns max signal from the checkpoint.
+func (mgr *Manager) resumeMaxSignal() signal.Signal {
+	rec, ok := mgr.signalDB.Records[signalKeyMax]
+	if !ok {
+		return nil
+	}
+	var max signal.Serial
+	if err := json.Unmarshal(rec.Val, &max); err != nil || len(max.Elems) != len(max.Prios) {
+		log.Errorf("bad max signal record: %v", err)
+		return nil
+	}
+	return max.Deserialize()
+}
+
+// resumeCandidates returns pending hub candidates from the checkpoint.
+func (mgr *Manager) resumeCandidates() []rpctype.Candidate {
+	rec, ok := mgr.signalDB.Records[signalKeyCandidates]
+	if !ok {
+		return nil
+	}
+	var candidates []rpctype.Candidate
+	if err := json.Unmarshal(rec.Val, &candidates); err != nil {
+		log.Errorf("bad candidates record: %v", err)
+		return nil
+	}
+	return candidates
+}
+
+// saveInputSignal records signal of the corpus input, it's written to disk on the next checkpoint.
+func (mgr *Manager) saveInputSignal(sig string, item *CorpusItem) {
+	data, err := json.Marshal(inputSignal{
+		Call:   item.Call,
+		Signal: item.Signal,
+		Cover:  item.Cover,
+		SyzLLM: item.SyzLLM,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("failed to serialize input signal: %v", err))
+	}
+	mgr.signalDB.Save(signalKeyInput+sig, data, 0)
+}
+
+// checkpointSignal saves max signal and pending hub candidates and flushes signal.db.
+// Pending candidates loaded from corpus.db and seeds are recreated by loadCorpus on restart,
+// so they are not saved, otherwise they would be triaged twice after the restart.
+func (mgr *Manager) checkpointSignal() {
+	maxSignal := mgr.serv.maxSignalCheckpoint()
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.phase < phaseLoadedCorpus {
+		// Saved candidates are not loaded yet.
+		return
+	}
+	var candidates []rpctype.Candidate
+	saved := make(map[string]bool)
+	for _, candidate := range mgr.candidates {
+		// Corpus inputs keep their hub origin in provenance.db, but they are in corpus.db.
+		if candidate.Provenance.Origin != rpctype.OriginHub {
+			continue
+		}
+		// Candidates are given the second chance by loadCorpus, save only one copy.
+		sig := hash.String(candidate.Prog)
+		if _, ok := mgr.corpusDB.Records[sig]; !ok && !saved[sig] {
+			saved[sig] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	for key, val := range map[string]interface{}{
+		signalKeyMax:        maxSignal,
+		signalKeyCandidates: candidates,
+	} {
+		data, err := json.Marshal(val)
+		if err != nil {
+			panic(fmt.Sprintf("failed to serialize %v: %v", key, err))
+		}
+		mgr.signalDB.Save(key, data, 0)
+	}
+	if err := mgr.signalDB.Flush(); err != nil {
+		log.Errorf("failed to save signal database: %v", err)
+	}
+}
+
+// kernelBuildID identifies the kernel build, recorded signal is valid only for the same build.
+// It's the GNU build ID of the kernel object file if present, otherwise the kernel image file identity.
+// Empty result means that the build is unknown.
+func (mgr *Manager) kernelBuildID() string {
+	id := ""
+	if vmlinux := filepath.Join(mgr.cfg.KernelObj, mgr.sysTarget.KernelObject); mgr.cfg.KernelObj != "" &&
+		osutil.IsExist(vmlinux) {
+		var err error
+		if id, err = elfBuildID(vmlinux); err != nil {
+			log.Logf(0, "failed to read build ID of %v: %v", vmlinux, err)
+		}
+	}
+	if id == "" && mgr.cfg.Image != "" && mgr.cfg.Image != "9p" {
+		if stat, err := os.Stat(mgr.cfg.Image); err == nil {
+			id = fmt.Sprintf("%v size=%v mtime=%v", mgr.cfg.Image, stat.Size(), stat.ModTime().Unix())
+		}
+	}
+	if id == "" {
+		return ""
+	}
+	// Signal also depends on the coverage mode and the coverage filter.
+	filter, err := json.Marshal(mgr.cfg.CovFilter)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%v cover=%v filter=%v", id, mgr.cfg.Cover, hash.String(filter))
+}
+
+// elfBuildID returns the GNU build ID note of the ELF file, if any.
+func elfBuildID(file string) (string, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	for _, sec := range f.Sections {
+		if sec.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := sec.Data()
+		if err != nil {
+			return "", err
+		}
+		if id := noteBuildID(data, f.ByteOrder); id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// noteBuildID returns the GNU build ID from the contents of an ELF note section, if any.
+func noteBuildID(data []byte, order binary.ByteOrder) string {
+	const ntGNUBuildID = 3
+	align := func(v uint32) int { return int((v + 3) &^ 3) }
+	for len(data) >= 12 {
+		nameSize, descSize := order.Uint32(data), order.Uint32(data[4:])
+		typ := order.Uint32(data[8:])
+		// Check sizes before aligning them, align would wrap around for sizes close to 2^32.
+		if uint64(nameSize) > uint64(len(data)) || uint64(descSize) > uint64(len(data)) {
+			break
+		}
+		descStart := 12 + align(nameSize)
+		next := descStart + align(descSize)
+		if next > len(data) {
+			break
+		}
+		if typ == ntGNUBuildID && string(data[12:12+nameSize]) == "GNU\x00" {
+			return hex.EncodeToString(data[descStart : descStart+int(descSize)])
+		}
+		data = data[next:]
+	}
+	return ""
+}
+
+// resume merges the state restored from the checkpoint and sends the restored inputs
+// to the fuzzers that connected before the machine check.
+func (serv *RPCServer) resume(res *resumedState) error {
+	if err := serv.mergeCorpusCover(res.corpusCover); err != nil {
+		return err
+	}
+	serv.corpusSignal.Merge(res.corpusSignal)
+	serv.maxSignal.Merge(res.corpusSignal)
+	serv.maxSignal.Merge(res.maxSignal)
+	serv.stats.corpusSignal.set(serv.corpusSignal.Len())
+	serv.stats.maxSignal.set(serv.maxSignal.Len())
+	for _, f := range serv.fuzzers {
+		if f.rotated {
+			continue
+		}
+		f.inputs = append(f.inputs, res.inputs...)
+		f.newMaxSignal.Merge(serv.maxSignal)
+	}
+	return nil
+}
+
+// maxSignalCheckpoint returns max signal to save in the checkpoint.
+func (serv *RPCServer) maxSignalCheckpoint() signal.Serial {
+	serv.mu.Lock()
+	defer serv.mu.Unlock()
+	return serv.maxSignal.Serialize()
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestSignalCheckpoint(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := make(map[*prog.Syscall]bool)
+	for _, call := range target.Syscalls {
+		enabled[call] = true
+	}
+	workdir := t.TempDir()
+	image := filepath.Join(workdir, "image")
+	if err := os.WriteFile(image, []byte("kernel"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &mgrconfig.Config{Workdir: workdir, Image: image}
+	start := func(retriage bool) *Manager {
+		mgr := &Manager{
+			cfg:                   cfg,
+			target:                target,
+			sysTarget:             targets.Get(targets.TestOS, targets.TestArch64),
+			targetEnabledSyscalls: enabled,
+			corpus:                make(map[string]CorpusItem),
+			disabledHashes:        make(map[string]struct{}),
+			serv:                  &RPCServer{},
+		}
+		mgr.corpusDB, err = db.Open(filepath.Join(workdir, "corpus.db"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mgr.loadSeeds()
+		mgr.loadProvenance()
+		mgr.openSignalDB(retriage)
+		return mgr
+	}
+
+	mgr := start(false)
+	if mgr.resumeSignal {
+		t.Fatalf("resuming from an empty checkpoint")
+	}
+	mgr.loadCorpus()
+	if err := mgr.corpusDB.BumpVersion(currentDBVersion); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("test$int(0x1, 0x2, 0x3, 0x4, 0x5)\n")
+	sign := signal.FromRaw([]uint32{1, 2, 3}, 0)
+	if _, ok := mgr.newInput(rpctype.Input{
+		Call:   "test$int",
+		Prog:   data,
+		Signal: sign.Serialize(),
+		Cover:  []uint32{10, 20},
+		// Hub inputs added to the corpus are recreated from corpus.db, they are not checkpointed.
+		Provenance: rpctype.Provenance{Origin: rpctype.OriginHub},
+	}, sign); !ok {
+		t.Fatalf("input is not accepted")
+	}
+	hubInput := []byte("test$int(0x2, 0x2, 0x3, 0x4, 0x5)\n")
+	mgr.candidates = append(mgr.candidates, rpctype.Candidate{
+		Prog:       hubInput,
+		Provenance: rpctype.Provenance{Origin: rpctype.OriginHub},
+	})
+	mgr.serv.maxSignal = signal.FromRaw([]uint32{1, 2, 3, 4, 5}, 0)
+	mgr.checkpointSignal()
+
+	mgr = start(false)
+	if !mgr.resumeSignal {
+		t.Fatalf("checkpoint is not valid for the same kernel")
+	}
+	res := mgr.loadCorpus()
+	if len(res.inputs) != 1 || res.corpusSignal.Len() != 3 || res.maxSignal.Len() != 5 ||
+		len(res.corpusCover) != 2 {
+		t.Fatalf("bad resumed state: %+v", res)
+	}
+	if item := mgr.corpus[hash.String(data)]; item.Call != "test$int" || len(item.Cover) != 2 {
+		t.Fatalf("bad resumed input: %+v", item)
+	}
+	// The hub candidate is given the second chance, corpus inputs are not re-triaged.
+	if len(mgr.candidates) != 2 || string(mgr.candidates[0].Prog) != string(hubInput) {
+		t.Fatalf("bad candidates: %+v", mgr.candidates)
+	}
+
+	mgr = start(true)
+	if mgr.resumeSignal {
+		t.Fatalf("resuming with forced re-triage")
+	}
+	if res := mgr.loadCorpus(); len(res.inputs) != 0 || len(mgr.candidates) != 4 {
+		t.Fatalf("bad forced re-triage: %v inputs, %v candidates", len(res.inputs), len(mgr.candidates))
+	}
+	mgr.checkpointSignal()
+
+	// A new kernel image invalidates the checkpoint.
+	mgr = start(false)
+	if !mgr.resumeSignal {
+		t.Fatalf("checkpoint is not valid after re-triage")
+	}
+	// The corpus input is not resumed after re-triage, the hub candidate is saved once.
+	if mgr.loadCorpus(); len(mgr.candidates) != 4 || string(mgr.candidates[1].Prog) != string(hubInput) {
+		t.Fatalf("bad candidates after re-triage: %+v", mgr.candidates)
+	}
+	if err := os.Chtimes(image, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if mgr = start(false); mgr.resumeSignal {
+		t.Fatalf("checkpoint is valid for a new kernel")
+	}
+}
+
+func TestNoteBuildID(t *testing.T) {
+	note := func(nameSize, descSize, typ uint32, payload string) []byte {
+		data := make([]byte, 12)
+		binary.LittleEndian.PutUint32(data, nameSize)
+		binary.LittleEndian.PutUint32(data[4:], descSize)
+		binary.LittleEndian.PutUint32(data[8:], typ)
+		return append(data, payload...)
+	}
+	buildID := note(4, 4, 3, "GNU\x00\x01\x02\x03\x04")
+	tests := []struct {
+		data []byte
+		id   string
+	}{
+		{buildID, "01020304"},
+		{append(note(5, 1, 1, "Linux\x00\x00\x00\x07\x00\x00\x00"), buildID...), "01020304"},
+		{note(4, 4, 1, "GNU\x00\x01\x02\x03\x04"), ""},
+		{buildID[:len(buildID)-1], ""},
+		{note(0xfffffffd, 4, 3, "GNU\x00\x01\x02\x03\x04"), ""},
+		{note(4, 0xfffffffd, 3, "GNU\x00\x01\x02\x03\x04"), ""},
+		{nil, ""},
+	}
+	for i, test := range tests {
+		if id := noteBuildID(test.data, binary.LittleEndian); id != test.id {
+			t.Errorf("test #%v: got build ID %q, want %q", i, id, test.id)
+		}
+	}
+}
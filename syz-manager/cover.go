@@ -20,6 +20,12 @@ var getReportGenerator = func() func(cfg *mgrconfig.Config,
 	return func(cfg *mgrconfig.Config, modules []host.KernelModule) (*cover.ReportGenerator, error) {
 		once.Do(func() {
 			log.Logf(0, "initializing coverage information...")
+			if directedEnabled(cfg) {
+				// The generator is shared, so it's created with the call graph right away
+				// even if it's first needed for something else.
+				rg, err = cover.MakeCallGraphReportGenerator(cfg, cfg.KernelSubsystem, modules, cfg.RawCover)
+				return
+			}
 			rg, err = cover.MakeReportGenerator(cfg, cfg.KernelSubsystem, modules, cfg.RawCover)
 		})
 		return rg, err
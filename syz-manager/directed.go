@@ -0,0 +1,216 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/cover/backend"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+)
+
+// Directed fuzzing (see mgrconfig.Config.Directed): distances of kernel functions to the target functions
+// (functions with target lines) are computed once from the call graph extracted by pkg/cover/backend.
+// The distance of a corpus input is the minimum distance of the functions it covers (rpctype.Input.Distance),
+// fuzzers boost the energy of inputs close to the targets (see syz-fuzzer/energy.go).
+
+// maxDirectedDistance bounds the call graph search, further functions are treated as unreachable.
+const maxDirectedDistance = 16
+
+type directedTargets struct {
+	restorePC func(pc uint32) uint64
+	functions []string          // target functions
+	distances map[uint32]uint32 // PC -> distance of its function
+	targetPCs map[uint32]bool   // PCs of target lines (all PCs for function targets)
+	reached   map[uint32]bool   // target PCs covered by corpus inputs
+	reachable int               // number of functions with a distance
+}
+
+func (mgr *Manager) createDirectedTargets() (*directedTargets, error) {
+	if !directedEnabled(mgr.cfg) {
+		return nil, nil
+	}
+	rg, err := getReportGenerator(mgr.cfg, mgr.modules)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[*backend.Symbol][]uint64)
+	if err := directedFunctions(rg.Symbols, mgr.cfg.Directed.Functions, targets); err != nil {
+		return nil, err
+	}
+	if err := directedLines(rg, mgr.cfg.Directed.Lines, targets); err != nil {
+		return nil, err
+	}
+	dt := makeDirectedTargets(rg.Symbols, targets, rg.RestorePC)
+	log.Logf(0, "directed fuzzing: %v target functions %v, %v target PCs, %v functions reach them",
+		len(dt.functions), dt.functions, len(dt.targetPCs), dt.reachable)
+	if dt.reachable == len(dt.functions) {
+		log.Logf(0, "directed fuzzing: no callers of the targets, only inputs covering the targets are prioritized")
+	}
+	return dt, nil
+}
+
+func directedEnabled(cfg *mgrconfig.Config) bool {
+	return len(cfg.Directed.Functions)+len(cfg.Directed.Lines) != 0
+}
+
+func directedFunctions(symbols []*backend.Symbol, filters []string, targets map[*backend.Symbol][]uint64) error {
+	res, err := compileRegexps(filters)
+	if err != nil {
+		return err
+	}
+	used := make(map[*regexp.Regexp]bool)
+	for _, sym := range symbols {
+		for _, re := range res {
+			if re.MatchString(sym.Name) {
+				targets[sym] = sym.PCs
+				used[re] = true
+				break
+			}
+		}
+	}
+	for _, re := range res {
+		if !used[re] {
+			return fmt.Errorf("directed function %v doesn't match anything", re)
+		}
+	}
+	return nil
+}
+
+// directedLines finds PCs of the target lines among the functions of the corresponding compile units
+// (so lines of inline functions in headers are not found).
+func directedLines(rg *cover.ReportGenerator, lines []string, targets map[*backend.Symbol][]uint64) error {
+	for _, target := range lines {
+		file, line, err := mgrconfig.ParseDirectedLine(target)
+		if err != nil {
+			return err
+		}
+		pcs := make(map[*backend.Module][]uint64)
+		symbols := make(map[uint64]*backend.Symbol)
+		for _, sym := range rg.Symbols {
+			if sym.Unit.Name != file {
+				continue
+			}
+			pcs[sym.Module] = append(pcs[sym.Module], sym.PCs...)
+			for _, pc := range sym.PCs {
+				symbols[pc] = sym
+			}
+		}
+		if len(pcs) == 0 {
+			return fmt.Errorf("directed line %v: no functions in %v", target, file)
+		}
+		frames, err := rg.Symbolize(pcs)
+		if err != nil {
+			return fmt.Errorf("directed line %v: %w", target, err)
+		}
+		found := false
+		for _, frame := range frames {
+			if sym := symbols[frame.PC]; sym != nil && frame.Name == file && frame.StartLine == line {
+				targets[sym] = append(targets[sym], frame.PC)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("directed line %v: no coverage points on the line", target)
+		}
+	}
+	return nil
+}
+
+// makeDirectedTargets computes distances of functions to the targets (function -> target PCs in it)
+// by a breadth-first search over callers.
+func makeDirectedTargets(symbols []*backend.Symbol, targets map[*backend.Symbol][]uint64,
+	restorePC func(pc uint32) uint64) *directedTargets {
+	callers := make(map[*backend.Symbol][]*backend.Symbol)
+	for _, sym := range symbols {
+		for _, callee := range sym.Callees {
+			callers[callee] = append(callers[callee], sym)
+		}
+	}
+	dt := &directedTargets{
+		restorePC: restorePC,
+		distances: make(map[uint32]uint32),
+		targetPCs: make(map[uint32]bool),
+		reached:   make(map[uint32]bool),
+	}
+	dist := make(map[*backend.Symbol]uint32)
+	var queue []*backend.Symbol
+	for sym, pcs := range targets {
+		dt.functions = append(dt.functions, sym.Name)
+		for _, pc := range pcs {
+			dt.targetPCs[uint32(pc)] = true
+		}
+		dist[sym] = 1
+		queue = append(queue, sym)
+	}
+	sort.Strings(dt.functions)
+	for len(queue) != 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		if dist[sym] == maxDirectedDistance {
+			continue
+		}
+		for _, caller := range callers[sym] {
+			if _, ok := dist[caller]; !ok {
+				dist[caller] = dist[sym] + 1
+				queue = append(queue, caller)
+			}
+		}
+	}
+	dt.reachable = len(dist)
+	for sym, d := range dist {
+		for _, pc := range sym.PCs {
+			// PCs of different modules may collide after truncation, prefer the closer one.
+			if old := dt.distances[uint32(pc)]; old == 0 || d < old {
+				dt.distances[uint32(pc)] = d
+			}
+		}
+	}
+	return dt
+}
+
+// distance returns the distance of an input with the coverage and marks the target PCs it covers as reached.
+// Must be called with mgr.mu held.
+func (dt *directedTargets) distance(cov []uint32) uint32 {
+	if dt == nil {
+		return 0
+	}
+	res := uint32(0)
+	for _, pc := range cov {
+		pc := uint32(dt.restorePC(pc))
+		if d := dt.distances[pc]; d != 0 && (res == 0 || d < res) {
+			res = d
+		}
+		if dt.targetPCs[pc] {
+			dt.reached[pc] = true
+		}
+	}
+	return res
+}
+
+// directedStat describes the directed fuzzing progress for the summary page.
+// Must be called with mgr.mu held.
+func (mgr *Manager) directedStat() string {
+	closest, leading := uint32(0), 0
+	for _, inp := range mgr.corpus {
+		if inp.Distance == 0 {
+			continue
+		}
+		leading++
+		if closest == 0 || inp.Distance < closest {
+			closest = inp.Distance
+		}
+	}
+	dt := mgr.directed
+	res := fmt.Sprintf("%v functions, reached %v / %v PCs, %v inputs lead to targets",
+		len(dt.functions), len(dt.reached), len(dt.targetPCs), leading)
+	if closest != 0 {
+		res += fmt.Sprintf(", min distance %v", closest)
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/cover/backend"
+)
+
+func TestDirectedDistance(t *testing.T) {
+	sym := func(name string, pcs ...uint64) *backend.Symbol {
+		return &backend.Symbol{ObjectUnit: backend.ObjectUnit{Name: name, PCs: pcs}}
+	}
+	// entry -> {open, read}, open -> {lookup, alloc}, read -> alloc, lookup -> target, unrelated.
+	entry, open, read := sym("entry", 0x10), sym("open", 0x20), sym("read", 0x30)
+	lookup, alloc := sym("lookup", 0x40), sym("alloc", 0x50)
+	target, unrelated := sym("target", 0x60, 0x61, 0x62), sym("unrelated", 0x70)
+	entry.Callees = []*backend.Symbol{open, read}
+	open.Callees = []*backend.Symbol{lookup, alloc}
+	read.Callees = []*backend.Symbol{alloc}
+	lookup.Callees = []*backend.Symbol{target}
+	symbols := []*backend.Symbol{entry, open, read, lookup, alloc, target, unrelated}
+	var targets map[*backend.Symbol][]uint64
+	if err := directedFunctions(symbols, []string{"^nothing$"}, targets); err == nil {
+		t.Fatalf("no error for a function that doesn't match anything")
+	}
+	// A target line in the target function.
+	targets = map[*backend.Symbol][]uint64{target: {0x61}}
+	dt := makeDirectedTargets(symbols, targets, func(pc uint32) uint64 { return uint64(pc) })
+	if dt.reachable != 4 || !reflect.DeepEqual(dt.functions, []string{"target"}) {
+		t.Fatalf("bad targets: %v reachable, functions %v", dt.reachable, dt.functions)
+	}
+	tests := []struct {
+		cover    []uint32
+		distance uint32
+	}{
+		{nil, 0},
+		{[]uint32{0x50, 0x70}, 0},
+		{[]uint32{0x10, 0x30}, 4},
+		{[]uint32{0x10, 0x20, 0x50}, 3},
+		{[]uint32{0x40}, 2},
+		{[]uint32{0x10, 0x60}, 1},
+	}
+	for i, test := range tests {
+		if distance := dt.distance(test.cover); distance != test.distance {
+			t.Errorf("test #%v: distance %v, want %v", i, distance, test.distance)
+		}
+	}
+	if len(dt.reached) != 0 {
+		t.Fatalf("the target line is reached: %v", dt.reached)
+	}
+	if dt.distance([]uint32{0x61}) != 1 || !dt.reached[0x61] {
+		t.Fatalf("the target line is not reached: %v", dt.reached)
+	}
+	var nilTargets *directedTargets
+	if distance := nilTargets.distance([]uint32{0x61}); distance != 0 {
+		t.Fatalf("distance %v without directed fuzzing", distance)
+	}
+}
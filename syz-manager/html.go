@@ -22,8 +22,11 @@ import (
 	"github.com/google/syzkaller/pkg/cover"
 	"github.com/google/syzkaller/pkg/html/pages"
 	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/pkg/syzllm"
 	"github.com/google/syzkaller/pkg/vcs"
 	"github.com/google/syzkaller/prog"
 	"github.com/gorilla/handlers"
@@ -41,11 +44,14 @@ func (mgr *Manager) initHTTP() {
 	handle("/syscalls", mgr.httpSyscalls)
 	handle("/corpus", mgr.httpCorpus)
 	handle("/corpus.db", mgr.httpDownloadCorpus)
+	handle("/dataset.jsonl", mgr.httpDataset)
 	handle("/crash", mgr.httpCrash)
 	handle("/cover", mgr.httpCover)
 	handle("/subsystemcover", mgr.httpSubsystemCover)
 	handle("/modulecover", mgr.httpModuleCover)
 	handle("/prio", mgr.httpPrio)
+	handle("/syzllm", mgr.httpSyzLLM)
+	handle("/mutations", mgr.httpMutations)
 	handle("/file", mgr.httpFile)
 	handle("/report", mgr.httpReport)
 	handle("/rawcover", mgr.httpRawCover)
@@ -116,6 +122,8 @@ func (mgr *Manager) httpSyscalls(w http.ResponseWriter, r *http.Request) {
 }
 
 func (mgr *Manager) collectStats() []UIStat {
+	// RPCServer calls into Manager with serv.mu held, so serv.mu must not be taken under mgr.mu.
+	syzLLMDown, syzLLMFuzzers := mgr.serv.syzLLMServerDown()
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
 
@@ -135,6 +143,13 @@ func (mgr *Manager) collectStats() []UIStat {
 		{Name: "signal", Value: fmt.Sprint(rawStats["signal"])},
 		{Name: "coverage", Value: fmt.Sprint(rawStats["coverage"]), Link: "/cover"},
 	}
+	if mgr.directed != nil {
+		stats = append(stats, UIStat{
+			Name:  "directed",
+			Value: mgr.directedStat(),
+			Link:  "/corpus",
+		})
+	}
 	if mgr.coverFilter != nil {
 		stats = append(stats, UIStat{
 			Name: "filtered coverage",
@@ -147,6 +162,42 @@ func (mgr *Manager) collectStats() []UIStat {
 	delete(rawStats, "signal")
 	delete(rawStats, "coverage")
 	delete(rawStats, "filtered coverage")
+	if mgr.cfg.SyzLLM.Enabled {
+		// Detailed SyzLLM stats are on the /syzllm page.
+		var inserted, total uint64
+		for k, v := range rawStats {
+			if !strings.HasPrefix(k, "syzllm") {
+				continue
+			}
+			if strings.HasPrefix(k, rpctype.SyzLLMResultStat) {
+				total += v
+				if k == rpctype.SyzLLMResultStat+prog.PredictionInserted.String() {
+					inserted += v
+				}
+			}
+			delete(rawStats, k)
+		}
+		value := fmt.Sprintf("%v / %v inserted", inserted, total)
+		if syzLLMDown != 0 {
+			value += fmt.Sprintf(", server down on %v / %v VMs", syzLLMDown, syzLLMFuzzers)
+		}
+		stats = append(stats, UIStat{
+			Name:  "syzllm",
+			Value: value,
+			Link:  "/syzllm",
+		})
+	}
+	// Per operator stats are on the /mutations page.
+	for k := range rawStats {
+		if strings.HasPrefix(k, rpctype.MutationTriesStat) || strings.HasPrefix(k, rpctype.MutationSignalStat) {
+			delete(rawStats, k)
+		}
+	}
+	stats = append(stats, UIStat{
+		Name:  "mutations",
+		Value: mgr.mutationScheduler(),
+		Link:  "/mutations",
+	})
 	if mgr.checkResult != nil {
 		stats = append(stats, UIStat{
 			Name:  "syscalls",
@@ -167,6 +218,109 @@ func (mgr *Manager) collectStats() []UIStat {
 	return stats
 }
 
+func (mgr *Manager) httpSyzLLM(w http.ResponseWriter, r *http.Request) {
+	data := &UISyzLLMData{
+		Name: mgr.cfg.Name,
+	}
+	results := mgr.stats.namedWithPrefix(rpctype.SyzLLMResultStat)
+	var total uint64
+	for _, v := range results {
+		total += v
+	}
+	for res := prog.PredictionResult(0); res < prog.PredictionResultCount; res++ {
+		count := results[res.String()]
+		data.Results = append(data.Results, UISyzLLMCount{
+			Name:    res.String(),
+			Count:   count,
+			Percent: percent(count, total),
+		})
+	}
+	data.Total = total
+	data.ServerDown, data.Fuzzers = mgr.serv.syzLLMServerDown()
+	data.ServerDownTotal = mgr.stats.namedStat(rpctype.SyzLLMServerDownStat)
+	latency := mgr.stats.namedWithPrefix(rpctype.SyzLLMLatencyStat)
+	var requests uint64
+	for _, v := range latency {
+		requests += v
+	}
+	for i := 0; i <= len(rpctype.SyzLLMLatencyBuckets); i++ {
+		bucket := rpctype.SyzLLMLatencyBucket(i)
+		data.Latency = append(data.Latency, UISyzLLMCount{
+			Name:    bucket,
+			Count:   latency[bucket],
+			Percent: percent(latency[bucket], requests),
+		})
+	}
+	calls := mgr.stats.namedWithPrefix(rpctype.SyzLLMCallStat)
+	for call, count := range calls {
+		data.Calls = append(data.Calls, UISyzLLMCount{
+			Name:    call,
+			Count:   count,
+			Percent: percent(count, results[prog.PredictionInserted.String()]),
+		})
+	}
+	sort.Slice(data.Calls, func(i, j int) bool {
+		if data.Calls[i].Count != data.Calls[j].Count {
+			return data.Calls[i].Count > data.Calls[j].Count
+		}
+		return data.Calls[i].Name < data.Calls[j].Name
+	})
+	const maxCalls = 50
+	if len(data.Calls) > maxCalls {
+		data.Calls = data.Calls[:maxCalls]
+	}
+	mgr.mu.Lock()
+	data.CorpusInputs = len(mgr.corpus)
+	data.SyzLLMInputs = mgr.syzLLMCorpusInputsLocked()
+	mgr.mu.Unlock()
+	data.SyzLLMPercent = percent(uint64(data.SyzLLMInputs), uint64(data.CorpusInputs))
+	executeTemplate(w, syzLLMTemplate, data)
+}
+
+func (mgr *Manager) mutationScheduler() string {
+	if mgr.cfg.MutationScheduler == "" {
+		return mgrconfig.MutationSchedulerFixed
+	}
+	return mgr.cfg.MutationScheduler
+}
+
+func (mgr *Manager) httpMutations(w http.ResponseWriter, r *http.Request) {
+	data := &UIMutationsData{
+		Name:      mgr.cfg.Name,
+		Scheduler: mgr.mutationScheduler(),
+	}
+	tries := mgr.stats.namedWithPrefix(rpctype.MutationTriesStat)
+	signal := mgr.stats.namedWithPrefix(rpctype.MutationSignalStat)
+	weights := mgr.serv.mutationWeights()
+	var total uint64
+	for _, v := range tries {
+		total += v
+	}
+	for op := prog.MutationOp(0); op < prog.MutationOpCount; op++ {
+		name := op.String()
+		success := 0.0
+		if tries[name] != 0 {
+			success = float64(signal[name]) * 100 / float64(tries[name])
+		}
+		data.Ops = append(data.Ops, UIMutationOp{
+			Name:      name,
+			Programs:  tries[name],
+			Percent:   percent(tries[name], total),
+			NewSignal: signal[name],
+			Success:   fmt.Sprintf("%.2f%%", success),
+			Weight:    weights[name],
+		})
+	}
+	executeTemplate(w, mutationsTemplate, data)
+}
+
+func percent(v, total uint64) uint64 {
+	if total == 0 {
+		return 0
+	}
+	return v * 100 / total
+}
+
 func convertStats(stats map[string]uint64, secs uint64) []UIStat {
 	var intStats []UIStat
 	for k, v := range stats {
@@ -201,7 +355,9 @@ func (mgr *Manager) httpCorpus(w http.ResponseWriter, r *http.Request) {
 	data := UICorpus{
 		Call:     r.FormValue("call"),
 		RawCover: mgr.cfg.RawCover,
+		Directed: mgr.directed != nil,
 	}
+	depths := mgr.lineageDepths()
 	for sig, inp := range mgr.corpus {
 		if data.Call != "" && data.Call != inp.Call {
 			continue
@@ -212,9 +368,12 @@ func (mgr *Manager) httpCorpus(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		data.Inputs = append(data.Inputs, &UIInput{
-			Sig:   sig,
-			Short: p.String(),
-			Cover: len(inp.Cover),
+			Sig:      sig,
+			Short:    p.String(),
+			Cover:    len(inp.Cover),
+			Origin:   inp.Provenance.Origin,
+			Depth:    depths[sig],
+			Distance: inp.Distance,
 		})
 	}
 	sort.Slice(data.Inputs, func(i, j int) bool {
@@ -243,6 +402,92 @@ func (mgr *Manager) httpDownloadCorpus(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf)
 }
 
+// httpDataset exports the corpus with coverage as a JSONL training dataset, see syzllm.DatasetRecord.
+// The prompt parameter selects normalization of the calls (default: the configured SyzLLM prompt level).
+func (mgr *Manager) httpDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("prompt")
+	if name == "" {
+		name = mgr.cfg.SyzLLM.Prompt
+	}
+	level := prog.PromptFlags
+	if name != "" {
+		var err error
+		if level, err = prog.ParsePromptLevel(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	crashes := mgr.crashPrograms()
+	mgr.mu.Lock()
+	var inputs []*syzllm.DatasetInput
+	for sig, item := range mgr.corpus {
+		inp := &syzllm.DatasetInput{
+			Sig:        sig,
+			Prog:       item.Prog,
+			Call:       item.Call,
+			Signal:     len(item.Signal.Elems),
+			Cover:      len(item.Cover),
+			CallSignal: make(map[int]int),
+			CallCover:  make(map[int]int),
+			Origin:     item.Provenance.Origin,
+			Ops:        item.Provenance.Ops,
+		}
+		callCover := make(map[int]cover.Cover)
+		for _, update := range item.Updates {
+			if update.Signal > inp.CallSignal[update.CallID] {
+				inp.CallSignal[update.CallID] = update.Signal
+			}
+			cov := callCover[update.CallID]
+			cov.Merge(update.RawCover)
+			callCover[update.CallID] = cov
+		}
+		for id, cov := range callCover {
+			inp.CallCover[id] = len(cov)
+		}
+		inputs = append(inputs, inp)
+	}
+	mgr.mu.Unlock()
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Sig < inputs[j].Sig
+	})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, inp := range inputs {
+		rec, err := syzllm.NewDatasetRecord(mgr.target, inp, level, crashes[inp.Sig])
+		if err != nil {
+			log.Logf(1, "dataset: %v", err)
+			continue
+		}
+		if err := enc.Encode(rec); err != nil {
+			return
+		}
+	}
+}
+
+// crashPrograms collects programs from the logs of all saved crashes.
+func (mgr *Manager) crashPrograms() syzllm.CrashPrograms {
+	crashes := make(syzllm.CrashPrograms)
+	dirs, err := osutil.ListDir(mgr.crashdir)
+	if err != nil {
+		return crashes
+	}
+	for _, dir := range dirs {
+		desc, err := os.ReadFile(filepath.Join(mgr.crashdir, dir, "description"))
+		if err != nil || len(desc) == 0 {
+			continue
+		}
+		title := string(trimNewLines(desc))
+		for i := 0; i < mgr.cfg.MaxCrashLogs; i++ {
+			data, err := os.ReadFile(filepath.Join(mgr.crashdir, dir, fmt.Sprintf("log%v", i)))
+			if err != nil {
+				break
+			}
+			crashes.AddLog(mgr.target, title, data)
+		}
+	}
+	return crashes
+}
+
 const (
 	DoHTML int = iota
 	DoHTMLTable
@@ -459,13 +704,62 @@ func (mgr *Manager) httpFile(w http.ResponseWriter, r *http.Request) {
 func (mgr *Manager) httpInput(w http.ResponseWriter, r *http.Request) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
-	inp, ok := mgr.corpus[r.FormValue("sig")]
+	sig := r.FormValue("sig")
+	inp, ok := mgr.corpus[sig]
 	if !ok {
 		http.Error(w, "can't find the input", http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Write(inp.Prog)
+	data := UIInputData{
+		Sig:      sig,
+		Call:     inp.Call,
+		Prog:     string(inp.Prog),
+		RawCover: mgr.cfg.RawCover,
+	}
+	ancestors, root := mgr.lineage(sig)
+	var err error
+	for _, node := range ancestors {
+		var anc *UILineage
+		if anc, err = mgr.uiLineage(node); err != nil {
+			break
+		}
+		data.Ancestors = append(data.Ancestors, anc)
+	}
+	if err == nil {
+		data.Lineage, err = mgr.uiLineage(root)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	executeTemplate(w, inputTemplate, data)
+}
+
+// uiLineage converts the lineage tree rooted at node for the web UI.
+func (mgr *Manager) uiLineage(node *LineageNode) (*UILineage, error) {
+	res := &UILineage{Sig: node.Sig}
+	if node.Item == nil {
+		return res, nil
+	}
+	p, err := mgr.target.Deserialize(node.Item.Prog, prog.NonStrict)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize program: %w", err)
+	}
+	prov := node.Item.Provenance
+	res.Short = p.String()
+	res.Cover = len(node.Item.Cover)
+	res.Origin = prov.Origin
+	res.Ops = strings.Join(prov.Ops, ", ")
+	res.Added = prov.Added
+	for _, child := range node.Children {
+		uiChild, err := mgr.uiLineage(child)
+		if err != nil {
+			return nil, err
+		}
+		res.Children = append(res.Children, uiChild)
+		res.Descendants += 1 + uiChild.Descendants
+	}
+	return res, nil
 }
 
 func (mgr *Manager) httpDebugInput(w http.ResponseWriter, r *http.Request) {
@@ -752,6 +1046,42 @@ type UIStat struct {
 	Link  string
 }
 
+type UISyzLLMData struct {
+	Name          string
+	Total         uint64
+	Results       []UISyzLLMCount
+	Latency       []UISyzLLMCount
+	Calls         []UISyzLLMCount
+	CorpusInputs  int
+	SyzLLMInputs  int
+	SyzLLMPercent uint64
+	// Fuzzers that consider the server down now, all fuzzers, and how many times it went down.
+	ServerDown      int
+	Fuzzers         int
+	ServerDownTotal uint64
+}
+
+type UIMutationsData struct {
+	Name      string
+	Scheduler string
+	Ops       []UIMutationOp
+}
+
+type UIMutationOp struct {
+	Name      string
+	Programs  uint64 // executed programs mutated with the operator
+	Percent   uint64
+	NewSignal uint64 // how many of them gave new signal
+	Success   string
+	Weight    uint64 // per mille, averaged over fuzzers
+}
+
+type UISyzLLMCount struct {
+	Name    string
+	Count   uint64
+	Percent uint64
+}
+
 type UICallType struct {
 	Name   string
 	ID     *int
@@ -762,13 +1092,37 @@ type UICallType struct {
 type UICorpus struct {
 	Call     string
 	RawCover bool
+	Directed bool
 	Inputs   []*UIInput
 }
 
 type UIInput struct {
-	Sig   string
-	Short string
-	Cover int
+	Sig      string
+	Short    string
+	Cover    int
+	Origin   string
+	Depth    int    // number of ancestors
+	Distance uint32 // to directed fuzzing targets, 0 if none
+}
+
+type UIInputData struct {
+	Sig       string
+	Call      string
+	Prog      string
+	RawCover  bool
+	Ancestors []*UILineage // starting from the oldest one
+	Lineage   *UILineage   // the input and its descendants
+}
+
+type UILineage struct {
+	Sig         string
+	Short       string // empty if the input is not in the corpus anymore
+	Cover       int
+	Origin      string
+	Ops         string
+	Added       time.Time
+	Children    []*UILineage
+	Descendants int
 }
 
 var summaryTemplate = pages.Create(`
@@ -864,6 +1218,105 @@ var syscallsTemplate = pages.Create(`
 </body></html>
 `)
 
+var syzLLMTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Name }} syzkaller SyzLLM</title>
+	{{HEAD}}
+</head>
+<body>
+
+<b>Corpus inputs found by SyzLLM mutations: {{.SyzLLMInputs}} / {{.CorpusInputs}} ({{.SyzLLMPercent}}%)</b>
+<br>
+<b>Server is down on {{.ServerDown}} / {{.Fuzzers}} VMs (went down {{.ServerDownTotal}} times)</b>
+<br>
+<a href='/dataset.jsonl'>Corpus with coverage as a training dataset (JSONL)</a>
+<br>
+
+<table class="list_table">
+	<caption>Call insertion attempts ({{.Total}}):</caption>
+	<tr>
+		<th>Result</th>
+		<th>Count</th>
+		<th>Percent</th>
+	</tr>
+	{{range $r := $.Results}}
+	<tr>
+		<td>{{$r.Name}}</td>
+		<td>{{$r.Count}}</td>
+		<td>{{$r.Percent}}%</td>
+	</tr>
+	{{end}}
+</table>
+
+<table class="list_table">
+	<caption>Prediction latency:</caption>
+	<tr>
+		<th>Latency</th>
+		<th>Requests</th>
+		<th>Percent</th>
+	</tr>
+	{{range $l := $.Latency}}
+	<tr>
+		<td>{{$l.Name}}</td>
+		<td>{{$l.Count}}</td>
+		<td>{{$l.Percent}}%</td>
+	</tr>
+	{{end}}
+</table>
+
+<table class="list_table">
+	<caption>Most predicted syscalls:</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Syscall', textSort)" href="#">Syscall</a></th>
+		<th><a onclick="return sortTable(this, 'Inserted', numSort)" href="#">Inserted</a></th>
+		<th>Percent</th>
+	</tr>
+	{{range $c := $.Calls}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td>{{$c.Count}}</td>
+		<td>{{$c.Percent}}%</td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)
+
+var mutationsTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Name }} syzkaller mutations</title>
+	{{HEAD}}
+</head>
+<body>
+
+<table class="list_table">
+	<caption>Mutation operators ({{.Scheduler}} scheduler):</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Operator', textSort)" href="#">Operator</a></th>
+		<th><a onclick="return sortTable(this, 'Programs', numSort)" href="#">Programs</a></th>
+		<th>Percent</th>
+		<th><a onclick="return sortTable(this, 'New signal', numSort)" href="#">New signal</a></th>
+		<th>Success</th>
+		<th><a onclick="return sortTable(this, 'Weight', numSort)" href="#">Weight (&#8240;)</a></th>
+	</tr>
+	{{range $op := $.Ops}}
+	<tr>
+		<td>{{$op.Name}}</td>
+		<td>{{$op.Programs}}</td>
+		<td>{{$op.Percent}}%</td>
+		<td>{{$op.NewSignal}}</td>
+		<td>{{$op.Success}}</td>
+		<td>{{$op.Weight}}</td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)
+
 var crashTemplate = pages.Create(`
 <!doctype html>
 <html>
@@ -916,6 +1369,11 @@ var corpusTemplate = pages.Create(`
 	<caption>Corpus{{if $.Call}} for {{$.Call}}{{end}}:</caption>
 	<tr>
 		<th>Coverage</th>
+		<th><a onclick="return sortTable(this, 'Origin', textSort)" href="#">Origin</a></th>
+		<th><a onclick="return sortTable(this, 'Depth', numSort)" href="#">Depth</a></th>
+		{{if $.Directed}}
+		<th><a onclick="return sortTable(this, 'Distance', numSort)" href="#">Distance</a></th>
+		{{end}}
 		<th>Program</th>
 	</tr>
 	{{range $inp := $.Inputs}}
@@ -926,6 +1384,11 @@ var corpusTemplate = pages.Create(`
 		/ <a href="/debuginput?sig={{$inp.Sig}}">[raw]</a>
 	{{end}}
 		</td>
+		<td>{{$inp.Origin}}</td>
+		<td>{{$inp.Depth}}</td>
+		{{if $.Directed}}
+		<td>{{if $inp.Distance}}{{$inp.Distance}}{{end}}</td>
+		{{end}}
 		<td><a href="/input?sig={{$inp.Sig}}">{{$inp.Short}}</a></td>
 	</tr>
 	{{end}}
@@ -933,6 +1396,79 @@ var corpusTemplate = pages.Create(`
 </body></html>
 `)
 
+var inputTemplate = pages.Create(`
+{{define "lineage"}}
+<li>
+	{{if .Short}}
+		<a href="/input?sig={{.Sig}}">{{.Short}}</a>:
+		{{.Origin}}{{if .Ops}} ({{.Ops}}){{end}}, coverage {{.Cover}}{{if not .Added.IsZero}},
+		added {{formatTime .Added}}{{end}}{{if .Descendants}}, {{.Descendants}} descendants{{end}}
+	{{else}}
+		{{.Sig}} (not in the corpus anymore)
+	{{end}}
+	{{if .Children}}
+	<ul>
+		{{range .Children}}{{template "lineage" .}}{{end}}
+	</ul>
+	{{end}}
+</li>
+{{end}}
+<!doctype html>
+<html>
+<head>
+	<title>syzkaller input</title>
+	{{HEAD}}
+</head>
+<body>
+
+<b>Input {{.Sig}} for {{.Call}}</b>
+<a href='/cover?input={{.Sig}}'>[coverage]</a>
+{{if .RawCover}}<a href="/debuginput?sig={{.Sig}}">[raw coverage]</a>{{end}}
+<pre>{{.Prog}}</pre>
+
+<table class="list_table">
+	<caption>Lineage:</caption>
+	<tr>
+		<th>Depth</th>
+		<th>Program</th>
+		<th>Origin</th>
+		<th>Mutations</th>
+		<th>Coverage</th>
+		<th>Added</th>
+	</tr>
+	{{range $i, $anc := $.Ancestors}}
+	<tr>
+		<td>{{$i}}</td>
+	{{if $anc.Short}}
+		<td><a href="/input?sig={{$anc.Sig}}">{{$anc.Short}}</a></td>
+		<td>{{$anc.Origin}}</td>
+		<td>{{$anc.Ops}}</td>
+		<td>{{$anc.Cover}}</td>
+		<td>{{formatTime $anc.Added}}</td>
+	{{else}}
+		<td>{{$anc.Sig}}</td>
+		<td colspan="4">not in the corpus anymore</td>
+	{{end}}
+	</tr>
+	{{end}}
+	<tr>
+		<td>{{len $.Ancestors}}</td>
+		<td><b>{{$.Lineage.Short}}</b></td>
+		<td>{{$.Lineage.Origin}}</td>
+		<td>{{$.Lineage.Ops}}</td>
+		<td>{{$.Lineage.Cover}}</td>
+		<td>{{formatTime $.Lineage.Added}}</td>
+	</tr>
+</table>
+<br>
+
+<b>Descendants ({{$.Lineage.Descendants}}):</b>
+<ul>
+	{{template "lineage" $.Lineage}}
+</ul>
+</body></html>
+`)
+
 type UIPrioData struct {
 	Call  string
 	Prios []UIPrio
@@ -230,9 +230,10 @@ func (hc *HubConnector) processProgs(inputs []rpctype.HubInput) (minimized, smas
 			smashed++
 		}
 		candidates = append(candidates, rpctype.Candidate{
-			Prog:      inp.Prog,
-			Minimized: min,
-			Smashed:   smash,
+			Prog:       inp.Prog,
+			Minimized:  min,
+			Smashed:    smash,
+			Provenance: rpctype.Provenance{Origin: rpctype.OriginHub},
 		})
 	}
 	hc.mgr.addNewCandidates(candidates)
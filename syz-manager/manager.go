@@ -16,6 +16,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -37,6 +38,7 @@ import (
 	"github.com/google/syzkaller/pkg/repro"
 	"github.com/google/syzkaller/pkg/rpctype"
 	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/pkg/syzllm"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/google/syzkaller/vm"
@@ -46,6 +48,8 @@ var (
 	flagConfig = flag.String("config", "", "configuration file")
 	flagDebug  = flag.Bool("debug", false, "dump all VM output to console")
 	flagBench  = flag.String("bench", "", "write execution statistics into this file periodically")
+
+	flagRetriage = flag.Bool("retriage", false, "re-triage the whole corpus even if signal.db is valid for the kernel")
 )
 
 type Manager struct {
@@ -57,6 +61,9 @@ type Manager struct {
 	crashdir       string
 	serv           *RPCServer
 	corpusDB       *db.DB
+	seedDB         *db.DB // power schedule counters of corpus inputs, see seeds.go
+	provDB         *db.DB // provenance of corpus inputs, see provenance.go
+	signalDB       *db.DB // checkpoint of corpus and max signal, see checkpoint.go
 	startTime      time.Time
 	firstConnect   time.Time
 	fuzzingTime    time.Duration
@@ -93,15 +100,21 @@ type Manager struct {
 	usedFiles map[string]time.Time
 
 	modules            []host.KernelModule
+	syzLLMBundle       *prog.SyzLLMBundle
 	coverFilter        map[uint32]uint32
+	directed           *directedTargets // nil if directed fuzzing is disabled
 	execCoverFilter    map[uint32]uint32
 	modulesInitialized bool
 
 	assetStorage *asset.Storage
+
+	seedsFlushed time.Time // last write of seedDB
+	resumeSignal bool      // signalDB is valid for the kernel, corpus inputs with saved signal are not re-triaged
 }
 
 type CorpusItemUpdate struct {
 	CallID   int
+	Signal   int // amount of signal of the call
 	RawCover []uint32
 }
 
@@ -111,14 +124,22 @@ type CorpusItem struct {
 	Signal  signal.Serial
 	Cover   []uint32
 	Updates []CorpusItemUpdate
+	SyzLLM  bool // found by a program mutated with a SyzLLM-predicted call
+	Seed    rpctype.SeedStats
+	// Distance to directed fuzzing targets, not saved (the targets may change between restarts).
+	Distance uint32
+	// Saved in provenance.db, see provenance.go.
+	Provenance rpctype.Provenance
 }
 
 func (item *CorpusItem) RPCInput() rpctype.Input {
 	return rpctype.Input{
-		Call:   item.Call,
-		Prog:   item.Prog,
-		Signal: item.Signal,
-		Cover:  item.Cover,
+		Call:     item.Call,
+		Prog:     item.Prog,
+		Signal:   item.Signal,
+		Cover:    item.Cover,
+		Seed:     item.Seed,
+		Distance: item.Distance,
 	}
 }
 
@@ -137,7 +158,7 @@ const (
 	phaseTriagedHub
 )
 
-const currentDBVersion = 4
+const currentDBVersion = 6
 
 type Crash struct {
 	vmIndex int
@@ -207,19 +228,26 @@ func RunManager(cfg *mgrconfig.Config) {
 		saturatedCalls:   make(map[string]bool),
 	}
 
-	mgr.preloadCorpus()
-	mgr.initStats() // Initializes prometheus variables.
-	mgr.initHTTP()  // Creates HTTP server.
-	mgr.collectUsedFiles()
+	if cfg.SyzLLM.Enabled && cfg.SyzLLM.Bundle != "" {
+		mgr.syzLLMBundle, err = syzllm.ReadBundle(cfg.SyzLLM.Bundle, mgr.target)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
 
-	//PreprocessAllCorpora(mgr, AllocateConstant)
+	mgr.preloadCorpus()
 
 	// Create RPC server for fuzzers.
+	// It's created before the HTTP server, so that handlers and prometheus variables can use mgr.serv.
 	mgr.serv, err = startRPCServer(mgr)
 	if err != nil {
 		log.Fatalf("failed to create rpc server: %v", err)
 	}
 
+	mgr.initStats() // Initializes prometheus variables.
+	mgr.initHTTP()  // Creates HTTP server.
+	mgr.collectUsedFiles()
+
 	if cfg.DashboardAddr != "" {
 		mgr.dash, err = dashapi.New(cfg.DashboardClient, cfg.DashboardAddr, cfg.DashboardKey)
 		if err != nil {
@@ -235,6 +263,7 @@ func RunManager(cfg *mgrconfig.Config) {
 	}
 
 	go func() {
+		lastCheckpoint := time.Now()
 		for lastTime := time.Now(); ; {
 			time.Sleep(10 * time.Second)
 			now := time.Now()
@@ -259,7 +288,12 @@ func RunManager(cfg *mgrconfig.Config) {
 			log.Logf(0, "VMs %v, executed %v, cover %v, signal %v/%v, crashes %v, repro %v, triageQLen %v",
 				numFuzzing, executed, corpusCover, corpusSignal, maxSignal, crashes, numReproducing, triageQLen)
 
-			SendCoverToSyzLLM(corpusCover)
+			mgr.sendCoverToSyzLLM(corpusCover)
+
+			if now.Sub(lastCheckpoint) >= signalCheckpointPeriod {
+				lastCheckpoint = now
+				mgr.checkpointSignal()
+			}
 		}
 	}()
 
@@ -277,9 +311,11 @@ func RunManager(cfg *mgrconfig.Config) {
 		log.Logf(0, "you are supposed to start syz-fuzzer manually as:")
 		log.Logf(0, "syz-fuzzer -manager=manager.ip:%v [other flags as necessary]", mgr.serv.port)
 		<-vm.Shutdown
+		mgr.checkpointSignal()
 		return
 	}
 	mgr.vmLoop()
+	mgr.checkpointSignal()
 }
 
 // IF SyzLLM
@@ -289,7 +325,7 @@ var (
 )
 
 // getClientInstance initializes a single instance of http.Client with appropriate settings.
-func getClientInstance() *http.Client {
+func getClientInstance(timeout time.Duration) *http.Client {
 	_once.Do(func() {
 		_clientInstance = &http.Client{
 			Transport: &http.Transport{
@@ -297,24 +333,66 @@ func getClientInstance() *http.Client {
 				IdleConnTimeout:    30 * time.Second,
 				DisableCompression: true,
 			},
-			Timeout: 15 * time.Second,
+			Timeout: timeout,
 		}
 	})
 	return _clientInstance
 }
 
-const URL string = "http://10.211.55.4:6678/cover"
+func (mgr *Manager) syzLLMServerEnabled() bool {
+	return mgr.cfg.SyzLLM.Enabled && mgr.cfg.SyzLLM.Predictor == mgrconfig.SyzLLMPredictorHTTP
+}
 
-func SendCoverToSyzLLM(cover uint64) {
+func (mgr *Manager) postToSyzLLM(path, contentType string, data []byte) error {
+	url := fmt.Sprintf("http://%v%v", mgr.cfg.SyzLLM.Addr, path)
+	client := getClientInstance(time.Duration(mgr.cfg.SyzLLM.Timeout) * time.Millisecond)
+	resp, err := client.Post(url, contentType, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v: %v", url, resp.Status)
+	}
+	return nil
+}
+
+func (mgr *Manager) sendCoverToSyzLLM(cover uint64) {
+	if !mgr.syzLLMServerEnabled() {
+		return
+	}
 	numberBytes := []byte(strconv.Itoa(int(cover)))
-	// Make the HTTP POST request.
-	client := getClientInstance()
-	resp, err := client.Post(URL, "text/plain", bytes.NewBuffer(numberBytes))
+	if err := mgr.postToSyzLLM("/cover", "text/plain", numberBytes); err != nil {
+		log.Logf(1, "failed to send coverage to SyzLLM: %v", err)
+	}
+}
+
+// reportCrashToSyzLLM attributes the crash to the last program with a predicted call
+// that the fuzzer logged before the crash, and sends the outcome to the server.
+func (mgr *Manager) reportCrashToSyzLLM(crash *Crash) {
+	if !mgr.syzLLMServerEnabled() {
+		return
+	}
+	predicted := prog.ParsePredictedCalls(crash.Output)
+	if len(predicted) == 0 {
+		return
+	}
+	last := predicted[len(predicted)-1]
+	data, err := json.Marshal([]prog.PredictionOutcome{{
+		ID:       last.ID,
+		Call:     last.Call,
+		Position: last.Position,
+		Crash:    crash.Title,
+	}})
 	if err != nil {
-		//fmt.Println("Error making request when sending cover to SyzLLM:", err)
+		log.Logf(0, "failed to marshal SyzLLM crash outcome: %v", err)
 		return
 	}
-	resp.Body.Close()
+	go func() {
+		if err := mgr.postToSyzLLM("/outcome", "application/json", data); err != nil {
+			log.Logf(1, "failed to send SyzLLM crash outcome: %v", err)
+		}
+	}()
 }
 
 // ENDIF
@@ -640,6 +718,9 @@ func (mgr *Manager) preloadCorpus() {
 		log.Errorf("read %v inputs from corpus and got error: %v", len(corpusDB.Records), err)
 	}
 	mgr.corpusDB = corpusDB
+	mgr.loadSeeds()
+	mgr.loadProvenance()
+	mgr.openSignalDB(*flagRetriage)
 
 	if seedDir := filepath.Join(mgr.cfg.Syzkaller, "sys", mgr.cfg.TargetOS, "test"); osutil.IsExist(seedDir) {
 		seeds, err := os.ReadDir(seedDir)
@@ -656,11 +737,11 @@ func (mgr *Manager) preloadCorpus() {
 	}
 }
 
-func (mgr *Manager) loadCorpus() {
+func (mgr *Manager) loadCorpus() *resumedState {
 	// By default we don't re-minimize/re-smash programs from corpus,
 	// it takes lots of time on start and is unnecessary.
 	// However, on version bumps we can selectively re-minimize/re-smash.
-	minimized, smashed := true, true
+	minimized, smashed, migrate := true, true, false
 	switch mgr.corpusDB.Version {
 	case 0:
 		// Version 0 had broken minimization, so we need to re-minimize.
@@ -678,24 +759,54 @@ func (mgr *Manager) loadCorpus() {
 		// Version 3->4: to shake things up.
 		minimized = false
 		fallthrough
+	case 4:
+		// Version 4->5: provenance of inputs is saved after programs, older inputs get OriginCorpus.
+		fallthrough
+	case 5:
+		// Version 5->6: provenance of inputs is moved to provenance.db.
+		migrate = true
+		fallthrough
 	case currentDBVersion:
 	}
+	res := new(resumedState)
+	resume := mgr.resumeSignal && minimized && smashed
 	broken := 0
 	for key, rec := range mgr.corpusDB.Records {
-		if !mgr.loadProg(rec.Val, minimized, smashed) {
+		data := rec.Val
+		if migrate {
+			data = mgr.migrateProvenance(key, data)
+		}
+		if resume && mgr.resumeInput(key, data, res) {
+			continue
+		}
+		if !mgr.loadProg(data, minimized, smashed, mgr.savedProvenance(key)) {
 			mgr.corpusDB.Delete(key)
 			broken++
 		}
 	}
+	if migrate {
+		if err := mgr.provDB.Flush(); err != nil {
+			log.Errorf("failed to save provenance database: %v", err)
+		}
+	}
 	mgr.fresh = len(mgr.corpusDB.Records) == 0
 	corpusSize := len(mgr.candidates)
 	log.Logf(0, "%-24v: %v (deleted %v broken)", "corpus", corpusSize, broken)
+	if resume {
+		res.maxSignal = mgr.resumeMaxSignal()
+		log.Logf(0, "%-24v: %v (signal %v, max signal %v)", "resumed corpus",
+			len(res.inputs), res.corpusSignal.Len(), res.maxSignal.Len())
+	}
 
 	for _, seed := range mgr.seeds {
-		mgr.loadProg(seed, true, false)
+		mgr.loadProg(seed, true, false, rpctype.Provenance{Origin: rpctype.OriginSeed})
 	}
 	log.Logf(0, "%-24v: %v/%v", "seeds", len(mgr.candidates)-corpusSize, len(mgr.seeds))
 	mgr.seeds = nil
+	if candidates := mgr.resumeCandidates(); len(candidates) != 0 {
+		mgr.candidates = append(mgr.candidates, candidates...)
+		log.Logf(0, "%-24v: %v", "hub candidates", len(candidates))
+	}
 
 	// We duplicate all inputs in the corpus and shuffle the second part.
 	// This solves the following problem. A fuzzer can crash while triaging candidates,
@@ -711,9 +822,10 @@ func (mgr *Manager) loadCorpus() {
 		panic(fmt.Sprintf("loadCorpus: bad phase %v", mgr.phase))
 	}
 	mgr.phase = phaseLoadedCorpus
+	return res
 }
 
-func (mgr *Manager) loadProg(data []byte, minimized, smashed bool) bool {
+func (mgr *Manager) loadProg(data []byte, minimized, smashed bool, prov rpctype.Provenance) bool {
 	bad, disabled := checkProgram(mgr.target, mgr.targetEnabledSyscalls, data)
 	if bad != nil {
 		return false
@@ -731,18 +843,20 @@ func (mgr *Manager) loadProg(data []byte, minimized, smashed bool) bool {
 			leftover := programLeftover(mgr.target, mgr.targetEnabledSyscalls, data)
 			if len(leftover) > 0 {
 				mgr.candidates = append(mgr.candidates, rpctype.Candidate{
-					Prog:      leftover,
-					Minimized: false,
-					Smashed:   smashed,
+					Prog:       leftover,
+					Minimized:  false,
+					Smashed:    smashed,
+					Provenance: prov,
 				})
 			}
 		}
 		return true
 	}
 	mgr.candidates = append(mgr.candidates, rpctype.Candidate{
-		Prog:      data,
-		Minimized: minimized,
-		Smashed:   smashed,
+		Prog:       data,
+		Minimized:  minimized,
+		Smashed:    smashed,
+		Provenance: prov,
 	})
 	return true
 }
@@ -764,6 +878,9 @@ func programLeftover(target *prog.Target, enabled map[*prog.Syscall]bool, data [
 }
 
 func checkProgram(target *prog.Target, enabled map[*prog.Syscall]bool, data []byte) (bad error, disabled bool) {
+	// Deserialization fixes up arguments that don't match the descriptions and validates the program,
+	// fuzzers deserialize inputs the same way. So malformed programs saved by SyzLLM mutations before
+	// they were repaired with prog.Target.DeserializeSyzLLM are either fixed up or dropped here.
 	p, err := target.Deserialize(data, prog.NonStrict)
 	if err != nil {
 		return err, true
@@ -931,6 +1048,7 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 		flags += " [suppressed]"
 	}
 	log.Logf(0, "vm-%v: crash: %v%v", crash.vmIndex, crash.Title, flags)
+	mgr.reportCrashToSyzLLM(crash)
 
 	if crash.Suppressed {
 		// Collect all of them into a single bucket so that it's possible to control and assess them,
@@ -1327,6 +1445,32 @@ func (mgr *Manager) minimizeCorpus() {
 		}
 	}
 	mgr.corpusDB.BumpVersion(currentDBVersion)
+	for key := range mgr.seedDB.Records {
+		if _, ok := mgr.corpus[key]; !ok {
+			mgr.seedDB.Delete(key)
+		}
+	}
+	if err := mgr.seedDB.Flush(); err != nil {
+		log.Errorf("failed to save seeds database: %v", err)
+	}
+	for key := range mgr.provDB.Records {
+		_, ok1 := mgr.corpus[key]
+		_, ok2 := mgr.disabledHashes[key]
+		if !ok1 && !ok2 {
+			mgr.provDB.Delete(key)
+		}
+	}
+	if err := mgr.provDB.Flush(); err != nil {
+		log.Errorf("failed to save provenance database: %v", err)
+	}
+	for key := range mgr.signalDB.Records {
+		if !strings.HasPrefix(key, signalKeyInput) {
+			continue
+		}
+		if _, ok := mgr.corpus[strings.TrimPrefix(key, signalKeyInput)]; !ok {
+			mgr.signalDB.Delete(key)
+		}
+	}
 }
 
 func setGuiltyFiles(crash *dashapi.Crash, report *report.Report) {
@@ -1392,29 +1536,52 @@ func (mgr *Manager) fuzzerConnect(modules []host.KernelModule) (
 		if err != nil {
 			log.Fatalf("failed to create coverage filter: %v", err)
 		}
+		mgr.directed, err = mgr.createDirectedTargets()
+		if err != nil {
+			log.Fatalf("failed to create directed fuzzing targets: %v", err)
+		}
 		mgr.modulesInitialized = true
 	}
 	return corpus, frames, mgr.coverFilter, mgr.execCoverFilter, nil
 }
 
-func (mgr *Manager) machineChecked(a *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool) {
+func (mgr *Manager) machineChecked(a *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool) *resumedState {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
 	mgr.checkResult = a
 	mgr.targetEnabledSyscalls = enabledSyscalls
 	mgr.target.UpdateGlobs(a.GlobFiles)
-	mgr.loadCorpus()
+	res := mgr.loadCorpus()
 	mgr.firstConnect = time.Now()
+	return res
 }
 
-func (mgr *Manager) newInput(inp rpctype.Input, sign signal.Signal) bool {
+func (mgr *Manager) syzLLMCorpusInputs() int {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	return mgr.syzLLMCorpusInputsLocked()
+}
+
+func (mgr *Manager) syzLLMCorpusInputsLocked() int {
+	count := 0
+	for _, inp := range mgr.corpus {
+		if inp.SyzLLM {
+			count++
+		}
+	}
+	return count
+}
+
+// newInput returns the power schedule counters and the distance of the input and whether it was accepted.
+func (mgr *Manager) newInput(inp rpctype.Input, sign signal.Signal) (rpctype.NewInputRes, bool) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
 	if mgr.saturatedCalls[inp.Call] {
-		return false
+		return rpctype.NewInputRes{}, false
 	}
 	update := CorpusItemUpdate{
 		CallID:   inp.CallID,
+		Signal:   len(inp.Signal.Elems),
 		RawCover: inp.RawCover,
 	}
 	sig := hash.String(inp.Prog)
@@ -1426,26 +1593,46 @@ func (mgr *Manager) newInput(inp rpctype.Input, sign signal.Signal) bool {
 		cov.Merge(old.Cover)
 		cov.Merge(inp.Cover)
 		old.Cover = cov.Serialize()
+		old.Distance = mgr.directed.distance(old.Cover)
 		const maxUpdates = 32
 		old.Updates = append(old.Updates, update)
 		if len(old.Updates) > maxUpdates {
 			old.Updates = old.Updates[:maxUpdates]
 		}
 		mgr.corpus[sig] = old
-	} else {
-		mgr.corpus[sig] = CorpusItem{
-			Call:    inp.Call,
-			Prog:    inp.Prog,
-			Signal:  inp.Signal,
-			Cover:   inp.Cover,
-			Updates: []CorpusItemUpdate{update},
-		}
-		mgr.corpusDB.Save(sig, inp.Prog, 0)
-		if err := mgr.corpusDB.Flush(); err != nil {
-			log.Errorf("failed to save corpus database: %v", err)
-		}
-	}
-	return true
+		mgr.saveInputSignal(sig, &old)
+		return rpctype.NewInputRes{Seed: old.Seed, Distance: old.Distance}, true
+	}
+	// The counters may be saved if the input was in the corpus before a restart.
+	seed := mgr.savedSeed(sig)
+	// Inputs from corpus.db keep the provenance and the time they were first added.
+	prov := inp.Provenance
+	if prov.Added.IsZero() {
+		prov.Added = time.Now()
+	}
+	item := CorpusItem{
+		Call:    inp.Call,
+		Prog:    inp.Prog,
+		Signal:  inp.Signal,
+		Cover:   inp.Cover,
+		Updates: []CorpusItemUpdate{update},
+		SyzLLM:  inp.SyzLLM,
+		Seed:    seed,
+
+		Provenance: prov,
+		Distance:   mgr.directed.distance(inp.Cover),
+	}
+	mgr.corpus[sig] = item
+	mgr.saveInputSignal(sig, &item)
+	mgr.corpusDB.Save(sig, inp.Prog, 0)
+	if err := mgr.corpusDB.Flush(); err != nil {
+		log.Errorf("failed to save corpus database: %v", err)
+	}
+	mgr.saveProvenance(sig, prov)
+	if err := mgr.provDB.Flush(); err != nil {
+		log.Errorf("failed to save provenance database: %v", err)
+	}
+	return rpctype.NewInputRes{Seed: seed, Distance: item.Distance}, true
 }
 
 func (mgr *Manager) candidateBatch(size int) []rpctype.Candidate {
@@ -1519,6 +1706,9 @@ func (mgr *Manager) collectUsedFiles() {
 	if cfg.Image != "9p" {
 		addUsedFile(cfg.Image)
 	}
+	if cfg.SyzLLM.Enabled {
+		addUsedFile(cfg.SyzLLM.Bundle)
+	}
 }
 
 func (mgr *Manager) checkUsedFiles() {
@@ -0,0 +1,163 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpctype"
+)
+
+// Provenance of corpus inputs is saved in provenance.db (input hash -> JSON rpctype.Provenance),
+// corpus.db records stay plain programs that hash to their keys, so that other tools can read them.
+
+func (mgr *Manager) loadProvenance() {
+	provDB, err := db.Open(filepath.Join(mgr.cfg.Workdir, "provenance.db"), true)
+	if err != nil {
+		if provDB == nil {
+			log.Fatalf("failed to open provenance database: %v", err)
+		}
+		log.Errorf("read %v provenance records and got error: %v", len(provDB.Records), err)
+	}
+	mgr.provDB = provDB
+}
+
+// savedProvenance returns the saved provenance of the input with the hash sig.
+// Inputs saved before provenance was recorded get OriginCorpus.
+func (mgr *Manager) savedProvenance(sig string) rpctype.Provenance {
+	prov := rpctype.Provenance{Origin: rpctype.OriginCorpus}
+	rec, ok := mgr.provDB.Records[sig]
+	if !ok {
+		return prov
+	}
+	if err := json.Unmarshal(rec.Val, &prov); err != nil {
+		log.Errorf("bad provenance of %v: %v", sig, err)
+		return rpctype.Provenance{Origin: rpctype.OriginCorpus}
+	}
+	return prov
+}
+
+func (mgr *Manager) saveProvenance(sig string, prov rpctype.Provenance) {
+	data, err := json.Marshal(prov)
+	if err != nil {
+		panic(fmt.Sprintf("failed to serialize provenance: %v", err))
+	}
+	mgr.provDB.Save(sig, data, 0)
+}
+
+// legacyProvenanceComment starts the last line of corpus.db records of version 5,
+// which had the provenance after the program.
+const legacyProvenanceComment = "# provenance: "
+
+// migrateProvenance moves the provenance line of a version 5 corpus.db record to provenance.db
+// and returns the program.
+func (mgr *Manager) migrateProvenance(sig string, rec []byte) []byte {
+	pos := bytes.LastIndex(rec, []byte("\n"+legacyProvenanceComment))
+	if pos == -1 {
+		return rec
+	}
+	data, line := rec[:pos+1], bytes.TrimSpace(rec[pos+1+len(legacyProvenanceComment):])
+	var prov rpctype.Provenance
+	if err := json.Unmarshal(line, &prov); err != nil {
+		log.Errorf("corpus input %v: bad provenance %q: %v", sig, line, err)
+	} else {
+		mgr.saveProvenance(sig, prov)
+	}
+	mgr.corpusDB.Save(sig, data, 0)
+	return data
+}
+
+// maxLineage limits the number of inputs shown in a lineage tree.
+const maxLineage = 1000
+
+// LineageNode is an input in a lineage tree.
+type LineageNode struct {
+	Sig      string
+	Item     *CorpusItem // nil if the input is not in the corpus anymore
+	Children []*LineageNode
+}
+
+// lineage returns the ancestors of the input starting from the oldest one
+// (the first one may be not in the corpus anymore) and the tree of its descendants.
+// Must be called with mgr.mu held.
+func (mgr *Manager) lineage(sig string) ([]*LineageNode, *LineageNode) {
+	var ancestors []*LineageNode
+	seen := map[string]bool{sig: true}
+	for parent := mgr.corpus[sig].Provenance.Parent; parent != "" && !seen[parent]; {
+		seen[parent] = true
+		node := &LineageNode{Sig: parent}
+		ancestors = append([]*LineageNode{node}, ancestors...)
+		item, ok := mgr.corpus[parent]
+		if !ok || len(ancestors) >= maxLineage {
+			break
+		}
+		node.Item = &item
+		parent = item.Provenance.Parent
+	}
+	children := make(map[string][]string)
+	for sig1, item := range mgr.corpus {
+		if parent := item.Provenance.Parent; parent != "" {
+			children[parent] = append(children[parent], sig1)
+		}
+	}
+	for _, sigs := range children {
+		sort.Slice(sigs, func(i, j int) bool {
+			a, b := mgr.corpus[sigs[i]].Provenance.Added, mgr.corpus[sigs[j]].Provenance.Added
+			if !a.Equal(b) {
+				return a.Before(b)
+			}
+			return sigs[i] < sigs[j]
+		})
+	}
+	item := mgr.corpus[sig]
+	root := &LineageNode{Sig: sig, Item: &item}
+	queue := []*LineageNode{root}
+	for n := 0; len(queue) != 0 && n < maxLineage; {
+		node := queue[0]
+		queue = queue[1:]
+		for _, sig1 := range children[node.Sig] {
+			if seen[sig1] || n >= maxLineage {
+				continue
+			}
+			seen[sig1] = true
+			n++
+			item := mgr.corpus[sig1]
+			child := &LineageNode{Sig: sig1, Item: &item}
+			node.Children = append(node.Children, child)
+			queue = append(queue, child)
+		}
+	}
+	return ancestors, root
+}
+
+// lineageDepths returns the number of ancestors of all corpus inputs.
+// Must be called with mgr.mu held.
+func (mgr *Manager) lineageDepths() map[string]int {
+	depths := make(map[string]int)
+	var depth func(sig string) int
+	depth = func(sig string) int {
+		if d, ok := depths[sig]; ok {
+			return d
+		}
+		item, ok := mgr.corpus[sig]
+		if !ok || item.Provenance.Parent == "" {
+			return 0
+		}
+		// Mark the input first to break cycles.
+		depths[sig] = 0
+		d := depth(item.Provenance.Parent) + 1
+		depths[sig] = d
+		return d
+	}
+	for sig := range mgr.corpus {
+		depth(sig)
+	}
+	return depths
+}
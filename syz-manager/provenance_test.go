@@ -0,0 +1,119 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestProvenanceDB(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workdir := t.TempDir()
+	data := []byte("test$int(0x1, 0x2, 0x3, 0x4, 0x5)\n")
+	sig := hash.String(data)
+	prov := rpctype.Provenance{
+		Origin: rpctype.OriginMutate,
+		Ops:    []string{"mutate arg", "insert call syzllm"},
+		Parent: "0123456789abcdef0123456789abcdef01234567",
+		Added:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	// Version 5 records have the provenance after the program.
+	corpusDB, err := db.Open(filepath.Join(workdir, "corpus.db"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corpusDB.Save(sig, []byte(string(data)+legacyProvenanceComment+
+		`{"Origin":"mutate","Ops":["mutate arg","insert call syzllm"],`+
+		`"Parent":"0123456789abcdef0123456789abcdef01234567","Added":"2024-05-01T12:00:00Z"}`+"\n"), 0)
+	if err := corpusDB.BumpVersion(5); err != nil {
+		t.Fatal(err)
+	}
+	enabled := make(map[*prog.Syscall]bool)
+	for _, call := range target.Syscalls {
+		enabled[call] = true
+	}
+	mgr := &Manager{
+		cfg:                   &mgrconfig.Config{Workdir: workdir},
+		target:                target,
+		targetEnabledSyscalls: enabled,
+		corpus:                make(map[string]CorpusItem),
+		disabledHashes:        make(map[string]struct{}),
+		corpusDB:              corpusDB,
+		signalDB:              &db.DB{},
+	}
+	mgr.loadProvenance()
+	mgr.loadCorpus()
+	if rec := string(mgr.corpusDB.Records[sig].Val); rec != string(data) {
+		t.Fatalf("corpus record is not a plain program:\n%s", rec)
+	}
+	if len(mgr.candidates) == 0 || !reflect.DeepEqual(mgr.candidates[0].Provenance, prov) {
+		t.Fatalf("bad candidates: %+v", mgr.candidates)
+	}
+	// The provenance survives the restart.
+	mgr.loadProvenance()
+	if prov1 := mgr.savedProvenance(sig); !reflect.DeepEqual(prov1, prov) {
+		t.Fatalf("got %+v, want %+v", prov1, prov)
+	}
+	if prov1 := mgr.savedProvenance(hash.String([]byte("test$int(0x0)\n"))); prov1.Origin != rpctype.OriginCorpus {
+		t.Fatalf("unknown input: got %+v", prov1)
+	}
+}
+
+func TestLineage(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &Manager{
+		cfg:    &mgrconfig.Config{},
+		target: target,
+		corpus: make(map[string]CorpusItem),
+	}
+	add := func(sig, parent, origin string) {
+		mgr.corpus[sig] = CorpusItem{
+			Prog:       []byte("test$int(0x1, 0x2, 0x3, 0x4, 0x5)\n"),
+			Provenance: rpctype.Provenance{Origin: origin, Parent: parent},
+		}
+	}
+	// gone <- a <- b <- {c, d <- e}, f is unrelated.
+	add("a", "gone", rpctype.OriginMutate)
+	add("b", "a", rpctype.OriginSmash)
+	add("c", "b", rpctype.OriginMutate)
+	add("d", "b", rpctype.OriginHint)
+	add("e", "d", rpctype.OriginMutate)
+	add("f", "", rpctype.OriginGenerate)
+	ancestors, root := mgr.lineage("b")
+	if len(ancestors) != 2 || ancestors[0].Sig != "gone" || ancestors[0].Item != nil ||
+		ancestors[1].Sig != "a" || ancestors[1].Item == nil {
+		t.Fatalf("bad ancestors: %+v", ancestors)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("bad descendants: %+v", root.Children)
+	}
+	depths := mgr.lineageDepths()
+	want := map[string]int{"a": 1, "b": 2, "c": 3, "d": 3, "e": 4}
+	if !reflect.DeepEqual(depths, want) {
+		t.Fatalf("got depths %v, want %v", depths, want)
+	}
+	w := httptest.NewRecorder()
+	mgr.httpInput(w, httptest.NewRequest("GET", "/input?sig=b", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "3 descendants") {
+		t.Fatalf("bad /input page (%v):\n%s", w.Code, w.Body.String())
+	}
+}
@@ -26,6 +26,7 @@ type RPCServer struct {
 	port                  int
 	targetEnabledSyscalls map[*prog.Syscall]bool
 	coverFilter           map[uint32]uint32
+	syzLLMBundle          *prog.SyzLLMBundle
 	stats                 *Stats
 	batchSize             int
 	canonicalModules      *cover.Canonicalizer
@@ -39,6 +40,10 @@ type RPCServer struct {
 	rotator       *prog.Rotator
 	rnd           *rand.Rand
 	checkFailures int
+
+	// State of the "propose" SyzLLM policy for predicted calls that are not enabled on VMs.
+	syzLLMProposals map[*prog.Syscall]uint64
+	syzLLMCalls     []int // accepted calls in the order of acceptance
 }
 
 type Fuzzer struct {
@@ -49,6 +54,8 @@ type Fuzzer struct {
 	rotatedSignal signal.Signal
 	machineInfo   []byte
 	instModules   *cover.CanonicalizerInstance
+	syzLLMDown    bool // the fuzzer considers the SyzLLM server down
+	opWeights     map[string]uint64
 }
 
 type BugFrames struct {
@@ -60,19 +67,23 @@ type BugFrames struct {
 type RPCManagerView interface {
 	fuzzerConnect([]host.KernelModule) (
 		[]rpctype.Input, BugFrames, map[uint32]uint32, map[uint32]uint32, error)
-	machineChecked(result *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool)
-	newInput(inp rpctype.Input, sign signal.Signal) bool
+	machineChecked(result *rpctype.CheckArgs, enabledSyscalls map[*prog.Syscall]bool) *resumedState
+	newInput(inp rpctype.Input, sign signal.Signal) (rpctype.NewInputRes, bool)
+	updateSeeds(stats map[string]rpctype.SeedStats)
 	candidateBatch(size int) []rpctype.Candidate
 	rotateCorpus() bool
 }
 
 func startRPCServer(mgr *Manager) (*RPCServer, error) {
 	serv := &RPCServer{
-		mgr:     mgr,
-		cfg:     mgr.cfg,
-		stats:   mgr.stats,
-		fuzzers: make(map[string]*Fuzzer),
-		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		mgr:          mgr,
+		cfg:          mgr.cfg,
+		stats:        mgr.stats,
+		syzLLMBundle: mgr.syzLLMBundle,
+		fuzzers:      make(map[string]*Fuzzer),
+		rnd:          rand.New(rand.NewSource(time.Now().UnixNano())),
+
+		syzLLMProposals: make(map[*prog.Syscall]uint64),
 	}
 	serv.batchSize = 5
 	if serv.batchSize < mgr.cfg.Procs {
@@ -118,8 +129,28 @@ func (serv *RPCServer) Connect(a *rpctype.ConnectArgs, r *rpctype.ConnectRes) er
 	r.CoverFilterBitmap = createCoverageBitmap(serv.cfg.SysTarget, instCoverFilter)
 	r.EnabledCalls = serv.cfg.Syscalls
 	r.NoMutateCalls = serv.cfg.NoMutateCalls
+	r.AdaptiveMutations = serv.cfg.MutationScheduler == mgrconfig.MutationSchedulerBandit
 	r.GitRevision = prog.GitRevision
 	r.TargetRevision = serv.cfg.Target.Revision
+	if serv.cfg.SyzLLM.Enabled {
+		r.SyzLLM = rpctype.SyzLLMConfig{
+			Predictor:    serv.cfg.SyzLLM.Predictor,
+			Addr:         serv.cfg.SyzLLM.Addr,
+			Timeout:      time.Duration(serv.cfg.SyzLLM.Timeout) * time.Millisecond,
+			InsertProb:   serv.cfg.SyzLLM.InsertProb,
+			ArgProb:      serv.cfg.SyzLLM.ArgProb,
+			GenerateProb: serv.cfg.SyzLLM.GenerateProb,
+			Bundle:       serv.syzLLMBundle,
+
+			DisabledCalls: serv.cfg.SyzLLM.DisabledCalls,
+			Prompt:        serv.cfg.SyzLLM.Prompt,
+			StrictRepair:  serv.cfg.SyzLLM.StrictRepair,
+			AcceptedCalls: append([]int{}, serv.syzLLMCalls...),
+		}
+		for call := range serv.targetEnabledSyscalls {
+			r.SyzLLM.SupportedCalls = append(r.SyzLLM.SupportedCalls, call.ID)
+		}
+	}
 	if serv.mgr.rotateCorpus() && serv.rnd.Intn(5) == 0 {
 		// We do rotation every other time because there are no objective
 		// proofs regarding its efficiency either way.
@@ -248,14 +279,17 @@ func (serv *RPCServer) Check(a *rpctype.CheckArgs, r *int) error {
 	for _, feat := range a.Features.Supported() {
 		log.Logf(0, "%-24v: %v", feat.Name, feat.Reason)
 	}
-	serv.mgr.machineChecked(a, serv.targetEnabledSyscalls)
+	res := serv.mgr.machineChecked(a, serv.targetEnabledSyscalls)
+	if err := serv.resume(res); err != nil {
+		return err
+	}
 	a.DisabledCalls = nil
 	serv.checkResult = a
 	serv.rotator = prog.MakeRotator(serv.cfg.Target, serv.targetEnabledSyscalls, serv.rnd)
 	return nil
 }
 
-func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *int) error {
+func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *rpctype.NewInputRes) error {
 	bad, disabled := checkProgram(serv.cfg.Target, serv.targetEnabledSyscalls, a.Input.Prog)
 	if bad != nil || disabled {
 		log.Errorf("rejecting program from fuzzer (bad=%v, disabled=%v):\n%s", bad, disabled, a.Input.Prog)
@@ -281,28 +315,19 @@ func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *int) error {
 	if !genuine && !rotated {
 		return nil
 	}
-	if !serv.mgr.newInput(a.Input, inputSignal) {
+	res, ok := serv.mgr.newInput(a.Input, inputSignal)
+	if !ok {
 		return nil
 	}
+	*r = res
+	a.Input.Seed = res.Seed
+	a.Input.Distance = res.Distance
 
 	if f != nil && f.rotated {
 		f.rotatedSignal.Merge(inputSignal)
 	}
-	diff := serv.corpusCover.MergeDiff(a.Cover)
-	serv.stats.corpusCover.set(len(serv.corpusCover))
-	if len(diff) != 0 && serv.coverFilter != nil {
-		// Note: ReportGenerator is already initialized if coverFilter is enabled.
-		rg, err := getReportGenerator(serv.cfg, serv.modules)
-		if err != nil {
-			return err
-		}
-		filtered := 0
-		for _, pc := range diff {
-			if serv.coverFilter[uint32(rg.RestorePC(pc))] != 0 {
-				filtered++
-			}
-		}
-		serv.stats.corpusCoverFiltered.add(filtered)
+	if err := serv.mergeCorpusCover(a.Cover); err != nil {
+		return err
 	}
 	serv.stats.newInputs.inc()
 	if rotated {
@@ -325,6 +350,26 @@ func (serv *RPCServer) NewInput(a *rpctype.NewInputArgs, r *int) error {
 	return nil
 }
 
+func (serv *RPCServer) mergeCorpusCover(cov []uint32) error {
+	diff := serv.corpusCover.MergeDiff(cov)
+	serv.stats.corpusCover.set(len(serv.corpusCover))
+	if len(diff) != 0 && serv.coverFilter != nil {
+		// Note: ReportGenerator is already initialized if coverFilter is enabled.
+		rg, err := getReportGenerator(serv.cfg, serv.modules)
+		if err != nil {
+			return err
+		}
+		filtered := 0
+		for _, pc := range diff {
+			if serv.coverFilter[uint32(rg.RestorePC(pc))] != 0 {
+				filtered++
+			}
+		}
+		serv.stats.corpusCoverFiltered.add(filtered)
+	}
+	return nil
+}
+
 func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 	serv.stats.mergeNamed(a.Stats)
 
@@ -338,6 +383,9 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 		log.Logf(1, "poll: fuzzer %v is not connected", a.Name)
 		return nil
 	}
+	f.syzLLMDown = a.SyzLLMDown
+	f.opWeights = a.MutationWeights
+	serv.mgr.updateSeeds(a.SeedStats)
 	newMaxSignal := serv.maxSignal.Diff(a.MaxSignal.Deserialize())
 	if !newMaxSignal.Empty() {
 		serv.maxSignal.Merge(newMaxSignal)
@@ -349,6 +397,11 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 			f1.newMaxSignal.Merge(newMaxSignal)
 		}
 	}
+	if serv.cfg.SyzLLM.DisabledCalls == mgrconfig.SyzLLMDisabledPropose {
+		serv.proposeSyzLLMCalls(a.SyzLLMProposals)
+		// All VMs must see the same set of accepted calls, including the rotated ones.
+		r.SyzLLMCalls = serv.syzLLMCalls
+	}
 	if f.rotated {
 		// Let rotated VMs run in isolation, don't send them anything.
 		return nil
@@ -385,6 +438,70 @@ func (serv *RPCServer) Poll(a *rpctype.PollArgs, r *rpctype.PollRes) error {
 	return nil
 }
 
+// syzLLMServerDown returns the number of connected fuzzers that consider the SyzLLM server down.
+func (serv *RPCServer) syzLLMServerDown() (down, total int) {
+	serv.mu.Lock()
+	defer serv.mu.Unlock()
+	for _, f := range serv.fuzzers {
+		total++
+		if f.syzLLMDown {
+			down++
+		}
+	}
+	return
+}
+
+// mutationWeights returns weights of mutation operators (per mille) averaged over connected fuzzers.
+func (serv *RPCServer) mutationWeights() map[string]uint64 {
+	serv.mu.Lock()
+	defer serv.mu.Unlock()
+	sum := make(map[string]uint64)
+	fuzzers := uint64(0)
+	for _, f := range serv.fuzzers {
+		if len(f.opWeights) == 0 {
+			continue
+		}
+		fuzzers++
+		for op, w := range f.opWeights {
+			sum[op] += w
+		}
+	}
+	for op := range sum {
+		sum[op] /= fuzzers
+	}
+	return sum
+}
+
+// syzLLMProposeThreshold is the number of proposals after which a predicted call
+// is enabled on all VMs under the "propose" SyzLLM policy.
+const syzLLMProposeThreshold = 10
+
+// proposeSyzLLMCalls accounts predicted calls rejected by VMs and accepts the ones
+// that were proposed often enough. Only calls that are enabled in the config and supported
+// by the machine are accepted.
+func (serv *RPCServer) proposeSyzLLMCalls(proposals map[int]uint64) {
+	for id, count := range proposals {
+		if id < 0 || id >= len(serv.cfg.Target.Syscalls) {
+			continue
+		}
+		call := serv.cfg.Target.Syscalls[id]
+		prev := serv.syzLLMProposals[call]
+		if prev >= syzLLMProposeThreshold {
+			continue
+		}
+		serv.syzLLMProposals[call] = prev + count
+		if prev+count < syzLLMProposeThreshold {
+			continue
+		}
+		if !serv.targetEnabledSyscalls[call] {
+			log.Logf(0, "SyzLLM: not enabling proposed call %v: disabled in the config or unsupported", call.Name)
+			continue
+		}
+		log.Logf(0, "SyzLLM: enabling proposed call %v on all VMs", call.Name)
+		serv.syzLLMCalls = append(serv.syzLLMCalls, id)
+	}
+}
+
 func (serv *RPCServer) shutdownInstance(name string) []byte {
 	serv.mu.Lock()
 	defer serv.mu.Unlock()
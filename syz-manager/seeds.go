@@ -0,0 +1,93 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpctype"
+)
+
+// Fuzzers choose corpus inputs for mutation with a power schedule based on per-input counters
+// (see syz-fuzzer/energy.go). The manager sums the counters reported by fuzzers in CorpusItem.Seed,
+// passes them to fuzzers together with the inputs and mirrors them in seeds.db,
+// so that the schedule survives fuzzer and manager restarts.
+
+// seedsFlushPeriod limits how often seeds.db is written, the counters change on every poll.
+const seedsFlushPeriod = time.Minute
+
+func (mgr *Manager) loadSeeds() {
+	seedDB, err := db.Open(filepath.Join(mgr.cfg.Workdir, "seeds.db"), true)
+	if err != nil {
+		if seedDB == nil {
+			log.Fatalf("failed to open seeds database: %v", err)
+		}
+		log.Errorf("read %v seeds and got error: %v", len(seedDB.Records), err)
+	}
+	mgr.seedDB = seedDB
+}
+
+// savedSeed returns the saved counters of the input with the hash sig.
+func (mgr *Manager) savedSeed(sig string) rpctype.SeedStats {
+	rec, ok := mgr.seedDB.Records[sig]
+	if !ok {
+		return rpctype.SeedStats{}
+	}
+	stats, err := deserializeSeed(rec.Val)
+	if err != nil {
+		log.Errorf("bad seed %v: %v", sig, err)
+	}
+	return stats
+}
+
+// updateSeeds adds counters accumulated by a fuzzer (input hash -> counters) to the corpus inputs.
+func (mgr *Manager) updateSeeds(stats map[string]rpctype.SeedStats) {
+	if len(stats) == 0 {
+		return
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	for sig, delta := range stats {
+		item, ok := mgr.corpus[sig]
+		if !ok {
+			// The input was minimized out of the corpus or the fuzzer hasn't sent it yet.
+			continue
+		}
+		item.Seed.Add(delta)
+		mgr.corpus[sig] = item
+		mgr.seedDB.Save(sig, serializeSeed(item.Seed), 0)
+	}
+	if time.Since(mgr.seedsFlushed) < seedsFlushPeriod {
+		return
+	}
+	mgr.seedsFlushed = time.Now()
+	if err := mgr.seedDB.Flush(); err != nil {
+		log.Errorf("failed to save seeds database: %v", err)
+	}
+}
+
+func serializeSeed(stats rpctype.SeedStats) []byte {
+	buf := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, stats.Mutations)
+	n += binary.PutUvarint(buf[n:], stats.NewSignal)
+	return buf[:n]
+}
+
+func deserializeSeed(data []byte) (rpctype.SeedStats, error) {
+	var stats rpctype.SeedStats
+	var n, n1 int
+	stats.Mutations, n = binary.Uvarint(data)
+	if n > 0 {
+		stats.NewSignal, n1 = binary.Uvarint(data[n:])
+	}
+	if n <= 0 || n1 <= 0 || n+n1 != len(data) {
+		return rpctype.SeedStats{}, fmt.Errorf("malformed record %x", data)
+	}
+	return stats, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/pkg/rpctype"
+)
+
+func TestSerializeSeed(t *testing.T) {
+	for _, stats := range []rpctype.SeedStats{
+		{},
+		{Mutations: 1},
+		{Mutations: 1 << 40, NewSignal: 12345},
+	} {
+		data := serializeSeed(stats)
+		got, err := deserializeSeed(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != stats {
+			t.Fatalf("got %+v, want %+v", got, stats)
+		}
+		if _, err := deserializeSeed(append(data, 0)); err == nil {
+			t.Fatalf("trailing data is accepted")
+		}
+		if _, err := deserializeSeed(data[:len(data)-1]); err == nil {
+			t.Fatalf("truncated data is accepted")
+		}
+	}
+}
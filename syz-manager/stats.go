@@ -4,9 +4,13 @@
 package main
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/google/syzkaller/pkg/rpctype"
+	"github.com/google/syzkaller/prog"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -58,6 +62,76 @@ func (mgr *Manager) initStats() {
 	},
 		func() float64 { return float64(mgr.stats.crashes.get()) },
 	))
+	for op := prog.MutationOp(0); op < prog.MutationOpCount; op++ {
+		name := op.String()
+		prometheus.Register(promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "syz_mutation_weight",
+			Help:        "Weight of the mutation operator averaged over fuzzers (per mille)",
+			ConstLabels: prometheus.Labels{"op": name},
+		},
+			func() float64 { return float64(mgr.serv.mutationWeights()[name]) },
+		))
+		signalName := rpctype.MutationSignalStat + name
+		prometheus.Register(promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "syz_mutation_new_signal",
+			Help:        "Count of programs mutated with the operator that gave new signal",
+			ConstLabels: prometheus.Labels{"op": name},
+		},
+			func() float64 { return float64(mgr.stats.namedStat(signalName)) },
+		))
+	}
+	if !mgr.cfg.SyzLLM.Enabled {
+		return
+	}
+	for res := prog.PredictionResult(0); res < prog.PredictionResultCount; res++ {
+		name := rpctype.SyzLLMResultStat + res.String()
+		prometheus.Register(promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "syz_syzllm_predictions",
+			Help:        "Count of SyzLLM prediction attempts by result",
+			ConstLabels: prometheus.Labels{"result": res.String()},
+		},
+			func() float64 { return float64(mgr.stats.namedStat(name)) },
+		))
+	}
+	// Latency buckets are exported in the prometheus histogram format (cumulative counts with le label),
+	// so that histogram_quantile can be used with them.
+	var latencyNames []string
+	for i := 0; i <= len(rpctype.SyzLLMLatencyBuckets); i++ {
+		latencyNames = append(latencyNames, rpctype.SyzLLMLatencyStat+rpctype.SyzLLMLatencyBucket(i))
+		names := append([]string{}, latencyNames...)
+		le := "+Inf"
+		if i < len(rpctype.SyzLLMLatencyBuckets) {
+			le = strconv.FormatFloat(rpctype.SyzLLMLatencyBuckets[i].Seconds(), 'g', -1, 64)
+		}
+		prometheus.Register(promauto.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "syz_syzllm_latency_seconds_bucket",
+			Help:        "Count of SyzLLM requests with latency less than or equal to le",
+			ConstLabels: prometheus.Labels{"le": le},
+		},
+			func() float64 {
+				var count uint64
+				for _, name := range names {
+					count += mgr.stats.namedStat(name)
+				}
+				return float64(count)
+			},
+		))
+	}
+	prometheus.Register(promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "syz_syzllm_server_down",
+		Help: "Number of fuzzers that consider the SyzLLM server down",
+	},
+		func() float64 {
+			down, _ := mgr.serv.syzLLMServerDown()
+			return float64(down)
+		},
+	))
+	prometheus.Register(promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "syz_syzllm_corpus_inputs",
+		Help: "Count of corpus inputs found by programs mutated with SyzLLM",
+	},
+		func() float64 { return float64(mgr.syzLLMCorpusInputs()) },
+	))
 }
 
 func (stats *Stats) all() map[string]uint64 {
@@ -91,6 +165,25 @@ func (stats *Stats) all() map[string]uint64 {
 	return m
 }
 
+func (stats *Stats) namedStat(name string) uint64 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	return stats.namedStats[name]
+}
+
+// namedWithPrefix returns named stats starting with prefix, the prefix is stripped.
+func (stats *Stats) namedWithPrefix(prefix string) map[string]uint64 {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	m := make(map[string]uint64)
+	for k, v := range stats.namedStats {
+		if strings.HasPrefix(k, prefix) {
+			m[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return m
+}
+
 func (stats *Stats) mergeNamed(named map[string]uint64) {
 	stats.mu.Lock()
 	defer stats.mu.Unlock()
@@ -2,9 +2,12 @@
 // Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
 
 // mutates mutates a given program and prints result.
+// With -replay it repeats SyzLLM insertions recorded by syz-fuzzer -syzllm_record instead.
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -30,6 +33,8 @@ var (
 	flagHintSrc  = flag.Uint64("hint-src", 0, "compared value in the program")
 	flagHintCmp  = flag.Uint64("hint-cmp", 0, "compare operand in the kernel")
 	flagStrict   = flag.Bool("strict", true, "parse input program in strict mode")
+	flagReplay   = flag.String("replay", "", "replay SyzLLM insertions recorded by syz-fuzzer -syzllm_record")
+	flagHash     = flag.String("replay-hash", "", "replay only insertions into the program with this hash")
 )
 
 func main() {
@@ -68,6 +73,13 @@ func main() {
 	}
 	rs := rand.NewSource(seed)
 	ct := target.BuildChoiceTable(corpus, syscalls)
+	if *flagReplay != "" {
+		if err := replay(target, ct, corpus, *flagReplay, *flagHash); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	var p *prog.Prog
 	if flag.NArg() == 0 {
 		p = target.Generate(rs, *flagLen, ct)
@@ -99,3 +111,43 @@ func main() {
 	}
 	fmt.Printf("%s\n", p.Serialize())
 }
+
+// replay repeats recorded insertions of predicted calls and prints the resulting programs.
+// The results match the recorded ones only if -enable and -corpus match the fuzzer's ones.
+func replay(target *prog.Target, ct *prog.ChoiceTable, corpus []*prog.Prog, file, progHash string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to open the record file: %w", err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64<<20)
+	for line := 1; s.Scan(); line++ {
+		rec := new(prog.PredictionRecord)
+		if err := json.Unmarshal(s.Bytes(), rec); err != nil {
+			return fmt.Errorf("%v:%v: failed to parse the record: %w", file, line, err)
+		}
+		if !strings.HasPrefix(rec.ProgHash, progHash) {
+			continue
+		}
+		p, res, err := target.ReplayPrediction(rec, ct, corpus)
+		fmt.Printf("# %v:%v: prog %v, pos %v, seed %v: %v", file, line, rec.ProgHash, rec.Pos, rec.Seed, res)
+		if err != nil {
+			fmt.Printf(": %v", err)
+		}
+		fmt.Printf("\n")
+		if res.String() != rec.Result {
+			fmt.Fprintf(os.Stderr, "%v:%v: replay result %v differs from the recorded %v (%v)\n",
+				file, line, res, rec.Result, rec.Err)
+		}
+		if p == nil {
+			continue
+		}
+		data := p.Serialize()
+		if rec.Mutated != "" && string(data) != rec.Mutated {
+			fmt.Fprintf(os.Stderr, "%v:%v: replayed program differs from the recorded one\n", file, line)
+		}
+		fmt.Printf("%s\n", data)
+	}
+	return s.Err()
+}
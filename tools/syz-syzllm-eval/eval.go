@@ -0,0 +1,105 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-syzllm-eval evaluates a SyzLLM predictor on held-out corpus programs without fuzzing.
+// A random call of every program is masked and the predictor is asked for it.
+// Usage:
+//
+//	syz-syzllm-eval -os=linux -arch=amd64 -addr=localhost:6678 [-k=5] heldout-corpus.db...
+//	syz-syzllm-eval -os=linux -arch=amd64 -predictor=ngram -train=corpus.db heldout-corpus.db...
+//
+// Reports top-1/top-k accuracy of call names (exact variants and base names) and the fractions
+// of candidates that have well-formed resource tags, deserialize, and produce valid programs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/syzllm"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func main() {
+	var (
+		flagOS        = flag.String("os", runtime.GOOS, "target OS")
+		flagArch      = flag.String("arch", runtime.GOARCH, "target arch")
+		flagPredictor = flag.String("predictor", "http", "predictor: http or ngram")
+		flagAddr      = flag.String("addr", "", "SyzLLM server address (for the http predictor)")
+		flagTimeout   = flag.Duration("timeout", 10*time.Second, "request timeout (for the http predictor)")
+		flagTrain     = flag.String("train", "", "comma-separated corpus.db files to build the ngram predictor from")
+		flagN         = flag.Int("n", 3, "order of the ngram predictor")
+		flagK         = flag.Int("k", 5, "number of candidates to consider")
+		flagPrompt    = flag.String("prompt", "flags", "prompt level: names, flags or full")
+		flagSeed      = flag.Int64("seed", 0, "seed for masked positions")
+	)
+	flag.Parse()
+	if flag.NArg() == 0 || *flagK < 1 {
+		fmt.Fprintf(os.Stderr, "usage: syz-syzllm-eval [flags] heldout-corpus.db...\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	target, err := prog.GetTarget(*flagOS, *flagArch)
+	if err != nil {
+		tool.Failf("failed to find target: %v", err)
+	}
+	level, err := prog.ParsePromptLevel(*flagPrompt)
+	if err != nil {
+		tool.Fail(err)
+	}
+	var pred prog.CallPredictor
+	switch *flagPredictor {
+	case "http":
+		if *flagAddr == "" {
+			tool.Failf("-addr is required for the http predictor")
+		}
+		pred = prog.NewHTTPPredictor(*flagAddr, *flagTimeout)
+	case "ngram":
+		if *flagTrain == "" {
+			tool.Failf("-train is required for the ngram predictor")
+		}
+		var corpus []*prog.Prog
+		for _, file := range strings.Split(*flagTrain, ",") {
+			corpus = append(corpus, loadCorpus(target, file)...)
+		}
+		pred = prog.NewNgramPredictor(corpus, *flagN)
+	default:
+		tool.Failf("unknown predictor %q", *flagPredictor)
+	}
+	ev := syzllm.NewEvaluator(target, pred, *flagK, level, *flagSeed)
+	for _, file := range flag.Args() {
+		ev.Process(openCorpus(file))
+	}
+	fmt.Print(ev.Stats())
+}
+
+func openCorpus(file string) *db.DB {
+	corpusDB, err := db.Open(file, false)
+	if err != nil {
+		if corpusDB == nil {
+			tool.Failf("failed to open corpus database %v: %v", file, err)
+		}
+		log.Errorf("read %v inputs from %v and got error: %v", len(corpusDB.Records), file, err)
+	}
+	return corpusDB
+}
+
+func loadCorpus(target *prog.Target, file string) []*prog.Prog {
+	var corpus []*prog.Prog
+	for _, rec := range openCorpus(file).Records {
+		p, err := target.Deserialize(rec.Val, prog.NonStrict)
+		if err != nil {
+			continue
+		}
+		corpus = append(corpus, p)
+	}
+	return corpus
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-syzllm-prep converts corpus.db files into SyzLLM training data.
+// Usage:
+//
+//	syz-syzllm-prep -os=linux -arch=amd64 -out=data [-type=constant|brutal] corpus.db...
+//
+// The output directory gets tokens/tokens_N.txt, vocab/vocab.txt,
+// (for the constant preprocessor) addr.txt files and bundle.json
+// that must be passed to syz-manager in the syzllm.bundle config parameter.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/syzllm"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func main() {
+	var (
+		flagOS   = flag.String("os", runtime.GOOS, "target OS")
+		flagArch = flag.String("arch", runtime.GOARCH, "target arch")
+		flagOut  = flag.String("out", "", "output directory")
+		flagType = flag.String("type", "constant", "preprocessor: constant or brutal")
+	)
+	flag.Parse()
+	if *flagOut == "" || flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: syz-syzllm-prep -out=dir [flags] corpus.db...\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	target, err := prog.GetTarget(*flagOS, *flagArch)
+	if err != nil {
+		tool.Failf("failed to find target: %v", err)
+	}
+	typ, err := syzllm.ParsePreprocessorType(*flagType)
+	if err != nil {
+		tool.Fail(err)
+	}
+	session, err := syzllm.NewSession(target, typ, *flagOut)
+	if err != nil {
+		tool.Fail(err)
+	}
+	for _, file := range flag.Args() {
+		corpusDB, err := db.Open(file, false)
+		if err != nil {
+			if corpusDB == nil {
+				tool.Failf("failed to open corpus database %v: %v", file, err)
+			}
+			log.Errorf("read %v inputs from %v and got error: %v", len(corpusDB.Records), file, err)
+		}
+		if err := session.Process(corpusDB); err != nil {
+			tool.Failf("failed to preprocess %v: %v", file, err)
+		}
+	}
+	summary, err := session.Finish()
+	if err != nil {
+		tool.Fail(err)
+	}
+	fmt.Print(summary)
+}